@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"syscall"
 
 	"github.com/BigOokie/skywire-wing-commander/internal/telegrambot"
 	"github.com/BigOokie/skywire-wing-commander/internal/utils"
@@ -26,54 +27,92 @@ func main() {
 	wc.cmdFlags.parseCmdLineFlags()
 	wc.cmdFlags.handleCmdLineFlags()
 
+	// Create the configuration interactively and exit
+	if wc.cmdFlags.setup {
+		wc.runSetup()
+	}
+
 	// Load configuration
 	wc.loadConfig()
-	wc.config.PrintConfig()
+	wc.configureLogging()
+	// The health check output is reported over Telegram, so must not include the config
+	if !wc.cmdFlags.healthcheck && !wc.cmdFlags.validateconfig {
+		wc.config.PrintConfig()
+	}
 	if wc.cmdFlags.dumpconfig {
 		os.Exit(0)
 	}
+	// Check the configuration before using it
+	wc.validateConfig()
+
+	// Load persisted runtime state and open the audit log
+	wc.loadState()
+	wc.openAuditLog()
+
+	// Check this version is healthy (prior to an upgrade) and exit.
+	// This must not acquire the instance lock which is held by the running instance.
+	if wc.cmdFlags.healthcheck {
+		wc.runHealthCheck()
+	}
+
+	// Exit (with exitCode) once the deferred calls below, such as releasing
+	// the instance lock, have completed
+	exitCode := 0
+	defer func() { os.Exit(exitCode) }()
 
 	// Check and setup application instance control. Only allow a single instance to run
-	appInstance := utils.InitAppInstance(wcconst.AppInstanceID)
+	// per configuration file and profile
+	appInstance := utils.InitAppInstance(wc.instanceID())
 	defer utils.ReleaseAppInstance(appInstance)
 
-	// Setup OS Notification for Interrupt or Kill signal - to cleanly terminate the app
+	// Setup OS Notification for Interrupt or Terminate (i.e. from systemd) signals to cleanly
+	// terminate the app, and the Hangup signal to reload the configuration
 	osSignal := make(chan os.Signal, 1)
-	signal.Notify(osSignal, os.Interrupt)
+	signal.Notify(osSignal, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 
 	log.Infoln("Skywire Wing Commander Telegram Bot - Starting.")
 	defer log.Infoln("Skywire Wing Commander Telegram Bot - Stopped.")
 
 	// Initiate a new Bot instance
 	log.Infoln("Initiating Bot instance.")
-	bot, err := telegrambot.NewBot(wc.config)
-	if err != nil {
-		log.Error(err)
-		return
-	}
-
+	var bot *telegrambot.Bot
+	var err error
 	var startmsg string
 	// Check to see if we are starting because of an upgrade.
 	if wc.cmdFlags.upgradecompleted {
+		// The upgrade is rolled back if the Bot does not become healthy
+		bot, err = wc.confirmUpgrade()
+		if err != nil {
+			log.Error(err)
+			exitCode = 1
+			return
+		}
 		startmsg = fmt.Sprintf("*Successfully restarted after upgrade to %s*", wcconst.BotVersion)
 	} else {
+		bot, err = telegrambot.NewBot(wc.config, wc.state, wc.audit)
+		if err != nil {
+			log.Error(err)
+			exitCode = 1
+			return
+		}
+		wc.reportUpgrade(bot)
 		startmsg = fmt.Sprintf("*Started: %s*", wcconst.BotAppVersion)
 	}
 	log.Debug(startmsg)
 	err = bot.SendNewMessage("markdown", startmsg)
 	if err != nil {
-		log.Fatalf("Failed to send startup message to Telegram: %v", err)
+		log.Errorf("Failed to send startup message to Telegram: %v", err)
 	}
 
 	err = bot.SendMainMenuMessage(nil)
 	if err != nil {
-		log.Fatalf("Failed to Send Main Menu: %v", err)
+		log.Errorf("Failed to Send Main Menu: %v", err)
 	}
 
-	log.Infoln("Starting Bot instance.")
-	go bot.Start()
+	// Apply changes to config.toml without a restart
+	wc.watchConfig(bot)
 
-	// Wait for the app to be signaled to terminate
-	signal := <-osSignal
-	log.Debugln(wcconst.MsgOSInteruptSig, signal)
+	// Run the Bot until the app is signaled to terminate
+	log.Infoln("Starting Bot instance.")
+	exitCode = wc.runBot(bot, osSignal)
 }
@@ -6,45 +6,157 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"syscall"
+	"time"
 
+	"github.com/BigOokie/skywire-wing-commander/internal/telegrambot"
+	"github.com/BigOokie/skywire-wing-commander/internal/updater"
 	"github.com/BigOokie/skywire-wing-commander/internal/utils"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcaudit"
 	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
 	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	"github.com/BigOokie/skywire-wing-commander/internal/wclog"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcsetup"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcstate"
 	log "github.com/sirupsen/logrus"
 )
 
+// shutdownTimeout is the time notifications which are being sent are given to complete on shutdown
+const shutdownTimeout = 10 * time.Second
+
 type cmdlineFlags struct {
 	dumpconfig       bool
 	version          bool
 	help             bool
 	about            bool
 	upgradecompleted bool
+	healthcheck      bool
+	validateconfig   bool
+	setup            bool
+	configfile       string
+	profile          string
+	// overrides holds the configuration parameters provided on the command line, by key
+	overrides map[string]string
+}
+
+// configFlag is a command line flag which overrides the configuration parameter
+// identified by key (i.e. `-skymanager.address 127.0.0.1:8000`)
+type configFlag struct {
+	key    string
+	values map[string]string
+}
+
+func (f configFlag) String() string {
+	if f.values == nil {
+		return ""
+	}
+	return f.values[f.key]
+}
+
+func (f configFlag) Set(value string) error {
+	f.values[f.key] = value
+	return nil
 }
 
 type wcBotApp struct {
 	config   wcconfig.Config
+	state    *wcstate.State
+	audit    *wcaudit.Log
 	cmdFlags cmdlineFlags
 }
 
+// configDir returns the Wing Commander config folder
+func configDir() string {
+	return filepath.Join(utils.UserHome(), ".wingcommander")
+}
+
+// configFile returns the path of the configuration file, which is selected
+// using `-configfile` (default `~/.wingcommander/config.toml`)
+func (ba *wcBotApp) configFile() string {
+	if ba.cmdFlags.configfile != "" {
+		return ba.cmdFlags.configfile
+	}
+	return filepath.Join(configDir(), "config.toml")
+}
+
+// dataFile returns the path of a file holding runtime data (such as the state or audit log).
+// These reside alongside the configuration file, and are named after the profile (if one is
+// selected using `-profile`) so that each profile has its own (i.e. `state-staging.json`).
+func (ba *wcBotApp) dataFile(name, ext string) string {
+	if ba.cmdFlags.profile != "" {
+		name += "-" + ba.cmdFlags.profile
+	}
+	return filepath.Join(filepath.Dir(ba.configFile()), name+ext)
+}
+
+// instanceID returns the ID used to ensure only a single instance runs. Each configuration
+// file and profile has a distinct ID, so several bots can run on the same machine.
+func (ba *wcBotApp) instanceID() string {
+	id := wcconst.AppInstanceID
+	if ba.cmdFlags.configfile != "" {
+		path, err := filepath.Abs(ba.cmdFlags.configfile)
+		if err != nil {
+			path = ba.cmdFlags.configfile
+		}
+		id += fmt.Sprintf("-%x", sha256.Sum256([]byte(path)))[:9]
+	}
+	if ba.cmdFlags.profile != "" {
+		id += "-" + ba.cmdFlags.profile
+	}
+	return id
+}
+
+// configDefaults returns the default values of configuration parameters
+// which are not set within config.toml
+func configDefaults() map[string]interface{} {
+	return map[string]interface{}{
+		"wingcommander.analyticsenabled":       false,
+		"wingcommander.twofactormode":          "totp",
+		"wingcommander.twofactorexpirysec":     60,
+		"wingcommander.updateallowunsigned":    false,
+		"wingcommander.updatehealthtimeoutsec": 120,
+		"wingcommander.updatecheckintmin":      720,
+		"wingcommander.updatechannel":          "stable",
+		"telegram.debug":                       false,
+		"monitor.intervalsec":                  10,
+		"monitor.heartbeatintmin":              120,
+		"monitor.discoverymonitorintmin":       120,
+		"monitor.versioncheckintmin":           720,
+		"skymanager.address":                   "127.0.0.1:8000",
+		"skymanager.discoveryaddress":          "testnet.skywire.skycoin.com:8001",
+		"skymanager.skywirerepo":               "skycoin/skywire",
+		"host.checkintmin":                     5,
+		"host.diskpath":                        "/",
+		"host.maxload":                         2.0,
+		"host.maxmempct":                       90,
+		"host.mindiskfreepct":                  10,
+		"host.maxtempc":                        75,
+		"probe.intervalsec":                    60,
+		"probe.timeoutsec":                     2,
+		"log.level":                            "info",
+		"log.format":                           "text",
+		"log.maxsizemb":                        10,
+		"log.rotateintmin":                     1440,
+		"log.maxbackups":                       7,
+		"log.retentionmin":                     10080,
+		"webhook.enabled":                      false,
+		"webhook.listenaddress":                "127.0.0.1:8443",
+	}
+}
+
 // loadConfig manages the configuration load specifics
 // offloading the detail from the `main()` funct
 func (ba *wcBotApp) loadConfig() {
 	log.Debugln("wcBotApp.loadConfig: Start")
 	defer log.Debugln("wcBotApp.loadConfig: Complete")
 	// Load configuration
-	c, err := wcconfig.LoadConfigParameters("config", filepath.Join(utils.UserHome(), ".wingcommander"), map[string]interface{}{
-		"wingcommander.analyticsenabled": true,
-		"telegram.debug":                 false,
-		"monitor.intervalsec":            10,
-		"monitor.heartbeatintmin":        120,
-		"monitor.discoverymonitorintmin": 120,
-		"skymanager.address":             "127.0.0.1:8000",
-		"skymanager.discoveryaddress":    "testnet.skywire.skycoin.com:8001",
-	})
+	c, err := wcconfig.LoadConfigFile(ba.configFile(), ba.cmdFlags.profile, configDefaults())
 
 	if err != nil {
 		log.Fatalf("wcBotApp.loadConfig: Error loading configuration: %s", err)
@@ -53,14 +165,153 @@ func (ba *wcBotApp) loadConfig() {
 	ba.config = c
 }
 
+// watchConfig watches config.toml for changes, which are applied to the running Bot
+// (see telegrambot.Bot.ReloadConfig). Settings changed using /set are saved to the same file.
+func (ba *wcBotApp) watchConfig(bot *telegrambot.Bot) {
+	bot.SetConfigFile(ba.configFile(), ba.cmdFlags.profile)
+	err := wcconfig.WatchConfigFile(ba.configFile(), ba.cmdFlags.profile, configDefaults(), bot.ReloadConfig)
+	if err != nil {
+		log.Errorf("wcBotApp.watchConfig: Changes to config.toml will not be applied until restart: %v", err)
+	}
+}
+
+// reloadConfig reloads the configuration file and applies it to the running Bot (on SIGHUP),
+// as if it had been changed (see watchConfig)
+func (ba *wcBotApp) reloadConfig(bot *telegrambot.Bot) {
+	log.Infof("wcBotApp.reloadConfig: Reloading %s", ba.configFile())
+	bot.ReloadConfig(wcconfig.LoadConfigFile(ba.configFile(), ba.cmdFlags.profile, configDefaults()))
+}
+
+// runBot runs the Bot until the application is signaled to terminate (SIGINT or SIGTERM), or
+// the Bot fails. SIGHUP reloads the configuration. The Bot is then shut down, and the exit
+// code is returned.
+func (ba *wcBotApp) runBot(bot *telegrambot.Bot, osSignal <-chan os.Signal) int {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	botErr := make(chan error, 1)
+	go func() { botErr <- bot.Start(ctx) }()
+
+	exitCode := 0
+	reason := ""
+	for reason == "" {
+		select {
+		case sig := <-osSignal:
+			if sig == syscall.SIGHUP {
+				ba.reloadConfig(bot)
+				continue
+			}
+			log.Infoln(wcconst.MsgOSInteruptSig, sig)
+			reason = sig.String()
+		case err := <-botErr:
+			log.Errorf("wcBotApp.runBot: %v", err)
+			reason, exitCode = err.Error(), 1
+		}
+	}
+
+	cancel()
+	bot.Shutdown(reason, shutdownTimeout)
+	return exitCode
+}
+
+// validateConfig checks the loaded configuration. Warnings are logged, while errors prevent
+// the application from starting. When the `-validateconfig` flag is provided the outcome is
+// printed and the application exits (with a non-zero exit code if there are errors).
+func (ba *wcBotApp) validateConfig() {
+	issues := ba.config.Validate()
+
+	if ba.cmdFlags.validateconfig {
+		if len(issues) == 0 {
+			fmt.Println("Configuration is valid.")
+		} else {
+			fmt.Println(issues)
+		}
+		if issues.HasErrors() {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	for _, vi := range issues {
+		if vi.Severity == wcconfig.SeverityError {
+			log.Errorf("wcBotApp.validateConfig: %s", vi)
+		} else {
+			log.Warnf("wcBotApp.validateConfig: %s", vi)
+		}
+	}
+	if issues.HasErrors() {
+		log.Fatalf("wcBotApp.validateConfig: Configuration is invalid. Correct the errors above in %s", ba.configFile())
+	}
+}
+
+// runSetup runs the interactive setup wizard which creates config.toml, checks
+// the resulting configuration and exits
+func (ba *wcBotApp) runSetup() {
+	path := ba.configFile()
+	err := wcsetup.New(os.Stdin, os.Stdout).Run(path)
+	if wcsetup.IsCancelled(err) {
+		fmt.Printf("Setup cancelled. %s was not changed.\n", path)
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Printf("Setup failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	ba.cmdFlags.validateconfig = true
+	ba.loadConfig()
+	ba.validateConfig()
+}
+
+// loadState loads the persisted runtime state (i.e. bound Admin user IDs)
+// from the Wing Commander config folder
+func (ba *wcBotApp) loadState() {
+	log.Debugln("wcBotApp.loadState: Start")
+	defer log.Debugln("wcBotApp.loadState: Complete")
+	s, err := wcstate.LoadState(ba.dataFile("state", ".json"))
+	if err != nil {
+		log.Fatalf("wcBotApp.loadState: Error loading state: %s", err)
+		return
+	}
+	ba.state = s
+
+	if len(ba.config.Telegram.AdminIDs) == 0 && len(s.GetAdminIDs()) == 0 {
+		log.Warnf("wcBotApp.loadState: Admin %s is not yet bound to a Telegram user ID. Send /start to the bot to bind it.", ba.config.Telegram.Admin)
+	}
+}
+
+// openAuditLog sets up the audit log of commands issued to the bot
+// within the Wing Commander config folder
+func (ba *wcBotApp) openAuditLog() {
+	ba.audit = wcaudit.NewLog(ba.dataFile("audit", ".log"))
+	log.Infof("wcBotApp.openAuditLog: Commands will be audited to %s", ba.audit.Path())
+}
+
 func (cf *cmdlineFlags) parseCmdLineFlags() {
 	flag.BoolVar(&cf.version, "v", false, "print current version")
 	flag.BoolVar(&cf.dumpconfig, "config", false, "print current config")
 	flag.BoolVar(&cf.help, "help", false, "print application help")
 	flag.BoolVar(&cf.about, "about", false, "print application information")
 	flag.BoolVar(&cf.upgradecompleted, "upgradecompleted", false, "signals the application has been restarted following an upgrade")
+	flag.BoolVar(&cf.healthcheck, "upgradehealthcheck", false, "check Telegram and the Manager are reachable and exit (used before completing an upgrade)")
+	flag.BoolVar(&cf.validateconfig, "validateconfig", false, "validate the configuration and exit")
+	flag.BoolVar(&cf.setup, "setup", false, "interactively create the configuration file and exit")
+	flag.StringVar(&cf.configfile, "configfile", "", "path of the configuration file (default ~/.wingcommander/config.toml)")
+	flag.StringVar(&cf.profile, "profile", "", "name of the profile to use within the configuration file (see [profiles.<name>.*] sections)")
+
+	// Every configuration parameter can be overridden on the command line, except for secrets
+	// which would otherwise be visible to other users in the process list
+	cf.overrides = map[string]string{}
+	for _, key := range wcconfig.Keys() {
+		if wcconfig.IsSecret(key) {
+			continue
+		}
+		flag.Var(configFlag{key: key, values: cf.overrides}, key,
+			fmt.Sprintf("override %s in config.toml (also %s)", key, wcconfig.EnvName(key)))
+	}
 
 	flag.Parse()
+	wcconfig.SetOverrides(cf.overrides)
 }
 
 func (cf *cmdlineFlags) handleCmdLineFlags() {
@@ -85,6 +336,14 @@ func (cf *cmdlineFlags) handleCmdLineFlags() {
 		os.Exit(0)
 	}
 
+	// if profile cmd line flag `-profile` is not a valid profile name then exit
+	if cf.profile != "" {
+		if err := wcconfig.CheckProfileName(cf.profile); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+
 	// if about cmd line flag `-about` then print version info and exit
 	if cf.upgradecompleted {
 		fmt.Println("Upgrade completed.")
@@ -92,8 +351,116 @@ func (cf *cmdlineFlags) handleCmdLineFlags() {
 	}
 }
 
+// initLogging logs to stderr until the configuration is loaded (see configureLogging)
 func (ba *wcBotApp) initLogging() {
-	// Setup Log Formatter
-	log.SetFormatter(&log.TextFormatter{})
-	log.SetLevel(log.DebugLevel)
+	if err := wclog.Configure(wclog.Options{}); err != nil {
+		log.Errorf("wcBotApp.initLogging: %v", err)
+	}
+}
+
+// configureLogging applies the logging configuration (the `[log]` section). If it can not be
+// applied, logging continues to stderr and the error is reported by validateConfig.
+func (ba *wcBotApp) configureLogging() {
+	if err := wclog.Configure(ba.config.LogOptions()); err != nil {
+		log.Errorf("wcBotApp.configureLogging: %v", err)
+		return
+	}
+	if ba.config.Log.File != "" {
+		fmt.Fprintf(os.Stderr, "Logging to %s\n", ba.config.Log.File)
+	}
+}
+
+// runHealthCheck confirms that Telegram and the Manager are reachable and then exits.
+// The exit status is used by the running instance to decide if it is safe to upgrade to
+// this version (see updater.HealthCheck).
+func (ba *wcBotApp) runHealthCheck() {
+	bot, err := telegrambot.NewBot(ba.config, ba.state, nil)
+	if err == nil {
+		err = bot.CheckHealth()
+	}
+	if err != nil {
+		// The output is sent to the chat by the running instance (see Bot.reportRollback)
+		fmt.Printf("Health check failed: %s\n", ba.config.Redact(err.Error()))
+		os.Exit(1)
+	}
+	fmt.Printf("%s is healthy.\n", wcconst.BotAppVersion)
+	os.Exit(0)
+}
+
+// confirmUpgrade is called after restarting following an upgrade. The Bot must be created
+// and confirm it is healthy within the configured timeout, otherwise the upgrade is rolled back.
+// An error is only returned if the upgrade could not be rolled back.
+func (ba *wcBotApp) confirmUpgrade() (*telegrambot.Bot, error) {
+	timeout := ba.config.WingCommander.UpdateHealthTimeoutSec
+	deadline := time.Now().Add(timeout)
+
+	bot, err := telegrambot.NewBot(ba.config, ba.state, ba.audit)
+	if err != nil {
+		return nil, ba.rollbackUpgrade(err)
+	}
+
+	for {
+		err := bot.CheckHealth()
+		if err == nil {
+			log.Infof("wcBotApp.confirmUpgrade: Upgrade to %s confirmed healthy", wcconst.BotVersion)
+			if err := ba.state.SetUpgrade(nil); err != nil {
+				log.Errorf("wcBotApp.confirmUpgrade: Failed to clear upgrade state: %v", err)
+			}
+			return bot, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, ba.rollbackUpgrade(err)
+		}
+		log.Warnf("wcBotApp.confirmUpgrade: Not yet healthy (retrying): %v", err)
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// rollbackUpgrade restores the previous binary and restarts it. The reason for the rollback
+// is recorded in the State so that the previous version can report it once restarted.
+// It only returns (with an error) if the previous binary could not be restored and restarted.
+func (ba *wcBotApp) rollbackUpgrade(reason error) error {
+	log.Errorf("wcBotApp.rollbackUpgrade: Upgrade to %s is not healthy: %v", wcconst.BotVersion, reason)
+
+	upgrade := ba.state.GetUpgrade()
+	if upgrade == nil {
+		upgrade = &wcstate.UpgradeState{To: wcconst.BotVersion}
+	}
+	upgrade.RolledBack = true
+	// The reason is sent to the chat (see reportUpgrade), so must not contain secrets
+	upgrade.Reason = ba.config.Redact(reason.Error())
+	if err := ba.state.SetUpgrade(upgrade); err != nil {
+		log.Errorf("wcBotApp.rollbackUpgrade: Failed to record rollback: %v", err)
+	}
+
+	exe, err := updater.Executable()
+	if err == nil {
+		err = updater.Rollback(exe)
+	}
+	if err == nil {
+		err = updater.Restart(exe, updater.OriginalArgs())
+	}
+	return fmt.Errorf("wcBotApp.rollbackUpgrade: Failed to roll back: %v", err)
+}
+
+// reportUpgrade reports an upgrade which was rolled back (by the new version) to the Admin
+func (ba *wcBotApp) reportUpgrade(bot *telegrambot.Bot) {
+	upgrade := ba.state.GetUpgrade()
+	if upgrade == nil {
+		return
+	}
+
+	if upgrade.RolledBack {
+		msg := fmt.Sprintf(wcconst.MsgUpgradeRolledBack, upgrade.From, upgrade.To, ba.config.Redact(upgrade.Reason))
+		if err := bot.SendNewMessage("text", msg); err != nil {
+			log.Errorf("wcBotApp.reportUpgrade: Failed to send message: %v", err)
+		}
+	} else {
+		log.Warnf("wcBotApp.reportUpgrade: Upgrade from %s to %s did not complete", upgrade.From, upgrade.To)
+	}
+
+	if err := ba.state.SetUpgrade(nil); err != nil {
+		log.Errorf("wcBotApp.reportUpgrade: Failed to clear upgrade state: %v", err)
+	}
 }
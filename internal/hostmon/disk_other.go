@@ -0,0 +1,16 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package hostmon
+
+import "errors"
+
+// diskSpace is only supported on Linux
+func diskSpace(path string) (total, free uint64, err error) {
+	return 0, 0, errors.New("disk space monitoring is only supported on Linux")
+}
@@ -0,0 +1,265 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+// Package hostmon collects resource metrics (CPU load, memory, disk, temperature
+// and uptime) of the Linux host Wing Commander is running on (typically the
+// Skyminer Manager board) by parsing files under /proc and /sys.
+package hostmon
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metrics models the resource metrics of the host
+type Metrics struct {
+	// Load averages over 1, 5 and 15 minutes
+	Load1, Load5, Load15 float64
+	// Number of CPUs (cores)
+	CPUs int
+	// Memory in bytes
+	MemTotal, MemAvailable uint64
+	// Disk space in bytes of the filesystem containing the monitored path
+	DiskTotal, DiskFree uint64
+	// Highest temperature (°C) reported by the thermal zones
+	Temperature float64
+	// HasTemperature is false if the host does not provide any thermal zones
+	HasTemperature bool
+	// Time since the host booted
+	Uptime time.Duration
+}
+
+// LoadPerCPU returns the 1 minute load average divided by the number of CPUs
+func (m Metrics) LoadPerCPU() float64 {
+	if m.CPUs <= 0 {
+		return m.Load1
+	}
+	return m.Load1 / float64(m.CPUs)
+}
+
+// MemUsedPct returns the percentage of memory in use
+func (m Metrics) MemUsedPct() float64 {
+	if m.MemTotal == 0 {
+		return 0
+	}
+	return 100 * float64(m.MemTotal-m.MemAvailable) / float64(m.MemTotal)
+}
+
+// DiskFreePct returns the percentage of disk space which is free
+func (m Metrics) DiskFreePct() float64 {
+	if m.DiskTotal == 0 {
+		return 0
+	}
+	return 100 * float64(m.DiskFree) / float64(m.DiskTotal)
+}
+
+// String is the stringer function for the Metrics struct
+func (m Metrics) String() string {
+	temperature := "n/a"
+	if m.HasTemperature {
+		temperature = fmt.Sprintf("%.1f°C", m.Temperature)
+	}
+	return fmt.Sprintf("Load: %.2f %.2f %.2f (%d CPUs)\n"+
+		"Memory: %s of %s used (%.0f%%)\n"+
+		"Disk: %s of %s free (%.0f%%)\n"+
+		"Temperature: %s\n"+
+		"Uptime: %v",
+		m.Load1, m.Load5, m.Load15, m.CPUs,
+		formatBytes(m.MemTotal-m.MemAvailable), formatBytes(m.MemTotal), m.MemUsedPct(),
+		formatBytes(m.DiskFree), formatBytes(m.DiskTotal), m.DiskFreePct(),
+		temperature, m.Uptime)
+}
+
+// formatBytes renders a number of bytes using binary units
+func formatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+// Collector collects the Metrics of the host
+type Collector struct {
+	// Root is the directory /proc and /sys are located in ("/" except when testing)
+	Root string
+	// DiskPath is a path on the filesystem whose free space is monitored
+	DiskPath string
+}
+
+// NewCollector creates a Collector for the host which monitors the
+// free space of the filesystem containing diskpath
+func NewCollector(diskpath string) *Collector {
+	if diskpath == "" {
+		diskpath = "/"
+	}
+	return &Collector{Root: "/", DiskPath: diskpath}
+}
+
+// Collect reads the current Metrics of the host
+func (c *Collector) Collect() (Metrics, error) {
+	var m Metrics
+	var err error
+
+	if m.Load1, m.Load5, m.Load15, err = c.readLoadAvg(); err != nil {
+		return m, err
+	}
+	if m.CPUs, err = c.readCPUs(); err != nil {
+		return m, err
+	}
+	if m.MemTotal, m.MemAvailable, err = c.readMemInfo(); err != nil {
+		return m, err
+	}
+	if m.Uptime, err = c.readUptime(); err != nil {
+		return m, err
+	}
+	if m.DiskTotal, m.DiskFree, err = diskSpace(c.DiskPath); err != nil {
+		return m, fmt.Errorf("failed to get disk space of %s: %v", c.DiskPath, err)
+	}
+	m.Temperature, m.HasTemperature = c.readTemperature()
+	return m, nil
+}
+
+// path returns the location of the provided (absolute) host path under Root
+func (c *Collector) path(elem ...string) string {
+	return filepath.Join(append([]string{c.Root}, elem...)...)
+}
+
+// readLoadAvg parses /proc/loadavg
+func (c *Collector) readLoadAvg() (load1, load5, load15 float64, err error) {
+	data, err := ioutil.ReadFile(c.path("proc", "loadavg"))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return 0, 0, 0, fmt.Errorf("unexpected /proc/loadavg format: %q", string(data))
+	}
+
+	var loads [3]float64
+	for i := range loads {
+		if loads[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return 0, 0, 0, fmt.Errorf("unexpected /proc/loadavg format: %v", err)
+		}
+	}
+	return loads[0], loads[1], loads[2], nil
+}
+
+// readCPUs counts the per CPU (cpuN) lines of /proc/stat
+func (c *Collector) readCPUs() (int, error) {
+	f, err := os.Open(c.path("proc", "stat"))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	cpus := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "cpu") && len(line) > 3 && line[3] >= '0' && line[3] <= '9' {
+			cpus++
+		}
+	}
+	return cpus, scanner.Err()
+}
+
+// readMemInfo parses the total and available memory from /proc/meminfo
+func (c *Collector) readMemInfo() (total, available uint64, err error) {
+	f, err := os.Open(c.path("proc", "meminfo"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// i.e. `MemTotal:        1017984 kB`
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 2 && fields[2] == "kB" {
+			v *= 1024
+		}
+		values[strings.TrimSuffix(fields[0], ":")] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	total, ok := values["MemTotal"]
+	if !ok {
+		return 0, 0, fmt.Errorf("MemTotal not found in /proc/meminfo")
+	}
+	available, ok = values["MemAvailable"]
+	if !ok {
+		// Kernels prior to 3.14 do not provide MemAvailable
+		available = values["MemFree"] + values["Buffers"] + values["Cached"]
+	}
+	return total, available, nil
+}
+
+// readUptime parses /proc/uptime
+func (c *Collector) readUptime() (time.Duration, error) {
+	data, err := ioutil.ReadFile(c.path("proc", "uptime"))
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 1 {
+		return 0, fmt.Errorf("unexpected /proc/uptime format: %q", string(data))
+	}
+	secs, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected /proc/uptime format: %v", err)
+	}
+	return time.Duration(secs) * time.Second, nil
+}
+
+// readTemperature returns the highest temperature (°C) reported by the thermal zones
+// in /sys/class/thermal. Zones which can not be read are ignored.
+func (c *Collector) readTemperature() (float64, bool) {
+	zones, err := filepath.Glob(c.path("sys", "class", "thermal", "thermal_zone*", "temp"))
+	if err != nil {
+		return 0, false
+	}
+
+	var max float64
+	found := false
+	for _, zone := range zones {
+		data, err := ioutil.ReadFile(zone)
+		if err != nil {
+			continue
+		}
+		// Temperatures are reported in millidegrees Celsius
+		millic, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			continue
+		}
+		if t := float64(millic) / 1000; !found || t > max {
+			max, found = t, true
+		}
+	}
+	return max, found
+}
@@ -0,0 +1,114 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package hostmon
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+// newTestCollector creates a Collector which reads the provided fixture directory
+func newTestCollector(t *testing.T, fixture string) *Collector {
+	if runtime.GOOS != "linux" {
+		t.Skip("host monitoring is only supported on Linux")
+	}
+	return &Collector{Root: "testdata/" + fixture, DiskPath: "testdata"}
+}
+
+func Test_Collect(t *testing.T) {
+	m, err := newTestCollector(t, "host").Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Disk space is of the real filesystem
+	if m.DiskTotal == 0 {
+		t.Error("Expected: disk space of the filesystem containing testdata")
+	}
+	m.DiskTotal, m.DiskFree = 0, 0
+
+	expect := Metrics{
+		Load1: 0.52, Load5: 0.58, Load15: 0.59,
+		CPUs:           4,
+		MemTotal:       1017984 * 1024,
+		MemAvailable:   509000 * 1024,
+		Temperature:    51.75,
+		HasTemperature: true,
+		Uptime:         123456 * time.Second,
+	}
+	if diff := deep.Equal(m, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func Test_Collect_NoThermalZones(t *testing.T) {
+	m, err := newTestCollector(t, "nothermal").Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.HasTemperature {
+		t.Error("Expected: no temperature without thermal zones")
+	}
+	// MemAvailable is estimated on older kernels
+	if m.MemAvailable != (512000+256000+256000)*1024 {
+		t.Errorf("Unexpected MemAvailable: %d", m.MemAvailable)
+	}
+	if !strings.Contains(m.String(), "Temperature: n/a") {
+		t.Errorf("Unexpected String: %s", m.String())
+	}
+}
+
+func Test_Collect_Missing(t *testing.T) {
+	if _, err := newTestCollector(t, "does-not-exist").Collect(); err == nil {
+		t.Error("Expected: error collecting metrics without /proc")
+	}
+}
+
+func Test_Thresholds_Check(t *testing.T) {
+	m := Metrics{
+		Load1:          6,
+		CPUs:           4,
+		MemTotal:       1000,
+		MemAvailable:   50,
+		DiskTotal:      1000,
+		DiskFree:       20,
+		Temperature:    82.5,
+		HasTemperature: true,
+	}
+
+	var metrics []string
+	for _, b := range (Thresholds{MaxLoad: 1, MaxMemPct: 90, MinDiskFreePct: 5, MaxTempC: 80}).Check(m) {
+		metrics = append(metrics, b.Metric)
+	}
+	if diff := deep.Equal(metrics, []string{MetricLoad, MetricMemory, MetricDisk, MetricTemperature}); diff != nil {
+		t.Error(diff)
+	}
+
+	if breaches := (Thresholds{MaxLoad: 2, MaxMemPct: 99, MinDiskFreePct: 1, MaxTempC: 85}).Check(m); len(breaches) != 0 {
+		t.Errorf("Expected: no breaches, got %v", breaches)
+	}
+	if breaches := (Thresholds{}).Check(m); len(breaches) != 0 {
+		t.Errorf("Expected: disabled thresholds not to breach, got %v", breaches)
+	}
+}
+
+func Test_FormatBytes(t *testing.T) {
+	testCases := map[uint64]string{
+		512:                    "512 B",
+		1024:                   "1.0 KiB",
+		1536 * 1024:            "1.5 MiB",
+		3 * 1024 * 1024 * 1024: "3.0 GiB",
+	}
+	for b, expect := range testCases {
+		if s := formatBytes(b); s != expect {
+			t.Errorf("formatBytes(%d): expected %s, got %s", b, expect, s)
+		}
+	}
+}
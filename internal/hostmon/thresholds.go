@@ -0,0 +1,56 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package hostmon
+
+import "fmt"
+
+// Metric names identifying the Metrics a Breach relates to
+const (
+	MetricLoad        = "load"
+	MetricMemory      = "memory"
+	MetricDisk        = "disk"
+	MetricTemperature = "temperature"
+)
+
+// Thresholds defines the limits of the host Metrics. A zero limit disables its check.
+type Thresholds struct {
+	// MaxLoad is the maximum 1 minute load average per CPU
+	MaxLoad float64
+	// MaxMemPct is the maximum percentage of memory in use
+	MaxMemPct float64
+	// MinDiskFreePct is the minimum percentage of free disk space
+	MinDiskFreePct float64
+	// MaxTempC is the maximum temperature (°C)
+	MaxTempC float64
+}
+
+// Breach models a Metric which has exceeded its Threshold
+type Breach struct {
+	Metric  string
+	Message string
+}
+
+// Check returns a Breach for each of the provided Metrics which exceeds its Threshold
+func (t Thresholds) Check(m Metrics) []Breach {
+	var breaches []Breach
+	if t.MaxLoad > 0 && m.LoadPerCPU() > t.MaxLoad {
+		breaches = append(breaches, Breach{MetricLoad,
+			fmt.Sprintf("Load %.2f per CPU exceeds %.2f", m.LoadPerCPU(), t.MaxLoad)})
+	}
+	if t.MaxMemPct > 0 && m.MemUsedPct() > t.MaxMemPct {
+		breaches = append(breaches, Breach{MetricMemory,
+			fmt.Sprintf("Memory use %.0f%% exceeds %.0f%%", m.MemUsedPct(), t.MaxMemPct)})
+	}
+	if t.MinDiskFreePct > 0 && m.DiskTotal > 0 && m.DiskFreePct() < t.MinDiskFreePct {
+		breaches = append(breaches, Breach{MetricDisk,
+			fmt.Sprintf("Free disk space %.0f%% is below %.0f%%", m.DiskFreePct(), t.MinDiskFreePct)})
+	}
+	if t.MaxTempC > 0 && m.HasTemperature && m.Temperature > t.MaxTempC {
+		breaches = append(breaches, Breach{MetricTemperature,
+			fmt.Sprintf("Temperature %.1f°C exceeds %.1f°C", m.Temperature, t.MaxTempC)})
+	}
+	return breaches
+}
@@ -0,0 +1,176 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package netprobe
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultTimeout is used if no (valid) probe timeout is provided
+const defaultTimeout = 2 * time.Second
+
+// historySize is the number of recent probes the loss and average latency of a target is calculated over
+const historySize = 20
+
+// Status models the reachability of a probe target
+type Status struct {
+	// Target is the probed address (host:port or host)
+	Target string
+	// Host is the host part of Target
+	Host string
+	// Reachable reports the outcome of the last probe
+	Reachable bool
+	// Probed is false until the target has been probed
+	Probed bool
+	// Latency of the last successful probe
+	Latency time.Duration
+	// AvgLatency of the successful recent probes
+	AvgLatency time.Duration
+	// LossPct is the percentage of recent probes which failed
+	LossPct float64
+	// Err is the error of the last probe (if it failed)
+	Err error
+}
+
+// Event reports a change in the reachability of a probe target
+type Event struct {
+	Status
+}
+
+// target tracks the recent probes of an address
+type target struct {
+	address string
+	host    string
+	prober  Prober
+	history []time.Duration // 0 records a failed probe
+	last    Status
+}
+
+// status calculates the Status of the target from its recent probes
+func (t *target) status() Status {
+	s := t.last
+	var failed, ok int
+	var total time.Duration
+	for _, rtt := range t.history {
+		if rtt == 0 {
+			failed++
+			continue
+		}
+		ok++
+		total += rtt
+	}
+	if len(t.history) > 0 {
+		s.LossPct = 100 * float64(failed) / float64(len(t.history))
+	}
+	if ok > 0 {
+		s.AvgLatency = total / time.Duration(ok)
+	}
+	return s
+}
+
+// Monitor probes a set of targets and tracks their reachability
+type Monitor struct {
+	timeout time.Duration
+	targets []*target
+	m       sync.Mutex
+}
+
+// NewMonitor creates a Monitor for the provided targets (see ParseTarget)
+// which are considered unreachable if a probe does not complete within timeout
+func NewMonitor(targets []string, timeout time.Duration) (*Monitor, error) {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	mon := &Monitor{timeout: timeout}
+	for _, t := range targets {
+		host, prober, err := ParseTarget(t)
+		if err != nil {
+			return nil, err
+		}
+		mon.targets = append(mon.targets, &target{address: t, host: host, prober: prober,
+			last: Status{Target: t, Host: host}})
+	}
+	return mon, nil
+}
+
+// SetProber overrides the Prober used for every target (for testing)
+func (mon *Monitor) SetProber(p Prober) {
+	mon.m.Lock()
+	defer mon.m.Unlock()
+	for _, t := range mon.targets {
+		t.prober = p
+	}
+}
+
+// ProbeAll probes every target (concurrently) and returns an Event for each target whose
+// reachability has changed. The first probe of a target only produces an Event if it fails.
+func (mon *Monitor) ProbeAll() []Event {
+	type result struct {
+		rtt time.Duration
+		err error
+	}
+
+	mon.m.Lock()
+	targets := append([]*target(nil), mon.targets...)
+	mon.m.Unlock()
+
+	results := make([]result, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t *target) {
+			defer wg.Done()
+			rtt, err := t.prober.Probe(t.address, mon.timeout)
+			if err == nil && rtt <= 0 {
+				// Record a successful probe distinctly from a failed one
+				rtt = time.Nanosecond
+			}
+			results[i] = result{rtt, err}
+		}(i, t)
+	}
+	wg.Wait()
+
+	mon.m.Lock()
+	defer mon.m.Unlock()
+
+	var events []Event
+	for i, t := range targets {
+		r := results[i]
+		prev := t.last
+
+		var rtt time.Duration
+		t.last.Err = r.err
+		t.last.Reachable = r.err == nil
+		if t.last.Reachable {
+			rtt = r.rtt
+			t.last.Latency = r.rtt
+		}
+		t.last.Probed = true
+
+		t.history = append(t.history, rtt)
+		if len(t.history) > historySize {
+			t.history = t.history[len(t.history)-historySize:]
+		}
+
+		if prev.Probed && prev.Reachable != t.last.Reachable || !prev.Probed && !t.last.Reachable {
+			events = append(events, Event{t.status()})
+		}
+	}
+	return events
+}
+
+// Statuses returns the Status of every target (in the order they were configured)
+func (mon *Monitor) Statuses() []Status {
+	mon.m.Lock()
+	defer mon.m.Unlock()
+
+	var statuses []Status
+	for _, t := range mon.targets {
+		statuses = append(statuses, t.status())
+	}
+	return statuses
+}
@@ -0,0 +1,153 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package netprobe
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+// fakeProber reports the configured addresses as unreachable
+type fakeProber struct {
+	m    sync.Mutex
+	down map[string]bool
+}
+
+func (p *fakeProber) Probe(address string, timeout time.Duration) (time.Duration, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if p.down[address] {
+		return 0, errors.New("connection refused")
+	}
+	return 5 * time.Millisecond, nil
+}
+
+func (p *fakeProber) setDown(address string, down bool) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.down[address] = down
+}
+
+func Test_TCPProber(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+
+	if _, err := (TCPProber{}).Probe(addr, time.Second); err != nil {
+		t.Errorf("Expected: %s to be reachable: %v", addr, err)
+	}
+
+	l.Close()
+	if _, err := (TCPProber{}).Probe(addr, time.Second); err == nil {
+		t.Errorf("Expected: %s to be unreachable once closed", addr)
+	}
+}
+
+func Test_ICMPProber(t *testing.T) {
+	_, err := (ICMPProber{}).Probe("127.0.0.1", time.Second)
+	if err == ErrICMPNotPermitted {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_MarshalEcho(t *testing.T) {
+	msg := marshalEcho(0x1234, 1, []byte("ab"))
+	if diff := deep.Equal(msg, []byte{8, 0, 0x84, 0x68, 0x12, 0x34, 0x00, 0x01, 'a', 'b'}); diff != nil {
+		t.Error(diff)
+	}
+	// A message including its checksum sums to zero
+	if c := checksum(msg); c != 0 {
+		t.Errorf("Expected: checksum of 0, got %#x", c)
+	}
+
+	reply := append([]byte(nil), msg...)
+	reply[0] = icmpEchoReply
+	if !isEchoReply(reply, 0x1234, 1) {
+		t.Error("Expected: reply to match the request")
+	}
+	if isEchoReply(reply, 0x1234, 2) || isEchoReply(msg, 0x1234, 1) {
+		t.Error("Expected: only the reply to match the request")
+	}
+}
+
+func Test_ParseTarget(t *testing.T) {
+	testCases := []struct {
+		target string
+		host   string
+		prober Prober
+	}{
+		{"192.168.0.2:8000", "192.168.0.2", TCPProber{}},
+		{"192.168.0.2", "192.168.0.2", ICMPProber{}},
+		{"node1.local:22", "node1.local", TCPProber{}},
+	}
+	for _, tc := range testCases {
+		host, prober, err := ParseTarget(tc.target)
+		if err != nil {
+			t.Errorf("%s: %v", tc.target, err)
+			continue
+		}
+		if host != tc.host || prober != tc.prober {
+			t.Errorf("%s: unexpected host %s or prober %T", tc.target, host, prober)
+		}
+	}
+
+	for _, target := range []string{"", ":8000", "192.168.0.2:"} {
+		if _, _, err := ParseTarget(target); err == nil {
+			t.Errorf("Expected: %q to be invalid", target)
+		}
+	}
+}
+
+func Test_Monitor_Events(t *testing.T) {
+	mon, err := NewMonitor([]string{"192.168.0.2:8000", "192.168.0.3:8000"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp := &fakeProber{down: map[string]bool{"192.168.0.3:8000": true}}
+	mon.SetProber(fp)
+
+	// Only the initially unreachable target is reported
+	events := mon.ProbeAll()
+	if len(events) != 1 || events[0].Target != "192.168.0.3:8000" || events[0].Reachable {
+		t.Fatalf("Unexpected events: %+v", events)
+	}
+
+	// No change
+	if events := mon.ProbeAll(); len(events) != 0 {
+		t.Fatalf("Unexpected events: %+v", events)
+	}
+
+	fp.setDown("192.168.0.2:8000", true)
+	fp.setDown("192.168.0.3:8000", false)
+	events = mon.ProbeAll()
+	if len(events) != 2 {
+		t.Fatalf("Expected: 2 events, got %+v", events)
+	}
+	if events[0].Target != "192.168.0.2:8000" || events[0].Reachable || events[0].Err == nil {
+		t.Errorf("Unexpected event: %+v", events[0])
+	}
+	if events[1].Target != "192.168.0.3:8000" || !events[1].Reachable || events[1].Latency != 5*time.Millisecond {
+		t.Errorf("Unexpected event: %+v", events[1])
+	}
+
+	statuses := mon.Statuses()
+	if statuses[0].LossPct != 100.0/3 || statuses[0].AvgLatency != 5*time.Millisecond {
+		t.Errorf("Unexpected status: %+v", statuses[0])
+	}
+	if statuses[1].LossPct != 200.0/3 || statuses[1].Host != "192.168.0.3" {
+		t.Errorf("Unexpected status: %+v", statuses[1])
+	}
+}
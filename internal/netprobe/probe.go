@@ -0,0 +1,147 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+// Package netprobe checks that Nodes are reachable on the LAN by periodically
+// probing them using TCP connects or ICMP echo requests (pings).
+package netprobe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Prober checks if an address is reachable and returns the round trip time
+type Prober interface {
+	Probe(address string, timeout time.Duration) (time.Duration, error)
+}
+
+// TCPProber probes a host:port address by establishing (and closing) a TCP connection
+type TCPProber struct{}
+
+// Probe connects to the provided host:port address
+func (TCPProber) Probe(address string, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+	conn.Close()
+	return rtt, nil
+}
+
+// ErrICMPNotPermitted is returned by ICMPProber when the process is not permitted to
+// send ICMP packets (which requires root, or the CAP_NET_RAW capability on Linux)
+var ErrICMPNotPermitted = errors.New("not permitted to send ICMP (requires root or CAP_NET_RAW)")
+
+const (
+	icmpEchoRequest = 8
+	icmpEchoReply   = 0
+)
+
+// icmpSeq provides the sequence numbers of ICMP echo requests
+var icmpSeq uint32
+
+// ICMPProber probes a host by sending an ICMP echo request (IPv4 only)
+type ICMPProber struct{}
+
+// Probe sends an ICMP echo request to the provided host and waits for the reply
+func (ICMPProber) Probe(host string, timeout time.Duration) (time.Duration, error) {
+	conn, err := net.DialTimeout("ip4:icmp", host, timeout)
+	if err != nil {
+		if isPermission(err) {
+			return 0, ErrICMPNotPermitted
+		}
+		return 0, err
+	}
+	defer conn.Close()
+
+	id := uint16(os.Getpid())
+	seq := uint16(atomic.AddUint32(&icmpSeq, 1))
+	start := time.Now()
+	if err := conn.SetDeadline(start.Add(timeout)); err != nil {
+		return 0, err
+	}
+	if _, err := conn.Write(marshalEcho(id, seq, []byte("wingcommander"))); err != nil {
+		if isPermission(err) {
+			return 0, ErrICMPNotPermitted
+		}
+		return 0, err
+	}
+
+	// The socket receives every ICMP packet from the host, so wait for our reply.
+	// Unlike Read, ReadFrom strips the IPv4 header.
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.(*net.IPConn).ReadFrom(buf)
+		if err != nil {
+			return 0, err
+		}
+		if isEchoReply(buf[:n], id, seq) {
+			return time.Since(start), nil
+		}
+	}
+}
+
+// isPermission determines if the provided (network) error is due to a lack of permission
+func isPermission(err error) bool {
+	if operr, ok := err.(*net.OpError); ok {
+		err = operr.Err
+	}
+	return os.IsPermission(err)
+}
+
+// marshalEcho builds an ICMP echo request message
+func marshalEcho(id, seq uint16, payload []byte) []byte {
+	msg := make([]byte, 8+len(payload))
+	msg[0] = icmpEchoRequest
+	binary.BigEndian.PutUint16(msg[4:], id)
+	binary.BigEndian.PutUint16(msg[6:], seq)
+	copy(msg[8:], payload)
+	binary.BigEndian.PutUint16(msg[2:], checksum(msg))
+	return msg
+}
+
+// isEchoReply determines if the provided ICMP message is the reply to the echo request identified by id and seq
+func isEchoReply(msg []byte, id, seq uint16) bool {
+	return len(msg) >= 8 && msg[0] == icmpEchoReply &&
+		binary.BigEndian.Uint16(msg[4:]) == id && binary.BigEndian.Uint16(msg[6:]) == seq
+}
+
+// checksum calculates the internet checksum (RFC 1071) of the provided message
+func checksum(msg []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(msg); i += 2 {
+		sum += uint32(msg[i])<<8 | uint32(msg[i+1])
+	}
+	if len(msg)%2 == 1 {
+		sum += uint32(msg[len(msg)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// ParseTarget determines how the provided target is probed. A `host:port` target is
+// probed using a TCP connect, while a target without a port is probed using ICMP.
+// The host of the target is also returned.
+func ParseTarget(target string) (host string, prober Prober, err error) {
+	if target == "" {
+		return "", nil, fmt.Errorf("empty probe target")
+	}
+	if h, port, err := net.SplitHostPort(target); err == nil {
+		if h == "" || port == "" {
+			return "", nil, fmt.Errorf("invalid probe target %q", target)
+		}
+		return h, TCPProber{}, nil
+	}
+	return target, ICMPProber{}, nil
+}
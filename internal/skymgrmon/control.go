@@ -0,0 +1,293 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package skymgrmon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/skynode"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	managerAPIGetNode      = "conn/getNode"
+	managerAPIGetNodeApps  = "node/getApps"
+	managerAPIGetNodeInfo  = "node/getInfo"
+	managerAPIRebootNode   = "node/reboot"
+	managerAPICloseNodeApp = "node/run/closeApp"
+	managerAPIRunNodeApp   = "node/run/%s"
+)
+
+// ErrAppNotRunning is returned when stopping an app which is not running on the Node
+var ErrAppNotRunning = errors.New("app is not running on the node")
+
+// SupportedApps lists the Skywire apps which can be started, stopped
+// and restarted on a Node through the Manager
+var SupportedApps = []string{"sshs", "sockss"}
+
+// IsSupportedApp determines if the provided Skywire app name is supported
+func IsSupportedApp(app string) bool {
+	for _, v := range SupportedApps {
+		if v == app {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeAddress models the JSON response from the Manager /conn/getNode API
+type nodeAddress struct {
+	Addr string `json:"addr"`
+}
+
+// NodeApp models an app running on a Node (JSON response from the Manager /node/getApps API)
+type NodeApp struct {
+	Key        string   `json:"key"`
+	Attributes []string `json:"attributes"`
+}
+
+// NodeSoftware models the Skywire software details reported by a Node
+// (subset of the JSON response from the Manager /node/getInfo API)
+type NodeSoftware struct {
+	Version string `json:"version"`
+	Tag     string `json:"tag"`
+	OS      string `json:"os"`
+}
+
+// managerPost performs a POST request against the Manager API and returns the response body
+func managerPost(managerAddr, endpoint string, form url.Values) ([]byte, error) {
+	log.Debugf("SkyManagerMonitor.managerPost: %s %v", endpoint, form)
+
+	client := &http.Client{}
+	client.Timeout = time.Second * 30
+	apiURL := fmt.Sprintf("http://%s/%s", managerAddr, endpoint)
+
+	req, err := http.NewRequest(http.MethodPost, apiURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Wing Commander Telegram Bot "+wcconst.BotVersion)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respbuf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return respbuf, fmt.Errorf("manager API %s returned %s: %s", endpoint, resp.Status, strings.TrimSpace(string(respbuf)))
+	}
+	return respbuf, nil
+}
+
+// GetAllNodes requests the list of Nodes connected to the Manager
+func (smm *SkyManagerMonitor) GetAllNodes() (skynode.NodeInfoSlice, error) {
+	return getAllNodesList(context.Background(), smm.ManagerAddress)
+}
+
+// MinNodeKeyPrefixLen is the minimum number of characters of an abbreviated Node key
+// (see FindNodeKey), so a Node is not controlled by mistake using a very short prefix
+const MinNodeKeyPrefixLen = 8
+
+// FindNodeKey resolves a (possibly abbreviated) Node key to the full key of a Node
+// connected to the Manager. An error is returned if the key is unknown or ambiguous,
+// or is abbreviated to fewer than MinNodeKeyPrefixLen characters.
+func (smm *SkyManagerMonitor) FindNodeKey(prefix string) (string, error) {
+	if prefix == "" {
+		return "", fmt.Errorf("no node key provided")
+	}
+	if len(prefix) < MinNodeKeyPrefixLen {
+		return "", fmt.Errorf("node key %s is too short (at least %d characters are required)", prefix, MinNodeKeyPrefixLen)
+	}
+
+	nodes, err := smm.GetAllNodes()
+	if err != nil {
+		return "", err
+	}
+
+	var found []string
+	for _, n := range nodes {
+		if strings.HasPrefix(n.Key, prefix) {
+			found = append(found, n.Key)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("node %s is not connected to the manager", prefix)
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("node key %s is ambiguous (%d nodes)", prefix, len(found))
+	}
+}
+
+// getNodeAddr requests the address of the Node identified by key from the Manager
+func (smm *SkyManagerMonitor) getNodeAddr(key string) (string, error) {
+	respbuf, err := managerPost(smm.ManagerAddress, managerAPIGetNode, url.Values{"key": {key}})
+	if err != nil {
+		return "", err
+	}
+
+	var na nodeAddress
+	if err := json.Unmarshal(respbuf, &na); err != nil {
+		return "", err
+	}
+	if na.Addr == "" {
+		return "", fmt.Errorf("manager did not provide an address for node %s", key)
+	}
+	return na.Addr, nil
+}
+
+// GetNodeAddress requests the address (IP:port) the Node identified by key is connected to the Manager from
+func (smm *SkyManagerMonitor) GetNodeAddress(key string) (string, error) {
+	return smm.getNodeAddr(key)
+}
+
+// nodeAction resolves the address of the Node identified by key and performs
+// a POST against the provided Manager API endpoint for that Node
+func (smm *SkyManagerMonitor) nodeAction(key, endpoint string, form url.Values) ([]byte, error) {
+	addr, err := smm.getNodeAddr(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if form == nil {
+		form = url.Values{}
+	}
+	form.Set("addr", addr)
+	return managerPost(smm.ManagerAddress, endpoint, form)
+}
+
+// checkResult interprets the response from a Manager API call which returns `true` on success
+func checkResult(endpoint string, respbuf []byte) error {
+	if strings.TrimSpace(string(respbuf)) != "true" {
+		return fmt.Errorf("manager API %s failed: %s", endpoint, strings.TrimSpace(string(respbuf)))
+	}
+	return nil
+}
+
+// GetNodeApps requests the list of apps running on the Node identified by key
+func (smm *SkyManagerMonitor) GetNodeApps(key string) ([]NodeApp, error) {
+	respbuf, err := smm.nodeAction(key, managerAPIGetNodeApps, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var apps []NodeApp
+	if err := json.Unmarshal(respbuf, &apps); err != nil {
+		return nil, err
+	}
+	return apps, nil
+}
+
+// GetNodeSoftware requests the Skywire software details of the Node identified by key
+func (smm *SkyManagerMonitor) GetNodeSoftware(key string) (NodeSoftware, error) {
+	var sw NodeSoftware
+	respbuf, err := smm.nodeAction(key, managerAPIGetNodeInfo, nil)
+	if err != nil {
+		return sw, err
+	}
+
+	err = json.Unmarshal(respbuf, &sw)
+	return sw, err
+}
+
+// RestartNode requests the Manager to restart (reboot) the Node identified by key
+func (smm *SkyManagerMonitor) RestartNode(key string) error {
+	log.Infof("SkyManagerMonitor.RestartNode: %s", key)
+	respbuf, err := smm.nodeAction(key, managerAPIRebootNode, nil)
+	if err != nil {
+		return err
+	}
+	return checkResult(managerAPIRebootNode, respbuf)
+}
+
+// RebootAllNodes requests the Manager to restart (reboot) every connected Node.
+// The number of Nodes successfully rebooted is returned, along with the last error encountered.
+func (smm *SkyManagerMonitor) RebootAllNodes() (int, error) {
+	nodes, err := smm.GetAllNodes()
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	var lasterr error
+	for _, n := range nodes {
+		if err := smm.RestartNode(n.Key); err != nil {
+			log.Errorf("SkyManagerMonitor.RebootAllNodes: Failed to reboot %s: %v", n.Key, err)
+			lasterr = err
+			continue
+		}
+		count++
+	}
+	return count, lasterr
+}
+
+// StartApp requests the Manager to start the provided app on the Node identified by key
+func (smm *SkyManagerMonitor) StartApp(key, app string) error {
+	log.Infof("SkyManagerMonitor.StartApp: %s %s", key, app)
+	if !IsSupportedApp(app) {
+		return fmt.Errorf("unsupported app %s", app)
+	}
+
+	endpoint := fmt.Sprintf(managerAPIRunNodeApp, app)
+	respbuf, err := smm.nodeAction(key, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return checkResult(endpoint, respbuf)
+}
+
+// StopApp requests the Manager to stop the provided app on the Node identified by key
+func (smm *SkyManagerMonitor) StopApp(key, app string) error {
+	log.Infof("SkyManagerMonitor.StopApp: %s %s", key, app)
+	if !IsSupportedApp(app) {
+		return fmt.Errorf("unsupported app %s", app)
+	}
+
+	apps, err := smm.GetNodeApps(key)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range apps {
+		for _, attr := range a.Attributes {
+			if attr == app {
+				respbuf, err := smm.nodeAction(key, managerAPICloseNodeApp, url.Values{"key": {a.Key}})
+				if err != nil {
+					return err
+				}
+				return checkResult(managerAPICloseNodeApp, respbuf)
+			}
+		}
+	}
+	return ErrAppNotRunning
+}
+
+// RestartApp requests the Manager to stop and then start the provided app on the
+// Node identified by key. An app which is not running is simply started.
+func (smm *SkyManagerMonitor) RestartApp(key, app string) error {
+	if err := smm.StopApp(key, app); err != nil && err != ErrAppNotRunning {
+		return err
+	}
+	return smm.StartApp(key, app)
+}
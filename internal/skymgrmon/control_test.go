@@ -0,0 +1,186 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package skymgrmon
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+const (
+	testNodeKey1 = "02a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90"
+	testNodeKey2 = "03ffeeddccbbaa99887766554433221100ffeeddccbbaa99887766554433221100"
+)
+
+// fakeManager is a fake Skywire Manager which records the actions requested of it
+type fakeManager struct {
+	m       sync.Mutex
+	actions []string
+	apps    map[string]bool
+}
+
+func newFakeManager() (*fakeManager, *httptest.Server) {
+	fm := &fakeManager{apps: map[string]bool{"sockss": true}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/conn/getAll", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"key":%q,"type":"TCP"},{"key":%q,"type":"TCP"}]`, testNodeKey1, testNodeKey2)
+	})
+	mux.HandleFunc("/conn/getNode", func(w http.ResponseWriter, r *http.Request) {
+		switch r.FormValue("key") {
+		case testNodeKey1:
+			fmt.Fprint(w, `{"addr":"192.168.0.2:5000"}`)
+		case testNodeKey2:
+			fmt.Fprint(w, `{"addr":"192.168.0.3:5000"}`)
+		default:
+			http.Error(w, "unknown node", http.StatusNotFound)
+		}
+	})
+	mux.HandleFunc("/node/getApps", func(w http.ResponseWriter, r *http.Request) {
+		fm.m.Lock()
+		defer fm.m.Unlock()
+		var apps []string
+		for app, running := range fm.apps {
+			if running {
+				apps = append(apps, fmt.Sprintf(`{"key":"%s-key","attributes":[%q]}`, app, app))
+			}
+		}
+		fmt.Fprintf(w, "[%s]", strings.Join(apps, ","))
+	})
+	mux.HandleFunc("/node/getInfo", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"version":"0.1.0","tag":"dev","os":"linux","start_time":60}`)
+	})
+	mux.HandleFunc("/node/", func(w http.ResponseWriter, r *http.Request) {
+		fm.m.Lock()
+		defer fm.m.Unlock()
+		action := strings.TrimPrefix(r.URL.Path, "/node/")
+		fm.actions = append(fm.actions, action+"@"+r.FormValue("addr"))
+		switch action {
+		case "run/closeApp":
+			fm.apps[strings.TrimSuffix(r.FormValue("key"), "-key")] = false
+		case "run/sshs", "run/sockss":
+			fm.apps[strings.TrimPrefix(action, "run/")] = true
+		}
+		fmt.Fprint(w, "true")
+	})
+	return fm, httptest.NewServer(mux)
+}
+
+func (fm *fakeManager) getActions() []string {
+	fm.m.Lock()
+	defer fm.m.Unlock()
+	return append([]string(nil), fm.actions...)
+}
+
+func Test_FindNodeKey(t *testing.T) {
+	_, server := newFakeManager()
+	defer server.Close()
+	monitor := NewMonitor(server.Listener.Addr().String(), "")
+
+	key, err := monitor.FindNodeKey("02a1b2c3")
+	if err != nil || key != testNodeKey1 {
+		t.Errorf("Unexpected result: %s %v", key, err)
+	}
+	if key, err := monitor.FindNodeKey(testNodeKey2); err != nil || key != testNodeKey2 {
+		t.Errorf("Unexpected result: %s %v", key, err)
+	}
+
+	if _, err := monitor.FindNodeKey("02a1b2"); err == nil {
+		t.Error("Expected: Short key prefix should fail")
+	}
+	if _, err := monitor.FindNodeKey("04a1b2c3"); err == nil {
+		t.Error("Expected: Unknown key prefix should fail")
+	}
+}
+
+func Test_RestartNode(t *testing.T) {
+	fm, server := newFakeManager()
+	defer server.Close()
+	monitor := NewMonitor(server.Listener.Addr().String(), "")
+
+	if err := monitor.RestartNode(testNodeKey1); err != nil {
+		t.Error(err)
+	}
+	if actions := fm.getActions(); len(actions) != 1 || actions[0] != "reboot@192.168.0.2:5000" {
+		t.Errorf("Unexpected actions: %v", actions)
+	}
+
+	if err := monitor.RestartNode("unknown"); err == nil {
+		t.Error("Expected: Restarting an unknown node should fail")
+	}
+}
+
+func Test_GetNodeSoftware(t *testing.T) {
+	_, server := newFakeManager()
+	defer server.Close()
+	monitor := NewMonitor(server.Listener.Addr().String(), "")
+
+	sw, err := monitor.GetNodeSoftware(testNodeKey1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(sw, NodeSoftware{Version: "0.1.0", Tag: "dev", OS: "linux"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if _, err := monitor.GetNodeSoftware("unknown"); err == nil {
+		t.Error("Expected: Getting the software of an unknown node should fail")
+	}
+}
+
+func Test_RebootAllNodes(t *testing.T) {
+	fm, server := newFakeManager()
+	defer server.Close()
+	monitor := NewMonitor(server.Listener.Addr().String(), "")
+
+	count, err := monitor.RebootAllNodes()
+	if err != nil {
+		t.Error(err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 nodes to be rebooted, got %d", count)
+	}
+	if actions := fm.getActions(); len(actions) != 2 {
+		t.Errorf("Unexpected actions: %v", actions)
+	}
+}
+
+func Test_StartStopRestartApp(t *testing.T) {
+	fm, server := newFakeManager()
+	defer server.Close()
+	monitor := NewMonitor(server.Listener.Addr().String(), "")
+
+	if err := monitor.StopApp(testNodeKey1, "sshs"); err != ErrAppNotRunning {
+		t.Errorf("Expected ErrAppNotRunning, got %v", err)
+	}
+	if err := monitor.StartApp(testNodeKey1, "sshs"); err != nil {
+		t.Error(err)
+	}
+	if err := monitor.StopApp(testNodeKey1, "sshs"); err != nil {
+		t.Error(err)
+	}
+	if err := monitor.RestartApp(testNodeKey1, "sockss"); err != nil {
+		t.Error(err)
+	}
+	if err := monitor.StartApp(testNodeKey1, "unknownapp"); err == nil {
+		t.Error("Expected: Starting an unsupported app should fail")
+	}
+
+	expect := []string{
+		"run/sshs@192.168.0.2:5000",
+		"run/closeApp@192.168.0.2:5000",
+		"run/closeApp@192.168.0.2:5000",
+		"run/sockss@192.168.0.2:5000",
+	}
+	actions := fm.getActions()
+	if strings.Join(actions, ",") != strings.Join(expect, ",") {
+		t.Errorf("Unexpected actions: %v", actions)
+	}
+}
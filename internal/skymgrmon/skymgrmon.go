@@ -35,6 +35,8 @@ type SkyManagerMonitor struct {
 	m                    sync.Mutex
 	updateStarted        bool
 	updateMsgChan        chan string
+	// stopped is closed once RunManagerMonitor has returned
+	stopped chan struct{}
 }
 
 // SetCancelFunc is a thread-safe function for setting the cancelFunc
@@ -63,6 +65,22 @@ func (smm *SkyManagerMonitor) DoCancelFunc() {
 	}
 }
 
+// SetDiscoveryAddress is a thread-safe function for setting the
+// DiscoveryAddress (i.e. when the configuration is reloaded)
+func (smm *SkyManagerMonitor) SetDiscoveryAddress(address string) {
+	smm.m.Lock()
+	defer smm.m.Unlock()
+	smm.DiscoveryAddress = address
+}
+
+// GetDiscoveryAddress is a thread-safe function for accessing (getting) the
+// DiscoveryAddress on the SkyManagerMonitor struct
+func (smm *SkyManagerMonitor) GetDiscoveryAddress() string {
+	smm.m.Lock()
+	defer smm.m.Unlock()
+	return smm.DiscoveryAddress
+}
+
 // GetUpdateStarted is a thread-safe function for checking if the
 // updateStarted flag has been set
 func (smm *SkyManagerMonitor) GetUpdateStarted() bool {
@@ -95,26 +113,45 @@ func NewMonitor(manageraddress, discoveryaddress string) *SkyManagerMonitor {
 
 // RunManagerMonitor starts the SkyManagerMonitor monitoring of the local Manager Node.
 // If `ctx` is not nil, the monitor will listen to ctx.Done() and stop monitoring
-// when it receives the signal.
+// when it receives the signal. statusMsgChan is closed once the monitor has stopped.
 func (smm *SkyManagerMonitor) RunManagerMonitor(runctx context.Context, doCancelFunc func(), statusMsgChan chan<- string, pollInt time.Duration) {
 	log.Debugf("SkyManagerMonitor.RunManagerMonitor: Start (Interval: %v)", pollInt)
 	defer log.Debugln("SkyManagerMonitor.RunManagerMonitor: End")
 
-	smm.SetCancelFunc(doCancelFunc)
+	stopped := make(chan struct{})
+	smm.m.Lock()
+	smm.cancelFunc = doCancelFunc
 	smm.monitorStatusMsgChan = statusMsgChan
+	smm.stopped = stopped
+	smm.m.Unlock()
+	// The status channel is only closed here, once nothing more can be sent on it
+	defer func() {
+		close(statusMsgChan)
+		close(stopped)
+	}()
 
 	ticker := time.NewTicker(pollInt)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			newcns, err := getAllNodesList(smm.ManagerAddress)
+			var msgs []string
+			newcns, err := getAllNodesList(runctx, smm.ManagerAddress)
 			if err != nil {
 				log.Error(err)
-				statusMsgChan <- wcconst.MsgErrorGetNodes
+				msgs = []string{wcconst.MsgErrorGetNodes}
 			} else {
 				// Maintain the list of connected nodes
-				smm.maintainConnectedNodesList(newcns, statusMsgChan)
+				msgs = smm.maintainConnectedNodesList(newcns)
+			}
+			for _, msg := range msgs {
+				select {
+				case statusMsgChan <- msg:
+				case <-runctx.Done():
+					log.Debugln("SkyManagerMonitor.RunManagerMonitor: Done Event.")
+					return
+				}
 			}
 		case <-runctx.Done():
 			log.Debugln("SkyManagerMonitor.RunManagerMonitor: Done Event.")
@@ -131,10 +168,20 @@ func (smm *SkyManagerMonitor) StopManagerMonitor() {
 	defer log.Debugln("SkyManagerMonitor.StopManagerMonitor: End")
 
 	if smm.IsRunning() {
+		smm.m.Lock()
+		stopped := smm.stopped
+		smm.m.Unlock()
+
 		smm.DoCancelFunc()
 		smm.SetCancelFunc(nil)
-		close(smm.monitorStatusMsgChan)
+		// Wait for the monitor to stop (it closes the status channel), so it can be restarted
+		if stopped != nil {
+			<-stopped
+		}
+		smm.m.Lock()
 		smm.monitorStatusMsgChan = nil
+		smm.stopped = nil
+		smm.m.Unlock()
 		log.Debug(wcconst.MsgMonitorStopped)
 	}
 }
@@ -152,7 +199,7 @@ func (smm *SkyManagerMonitor) RunDiscoveryMonitor(runctx context.Context, status
 	for {
 		select {
 		case <-ticker.C:
-			discNodes, err := getAllNodesList(smm.ManagerAddress)
+			discNodes, err := getAllNodesList(runctx, smm.ManagerAddress)
 			if err != nil {
 				log.Error(err)
 				statusMsgChan <- wcconst.MsgErrorGetDiscNodes
@@ -181,7 +228,7 @@ func (smm *SkyManagerMonitor) ConnectedDiscNodeCount() (int, error) {
 		return discConnNodeCount, nil
 	}
 
-	discNodes, err := getAllNodesList(smm.DiscoveryAddress)
+	discNodes, err := getAllNodesList(context.Background(), smm.GetDiscoveryAddress())
 	if err != nil {
 		log.Errorf("SkyManagerMonitor.ConnectedDiscNodeCount: Error contacting Discovery Server: %v", err)
 		return discConnNodeCount, err
@@ -243,8 +290,9 @@ func (smm *SkyManagerMonitor) getAllNodesStr() string {
 }
 */
 
-// getAllNodesList requests the list of connected Nodes from the Manager and returns an array (slice) of connectedNode
-func getAllNodesList(managerAddr string) (cns skynode.NodeInfoSlice, err error) {
+// getAllNodesList requests the list of connected Nodes from the Manager and returns an array (slice) of connectedNode.
+// The request is abandoned if ctx is done (i.e. the monitor is stopped).
+func getAllNodesList(ctx context.Context, managerAddr string) (cns skynode.NodeInfoSlice, err error) {
 	log.Debug("SkyManagerMonitor.getAllNodesList: Start")
 	defer log.Debug("SkyManagerMonitor.getAllNodesList: End")
 
@@ -262,7 +310,7 @@ func getAllNodesList(managerAddr string) (cns skynode.NodeInfoSlice, err error)
 	}
 	req.Header.Set("User-Agent", userAgent)
 
-	resp, err := client.Do(req)
+	resp, err := client.Do(req.WithContext(ctx))
 	if err != nil {
 		log.Errorf("client.Do() failed with '%s'\n", err)
 		return
@@ -283,9 +331,9 @@ func getAllNodesList(managerAddr string) (cns skynode.NodeInfoSlice, err error)
 }
 
 // maintainConnectedNodeList is responsible for maintaining (adding, updating and deleting) Nodes from the
-// Monitors internal connectedNodeList.
-// TODO: Support use of channels for signaling of change events
-func (smm *SkyManagerMonitor) maintainConnectedNodesList(newcns skynode.NodeInfoSlice, statusMsgChan chan<- string) {
+// Monitors internal connectedNodeList. It returns the status messages describing the changes, which are
+// sent by the caller (they are not sent while holding the lock).
+func (smm *SkyManagerMonitor) maintainConnectedNodesList(newcns skynode.NodeInfoSlice) (msgs []string) {
 	log.Debug("SkyManagerMonitor.maintainConnectedNodesList: Start")
 	defer log.Debug("SkyManagerMonitor.maintainConnectedNodesList: End")
 
@@ -313,7 +361,7 @@ func (smm *SkyManagerMonitor) maintainConnectedNodesList(newcns skynode.NodeInfo
 			smm.connectedNodes[v.Key] = v
 			msg := fmt.Sprintf(wcconst.MsgNodeConnected, v.Key, len(smm.connectedNodes))
 			log.Debugln(msg)
-			statusMsgChan <- msg
+			msgs = append(msgs, msg)
 		}
 	}
 
@@ -333,10 +381,11 @@ func (smm *SkyManagerMonitor) maintainConnectedNodesList(newcns skynode.NodeInfo
 				delete(smm.connectedNodes, v.Key)
 				msg := fmt.Sprintf(wcconst.MsgNodeDisconnected, v.Key, len(smm.connectedNodes))
 				log.Debugln(msg)
-				statusMsgChan <- msg
+				msgs = append(msgs, msg)
 			}
 		}
 	}
+	return msgs
 }
 
 /*
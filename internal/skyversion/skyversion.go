@@ -0,0 +1,141 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+// Package skyversion compares the Skywire software versions reported by the Nodes
+// of a Skyminer against each other, and against the latest upstream release.
+package skyversion
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/github"
+	version "github.com/hashicorp/go-version"
+)
+
+// Source provides the latest released version of the Skywire software
+type Source interface {
+	LatestVersion() (string, error)
+}
+
+// GitHubSource is a Source which provides the tag of the latest (non pre-release)
+// release of a GitHub repository
+type GitHubSource struct {
+	Owner string
+	Repo  string
+
+	client *github.Client
+}
+
+// NewGitHubSource creates a GitHubSource for the provided `owner/repo` GitHub repository
+func NewGitHubSource(repository string) (*GitHubSource, error) {
+	parts := strings.Split(repository, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid GitHub repository %q (expected owner/repo)", repository)
+	}
+	return &GitHubSource{Owner: parts[0], Repo: parts[1], client: github.NewClient(nil)}, nil
+}
+
+// SetBaseURL overrides the GitHub API URL (for testing)
+func (s *GitHubSource) SetBaseURL(rawurl string) error {
+	if !strings.HasSuffix(rawurl, "/") {
+		rawurl += "/"
+	}
+	baseURL, err := url.Parse(rawurl)
+	if err != nil {
+		return err
+	}
+	s.client.BaseURL = baseURL
+	return nil
+}
+
+// LatestVersion returns the tag of the latest release
+func (s *GitHubSource) LatestVersion() (string, error) {
+	release, _, err := s.client.Repositories.GetLatestRelease(context.Background(), s.Owner, s.Repo)
+	if err != nil {
+		return "", err
+	}
+	return release.GetTagName(), nil
+}
+
+// StaticSource is a Source which always provides the same (configured) version
+type StaticSource string
+
+// LatestVersion returns the configured version
+func (s StaticSource) LatestVersion() (string, error) {
+	return string(s), nil
+}
+
+// UnknownVersion is reported for a Node whose version could not be determined
+const UnknownVersion = "unknown"
+
+// Report models the outcome of comparing the versions run by the Nodes of a Skyminer
+type Report struct {
+	// Latest upstream version ("" if it could not be determined)
+	Latest string
+	// Nodes maps each Node key to the version it is running
+	Nodes map[string]string
+	// Versions lists the distinct versions run by the Nodes (sorted, without any `v` prefix).
+	// Nodes whose version is not known are only reported in Nodes, so they are not
+	// considered to be running a different version.
+	Versions []string
+	// Outdated lists the keys of the Nodes running a version older than Latest (sorted)
+	Outdated []string
+}
+
+// Compare builds a Report from the versions run by each Node (keyed by Node key) and
+// the latest upstream version (which may be "" if it is not known)
+func Compare(nodes map[string]string, latest string) Report {
+	r := Report{Latest: latest, Nodes: nodes}
+
+	latestVersion, err := version.NewVersion(latest)
+	if err != nil {
+		latestVersion = nil
+	}
+
+	distinct := make(map[string]bool)
+	for key, v := range nodes {
+		if v == UnknownVersion {
+			continue
+		}
+		// Releases are tagged vX.Y.Z while Nodes report X.Y.Z
+		distinct[strings.TrimPrefix(v, "v")] = true
+		if latestVersion == nil {
+			continue
+		}
+		if nv, err := version.NewVersion(v); err == nil && nv.LessThan(latestVersion) {
+			r.Outdated = append(r.Outdated, key)
+		}
+	}
+
+	for v := range distinct {
+		r.Versions = append(r.Versions, v)
+	}
+	sort.Strings(r.Versions)
+	sort.Strings(r.Outdated)
+	return r
+}
+
+// Mixed determines if the Nodes are running different versions
+func (r Report) Mixed() bool {
+	return len(r.Versions) > 1
+}
+
+// NeedsAttention determines if any Node is outdated, or the Nodes are running different versions
+func (r Report) NeedsAttention() bool {
+	return r.Mixed() || len(r.Outdated) > 0
+}
+
+// Signature summarises the issues identified by the Report. It changes whenever the
+// set of issues changes, so can be used to only alert about new issues.
+func (r Report) Signature() string {
+	if !r.NeedsAttention() {
+		return ""
+	}
+	return fmt.Sprintf("%s|%s|%s", r.Latest, strings.Join(r.Versions, ","), strings.Join(r.Outdated, ","))
+}
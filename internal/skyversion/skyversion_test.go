@@ -0,0 +1,117 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package skyversion
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func Test_GitHubSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/skycoin/skywire/releases/latest" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"tag_name": "v0.2.0"}`)
+	}))
+	defer server.Close()
+
+	src, err := NewGitHubSource("skycoin/skywire")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SetBaseURL(server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := src.LatestVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest != "v0.2.0" {
+		t.Errorf("Expected: v0.2.0, got %s", latest)
+	}
+}
+
+func Test_NewGitHubSource_Invalid(t *testing.T) {
+	for _, repo := range []string{"", "skywire", "/skywire", "skycoin/", "a/b/c"} {
+		if _, err := NewGitHubSource(repo); err == nil {
+			t.Errorf("Expected: error for repository %q", repo)
+		}
+	}
+}
+
+func Test_Compare(t *testing.T) {
+	testCases := []struct {
+		name      string
+		nodes     map[string]string
+		latest    string
+		versions  []string
+		outdated  []string
+		attention bool
+	}{
+		{
+			name:     "up to date",
+			nodes:    map[string]string{"a": "0.2.0", "b": "v0.2.0"},
+			latest:   "v0.2.0",
+			versions: []string{"0.2.0"},
+		},
+		{
+			name:     "newer than latest",
+			nodes:    map[string]string{"a": "0.3.0-rc1", "b": "0.3.0-rc1"},
+			latest:   "v0.2.0",
+			versions: []string{"0.3.0-rc1"},
+		},
+		{
+			name:      "outdated",
+			nodes:     map[string]string{"a": "0.1.0", "b": "0.1.0"},
+			latest:    "v0.2.0",
+			versions:  []string{"0.1.0"},
+			outdated:  []string{"a", "b"},
+			attention: true,
+		},
+		{
+			name:      "mixed",
+			nodes:     map[string]string{"a": "0.1.0", "b": "0.2.0", "c": UnknownVersion},
+			latest:    "v0.2.0",
+			versions:  []string{"0.1.0", "0.2.0"},
+			outdated:  []string{"a"},
+			attention: true,
+		},
+		{
+			name:     "unknown",
+			nodes:    map[string]string{"a": "0.1.0", "b": UnknownVersion},
+			versions: []string{"0.1.0"},
+		},
+		{
+			name:      "latest unknown",
+			nodes:     map[string]string{"a": "0.1.0", "b": "0.2.0"},
+			versions:  []string{"0.1.0", "0.2.0"},
+			attention: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		r := Compare(tc.nodes, tc.latest)
+		if diff := deep.Equal(r.Versions, tc.versions); diff != nil {
+			t.Errorf("%s: versions: %v", tc.name, diff)
+		}
+		if diff := deep.Equal(r.Outdated, tc.outdated); diff != nil {
+			t.Errorf("%s: outdated: %v", tc.name, diff)
+		}
+		if r.NeedsAttention() != tc.attention {
+			t.Errorf("%s: Expected: NeedsAttention %v", tc.name, tc.attention)
+		}
+		if (r.Signature() != "") != tc.attention {
+			t.Errorf("%s: Unexpected signature %q", tc.name, r.Signature())
+		}
+	}
+}
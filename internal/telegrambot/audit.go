@@ -0,0 +1,140 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package telegrambot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/wcaudit"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	"github.com/BigOokie/skywire-wing-commander/internal/wctelemetry"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// auditDefaultEntries is the number of entries shown by `/audit` if no count is provided
+	auditDefaultEntries = 10
+	// auditMaxEntries is the maximum number of entries shown by `/audit`
+	auditMaxEntries = 30
+)
+
+// setAuditOutcome allows a command handler to report an outcome which cannot be
+// determined from the error it returns (i.e. a command which is awaiting confirmation,
+// or which failed after the failure was reported to the user)
+func (ctx *BotContext) setAuditOutcome(outcome string, err error) {
+	ctx.auditOutcome = outcome
+	ctx.auditErr = err
+}
+
+// setAuditArgs allows a command handler to replace the arguments recorded in the audit log
+// (i.e. to prevent a two factor code from being recorded)
+func (ctx *BotContext) setAuditArgs(args string) {
+	ctx.auditArgs = &args
+}
+
+// recordAudit appends an entry to the audit log. Failure to write the audit log
+// is logged but does not prevent the command from being handled.
+func (bot *Bot) recordAudit(ctx *BotContext, command, args, outcome string, err error) {
+	if bot.audit == nil || ctx == nil {
+		return
+	}
+
+	e := wcaudit.Entry{
+		Source:  wcaudit.SourceMessage,
+		Command: command,
+		Args:    args,
+		Outcome: outcome,
+	}
+	if ctx.IsCallBackQuery() {
+		e.Source = wcaudit.SourceCallback
+	}
+	if ctx.User != nil {
+		e.UserID = ctx.User.ID
+		e.UserName = ctx.User.UserName
+	}
+	if ctx.message != nil && ctx.message.Chat != nil {
+		e.ChatID = ctx.message.Chat.ID
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	if werr := bot.audit.Record(e); werr != nil {
+		log.Errorf("Bot.recordAudit: Failed to write audit log (%s): %v", bot.audit.Path(), werr)
+	}
+}
+
+// auditCommand records the outcome of a command dispatched by handleCommand
+func (bot *Bot) auditCommand(ctx *BotContext, command, args string, err error) {
+	outcome := ctx.auditOutcome
+	if ctx.auditErr != nil {
+		err = ctx.auditErr
+	}
+	if ctx.auditArgs != nil {
+		args = *ctx.auditArgs
+	}
+
+	if outcome == "" {
+		switch {
+		case err == errAdminOnly:
+			outcome = wcaudit.OutcomeDenied
+		case err != nil:
+			outcome = wcaudit.OutcomeFailed
+		default:
+			outcome = wcaudit.OutcomeSuccess
+		}
+	}
+
+	if outcome == wcaudit.OutcomeFailed {
+		bot.RecordEvent(wctelemetry.CategoryCommandError, command, "Handle"+command)
+	}
+	bot.recordAudit(ctx, command, args, outcome, err)
+}
+
+// Handler for audit command. Shows the most recent audit log entries.
+func (bot *Bot) handleCommandAudit(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	n := auditDefaultEntries
+	if args = strings.TrimSpace(args); args != "" {
+		v, err := strconv.Atoi(args)
+		if err != nil || v <= 0 {
+			return bot.Send(ctx, getSendModeforContext(ctx), "text", wcconst.MsgAuditUsage)
+		}
+		n = v
+	}
+	if n > auditMaxEntries {
+		n = auditMaxEntries
+	}
+
+	if bot.audit == nil {
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", wcconst.MsgAuditEmpty)
+	}
+
+	entries, err := bot.audit.Recent(n)
+	if err != nil {
+		log.Errorf("Bot.handleCommandAudit: %v", err)
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgAuditReadFailed, err))
+	}
+	if len(entries) == 0 {
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", wcconst.MsgAuditEmpty)
+	}
+
+	var lines []string
+	for _, e := range entries {
+		lines = append(lines, e.String())
+	}
+
+	// Usernames and arguments may contain characters which are not valid markdown
+	err = bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgAudit, len(entries), strings.Join(lines, "\n")))
+	if err != nil {
+		logSendError("Bot.handleCommandAudit", err)
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package telegrambot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/wcaudit"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+)
+
+func Test_Audit_RecordsCommands(t *testing.T) {
+	bot, ft := newTestTwoFactorBot(t, wcconfig.TwoFactorModeTOTP)
+	defer removeTestState(bot)
+
+	messages := []struct {
+		id       int
+		username string
+		text     string
+	}{
+		{1001, "admin", "/help"},
+		{2002, "member", "/stop"},
+		{3003, "stranger", "/status"},
+		{1001, "admin", "/stop 000000"},
+		{1001, "admin", "/nosuchcommand"},
+	}
+	for _, m := range messages {
+		if err := bot.handleMessage(newTestCommandCtx(m.id, m.username, m.text)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	entries, err := bot.audit.Recent(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		userID  int
+		command string
+		args    string
+		outcome string
+	}{
+		{1001, "help", "", wcaudit.OutcomeSuccess},
+		{2002, "stop", "", wcaudit.OutcomeDenied},
+		{3003, "status", "", wcaudit.OutcomeUnauthorized},
+		// The two factor code must never be recorded
+		{1001, "stop", "", wcaudit.OutcomeDenied},
+		{1001, "nosuchcommand", "", wcaudit.OutcomeUnknown},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected: %d audit entries, got %d: %+v", len(expected), len(entries), entries)
+	}
+	for i, e := range expected {
+		got := entries[i]
+		if got.UserID != e.userID || got.Command != e.command || got.Args != e.args || got.Outcome != e.outcome {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, e, got)
+		}
+		if got.Source != wcaudit.SourceMessage {
+			t.Errorf("Entry %d: expected source %s, got %s", i, wcaudit.SourceMessage, got.Source)
+		}
+	}
+
+	// The audit log can be viewed by the Admin
+	if err := bot.handleMessage(newTestCommandCtx(1001, "admin", "/audit 2")); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(lastSent(ft), "nosuchcommand") {
+		t.Errorf("Expected: audit entries to be sent, got %s", lastSent(ft))
+	}
+}
+
+func Test_HandleCommandStats(t *testing.T) {
+	config := reloadTestConfig()
+	config.Telegram.AdminIDs = []int{1001}
+	bot, ft := newTestBot(t, config)
+	defer removeTestState(bot)
+
+	for _, text := range []string{"/help", "/help", "/set monitor.intervalsec often", "/stats"} {
+		if err := bot.handleMessage(newTestCommandCtx(1001, "admin", text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msg := lastReloadMsg(ft)
+	for _, expect := range []string{"Commands: /help 2, /set 1, /stats 1", "Failed: /set 1", "only kept locally"} {
+		if !strings.Contains(msg, expect) {
+			t.Errorf("Expected: %q in %s", expect, msg)
+		}
+	}
+}
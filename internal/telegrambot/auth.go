@@ -0,0 +1,142 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package telegrambot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/wcaudit"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	log "github.com/sirupsen/logrus"
+)
+
+// getAdminIDs returns the Telegram user IDs which are authorized as Admin.
+// This is the union of the IDs provided in the config (`telegram.adminids`)
+// and the IDs which have been bound at runtime and persisted to the State.
+func (bot *Bot) getAdminIDs() []int {
+	ids := append([]int(nil), bot.getConfig().Telegram.AdminIDs...)
+	if bot.state != nil {
+		ids = append(ids, bot.state.GetAdminIDs()...)
+	}
+	return ids
+}
+
+// isAdminID determines if the provided Telegram user ID is authorized as Admin
+func (bot *Bot) isAdminID(id int) bool {
+	for _, v := range bot.getAdminIDs() {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// isMemberID determines if the provided Telegram user ID is authorized as a
+// group member (`telegram.members`). Members may use all non-Admin commands.
+func (bot *Bot) isMemberID(id int) bool {
+	for _, v := range bot.getConfig().Telegram.Members {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// isConfiguredAdminName determines if the provided Telegram username matches the
+// Admin username from the config. Telegram usernames are case insensitive.
+func (bot *Bot) isConfiguredAdminName(username string) bool {
+	return username != "" && strings.EqualFold("@"+username, bot.getConfig().Telegram.Admin)
+}
+
+// authorizeUser determines if the User in the provided BotContext is allowed to
+// interact with the Bot. Users are authorized by their immutable Telegram user ID,
+// either as an Admin or (for group chats) as a member.
+// If no Admin user IDs have been bound yet, the first `/start` received from the
+// configured Admin username binds that users ID and persists it to the State.
+func (bot *Bot) authorizeUser(ctx *BotContext, command string) bool {
+	if ctx.User == nil {
+		log.Debug("Bot.authorizeUser: Ignoring message with no user.")
+		return false
+	}
+
+	if bot.isAdminID(ctx.User.ID) {
+		ctx.User.Admin = true
+		return true
+	}
+
+	if bot.isMemberID(ctx.User.ID) {
+		return true
+	}
+
+	if len(bot.getAdminIDs()) == 0 && bot.isConfiguredAdminName(ctx.User.UserName) {
+		if command != "start" {
+			log.Infof("Bot.authorizeUser: Admin %s is not yet bound to a user ID. Waiting for /start.", bot.getConfig().Telegram.Admin)
+			bot.recordAudit(ctx, command, "", wcaudit.OutcomeUnauthorized, nil)
+			bot.replyWithHint(ctx, wcconst.MsgAdminBindHint)
+			return false
+		}
+
+		if err := bot.bindAdminID(ctx.User); err != nil {
+			log.Errorf("Bot.authorizeUser: Failed to bind Admin user ID: %v", err)
+			return false
+		}
+		ctx.User.Admin = true
+		return true
+	}
+
+	bot.reportUnknownUser(ctx, command)
+	return false
+}
+
+// bindAdminID binds the provided User as Admin by persisting their user ID to the State
+func (bot *Bot) bindAdminID(u *User) error {
+	if bot.state == nil {
+		return fmt.Errorf("no state available to persist admin user id")
+	}
+
+	if err := bot.state.AddAdminID(u.ID); err != nil {
+		return err
+	}
+
+	log.Infof("Bot.bindAdminID: Admin %s bound to Telegram user ID %d (%s)", bot.getConfig().Telegram.Admin, u.ID, bot.state.Path())
+	err := bot.SendNewMessage("text", fmt.Sprintf(wcconst.MsgAdminBound, bot.getConfig().Telegram.Admin, u.ID, u.ID))
+	if err != nil {
+		logSendError("Bot.bindAdminID", err)
+	}
+	return nil
+}
+
+// reportUnknownUser logs and alerts the Admin (once per user ID) when
+// an unknown user attempts to interact with the Bot
+func (bot *Bot) reportUnknownUser(ctx *BotContext, command string) {
+	log.Warnf("Bot.reportUnknownUser: Ignoring command %q from unknown user %s", command, ctx.User.NameAndTags())
+	bot.recordAudit(ctx, command, "", wcaudit.OutcomeUnauthorized, nil)
+
+	bot.m.Lock()
+	reported := bot.unknownUsers[ctx.User.ID]
+	bot.unknownUsers[ctx.User.ID] = true
+	bot.m.Unlock()
+
+	if reported || len(bot.getAdminIDs()) == 0 {
+		return
+	}
+
+	err := bot.SendNewMessage("text", fmt.Sprintf(wcconst.MsgUnknownUser, ctx.User.NameAndTags(), ctx.User.ID, command))
+	if err != nil {
+		logSendError("Bot.reportUnknownUser", err)
+	}
+}
+
+// replyWithHint sends a plain text hint in response to the message in the provided BotContext
+func (bot *Bot) replyWithHint(ctx *BotContext, hint string) {
+	if ctx.message == nil {
+		return
+	}
+	if err := bot.Reply(ctx, "text", hint); err != nil {
+		logSendError("Bot.replyWithHint", err)
+	}
+}
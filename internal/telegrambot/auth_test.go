@@ -0,0 +1,212 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package telegrambot
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/skymgrmon"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcaudit"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcstate"
+	"github.com/BigOokie/skywire-wing-commander/internal/wctelemetry"
+	tgbotapi "gopkg.in/telegram-bot-api.v4"
+)
+
+// fakeTelegram is a http.RoundTripper which records requests made to the
+// Telegram Bot API and responds with an empty successful result
+type fakeTelegram struct {
+	m        sync.Mutex
+	requests []string
+	// fail (if set) is called with the path of each request, and may fail it by returning
+	// a response (i.e. an error from Telegram) or an error (i.e. a network error)
+	fail func(path string) (string, error)
+}
+
+func (ft *fakeTelegram) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := ""
+	if req.Body != nil {
+		b, _ := ioutil.ReadAll(req.Body)
+		body = string(b)
+	}
+	ft.m.Lock()
+	fail := ft.fail
+	ft.m.Unlock()
+
+	response := `{"ok":true,"result":{}}`
+	if fail != nil {
+		r, err := fail(req.URL.Path)
+		if err != nil {
+			return nil, err
+		}
+		if r != "" {
+			response = r
+		}
+	}
+
+	ft.m.Lock()
+	ft.requests = append(ft.requests, req.URL.Path+"?"+body)
+	ft.m.Unlock()
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(bytes.NewBufferString(response)),
+		Request:    req,
+	}, nil
+}
+
+// setFail sets the function which may fail requests (see fail)
+func (ft *fakeTelegram) setFail(fail func(path string) (string, error)) {
+	ft.m.Lock()
+	defer ft.m.Unlock()
+	ft.fail = fail
+}
+
+// sent returns the requests recorded for the provided Telegram API method
+func (ft *fakeTelegram) sent(method string) []string {
+	ft.m.Lock()
+	defer ft.m.Unlock()
+	var result []string
+	for _, r := range ft.requests {
+		if strings.Contains(r, "/"+method+"?") {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// newTestBot creates a Bot which talks to a fakeTelegram rather than the Telegram Bot API
+func newTestBot(t *testing.T, config wcconfig.Config) (*Bot, *fakeTelegram) {
+	dir, err := ioutil.TempDir("", "telegrambot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ft := &fakeTelegram{}
+	bot := &Bot{
+		config:               config,
+		state:                wcstate.NewState(filepath.Join(dir, "state.json")),
+		audit:                wcaudit.NewLog(filepath.Join(dir, "audit.log")),
+		telegram:             &tgbotapi.BotAPI{Token: "TEST", Client: &http.Client{Transport: ft}},
+		skyMgrMonitor:        skymgrmon.NewMonitor("127.0.0.1:0", "127.0.0.1:0"),
+		commandHandlers:      make(map[string]CommandHandler),
+		adminCommandHandlers: make(map[string]CommandHandler),
+		protectedCommands:    make(map[string]bool),
+		pendingConfirmations: make(map[string]*pendingConfirmation),
+		unknownUsers:         make(map[int]bool),
+		hostBreaches:         make(map[string]bool),
+		loops:                make(map[string]context.CancelFunc),
+		telemetry:            wctelemetry.New(nil),
+	}
+	// Messages are not rate limited (or persisted) within tests
+	bot.outbox = newSendQueue(func(c tgbotapi.Chattable) error {
+		_, err := bot.telegram.Send(c)
+		return err
+	})
+	bot.outbox.privateRate, bot.outbox.groupRate = 0, 0
+	bot.setCommandHandlers()
+	return bot, ft
+}
+
+func removeTestState(bot *Bot) {
+	os.RemoveAll(filepath.Dir(bot.state.Path()))
+}
+
+// newTestCommandCtx creates a BotContext for a private command message sent by the provided user
+func newTestCommandCtx(id int, username, text string) *BotContext {
+	msg := &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: id, UserName: username},
+		Chat:      &tgbotapi.Chat{ID: int64(id), Type: "private", UserName: username},
+		Text:      text,
+	}
+	if strings.HasPrefix(text, "/") {
+		cmdlen := len(strings.Fields(text)[0])
+		msg.Entities = &[]tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: cmdlen}}
+	}
+	return &BotContext{message: msg, User: &User{ID: id, UserName: username}}
+}
+
+func Test_AuthorizeUser_BindOnStart(t *testing.T) {
+	var config wcconfig.Config
+	config.Telegram.Admin = "@TESTUSER"
+	bot, ft := newTestBot(t, config)
+	defer removeTestState(bot)
+
+	// Commands other than /start must not bind the configured Admin username
+	if bot.authorizeUser(newTestCommandCtx(1001, "testuser", "/status"), "status") {
+		t.Error("Expected: /status should not be authorized before binding")
+	}
+	if len(bot.getAdminIDs()) != 0 {
+		t.Error("Expected: No Admin user IDs should be bound")
+	}
+
+	// /start from the configured Admin username binds the user ID
+	ctx := newTestCommandCtx(1001, "testuser", "/start")
+	if !bot.authorizeUser(ctx, "start") {
+		t.Error("Expected: /start from configured Admin should be authorized")
+	}
+	if !ctx.User.Admin {
+		t.Error("Expected: User should be flagged as Admin")
+	}
+	if !bot.isAdminID(1001) {
+		t.Error("Expected: User ID 1001 should be bound as Admin")
+	}
+
+	// Once bound, the username is no longer used for authorization
+	if bot.authorizeUser(newTestCommandCtx(2002, "testuser", "/start"), "start") {
+		t.Error("Expected: A different user ID claiming the Admin username should not be authorized")
+	}
+	if !bot.authorizeUser(newTestCommandCtx(1001, "renameduser", "/status"), "status") {
+		t.Error("Expected: Bound user ID should be authorized after changing username")
+	}
+
+	// The bound user ID must be persisted
+	state, err := wcstate.LoadState(bot.state.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := state.GetAdminIDs(); len(ids) != 1 || ids[0] != 1001 {
+		t.Errorf("Unexpected persisted Admin user IDs: %v", ids)
+	}
+
+	// The unknown user should have been reported once
+	if len(ft.sent("sendMessage")) == 0 {
+		t.Error("Expected: Messages should have been sent to Telegram")
+	}
+}
+
+func Test_AuthorizeUser_ConfiguredAdminIDs(t *testing.T) {
+	var config wcconfig.Config
+	config.Telegram.Admin = "@TESTUSER"
+	config.Telegram.AdminIDs = []int{1001}
+	bot, ft := newTestBot(t, config)
+	defer removeTestState(bot)
+
+	if !bot.authorizeUser(newTestCommandCtx(1001, "", "/help"), "help") {
+		t.Error("Expected: Configured Admin user ID should be authorized")
+	}
+
+	if bot.authorizeUser(newTestCommandCtx(3003, "testuser", "/start"), "start") {
+		t.Error("Expected: Unknown user ID should not be authorized")
+	}
+	if bot.authorizeUser(newTestCommandCtx(3003, "testuser", "/stop"), "stop") {
+		t.Error("Expected: Unknown user ID should not be authorized")
+	}
+
+	// Unknown users are only reported to the Admin once
+	if n := len(ft.sent("sendMessage")); n != 1 {
+		t.Errorf("Expected: 1 unknown user alert to be sent, got %d", n)
+	}
+}
@@ -5,6 +5,8 @@
 package telegrambot
 
 import (
+	"strings"
+
 	"gopkg.in/telegram-bot-api.v4"
 )
 
@@ -41,3 +43,14 @@ func CreateMultiLineMarkup(btns ...string) tgbotapi.InlineKeyboardMarkup {
 
 	return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
+
+// splitCallbackData splits the data from an inline button into a command and its arguments.
+// For example "confirm 1a2b3c4d" produces the command "confirm" and the arguments "1a2b3c4d"
+func splitCallbackData(data string) (command, args string) {
+	parts := strings.SplitN(strings.TrimSpace(data), " ", 2)
+	command = parts[0]
+	if len(parts) > 1 {
+		args = strings.TrimSpace(parts[1])
+	}
+	return
+}
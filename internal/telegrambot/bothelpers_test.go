@@ -126,3 +126,15 @@ func Test_CreateMultiLineMarkup_ThreeLines(t *testing.T) {
 	}
 
 }
+
+func Test_SplitCallbackData(t *testing.T) {
+	cmd, args := splitCallbackData("status")
+	if cmd != "status" || args != "" {
+		t.Errorf("Unexpected result: %q %q", cmd, args)
+	}
+
+	cmd, args = splitCallbackData("confirm 1a2b3c4d")
+	if cmd != "confirm" || args != "1a2b3c4d" {
+		t.Errorf("Unexpected result: %q %q", cmd, args)
+	}
+}
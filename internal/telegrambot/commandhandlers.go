@@ -11,8 +11,8 @@ import (
 	"strings"
 	"time"
 
-	"github.com/BigOokie/skywire-wing-commander/internal/utils"
 	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	"github.com/BigOokie/skywire-wing-commander/internal/wctelemetry"
 	log "github.com/sirupsen/logrus"
 	tgbotapi "gopkg.in/telegram-bot-api.v4"
 )
@@ -24,7 +24,10 @@ func logSendError(from string, err error) {
 func getSendModeforContext(ctx *BotContext) string {
 	var mode string
 
-	if ctx.IsCallBackQuery() {
+	if ctx.isGroupChat() && ctx.IsUserMessage() {
+		// Within a group we reply to the message which requested the command
+		mode = "reply"
+	} else if ctx.IsCallBackQuery() {
 		// we cannot "whisper" otherwise this will instruct the
 		// bot to talk to itself which is prohibuted. We must "yell"
 		mode = "yell"
@@ -38,8 +41,8 @@ func getSendModeforContext(ctx *BotContext) string {
 // Handler for help command
 func (bot *Bot) handleCommandHelp(ctx *BotContext, command, args string) error {
 	log.Debugf("Handle command: %s args: %s", command, args)
-	bot.SendGAEvent("BotCommand", command, "Handle"+command)
-	err := bot.Send(ctx, getSendModeforContext(ctx), "markdown", fmt.Sprintf(wcconst.MsgHelp, bot.config.Telegram.Admin))
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+	err := bot.Send(ctx, getSendModeforContext(ctx), "markdown", fmt.Sprintf(wcconst.MsgHelp, bot.getConfig().Telegram.Admin))
 	if err != nil {
 		logSendError("Bot.handleCommandHelp", err)
 	}
@@ -49,7 +52,7 @@ func (bot *Bot) handleCommandHelp(ctx *BotContext, command, args string) error {
 // Handler for about command
 func (bot *Bot) handleCommandAbout(ctx *BotContext, command, args string) error {
 	log.Debugf("Handle command: %s args: %s", command, args)
-	bot.SendGAEvent("BotCommand", command, "Handle"+command)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
 	err := bot.Send(ctx, getSendModeforContext(ctx), "markdown", wcconst.MsgAbout)
 	if err != nil {
 		logSendError("Bot.handleCommandAbout", err)
@@ -60,13 +63,14 @@ func (bot *Bot) handleCommandAbout(ctx *BotContext, command, args string) error
 // Handler for showconfig command
 func (bot *Bot) handleCommandShowConfig(ctx *BotContext, command, args string) error {
 	log.Debugf("Handle command: %s args: %s", command, args)
-	bot.SendGAEvent("BotCommand", command+"-asmarkdown", "Handle"+command)
-	err := bot.Send(ctx, getSendModeforContext(ctx), "markdown", fmt.Sprintf(wcconst.MsgShowConfig, bot.config.String()))
+	bot.RecordEvent("BotCommand", command+"-asmarkdown", "Handle"+command)
+	config := bot.getConfig()
+	err := bot.Send(ctx, getSendModeforContext(ctx), "markdown", fmt.Sprintf(wcconst.MsgShowConfig, config.String()))
 	if err != nil {
 		logSendError("Bot.handleCommandShowConfig (Send):", err)
 		log.Debug("Bot.handleCommandShowConfig: Attempting to resend as text.")
-		bot.SendGAEvent("BotCommand", command+"-astext", "Handle"+command)
-		err = bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgShowConfig, bot.config.String()))
+		bot.RecordEvent("BotCommand", command+"-astext", "Handle"+command)
+		err = bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgShowConfig, config.String()))
 		if err != nil {
 			logSendError("Bot.handleCommandShowConfig (Resend as Text):", err)
 		}
@@ -85,10 +89,10 @@ func (bot *Bot) handleCommandGetUptimeLink(ctx *BotContext, command, args string
 	if bot.skyMgrMonitor.IsRunning() {
 		// Add Node Keys as parameters to the URL Query
 		uptimeURL = fmt.Sprintf("https://skywirenc.com/?key_list=%s", strings.Join(bot.skyMgrMonitor.GetNodeKeyList(), "%2C"))
-		bot.SendGAEvent("BotCommand", command+"-isrunning", "Handle"+command)
+		bot.RecordEvent("BotCommand", command+"-isrunning", "Handle"+command)
 	} else {
 		uptimeURL = "https://skywirenc.com/"
-		bot.SendGAEvent("BotCommand", command+"-notrunning", "Handle"+command)
+		bot.RecordEvent("BotCommand", command+"-notrunning", "Handle"+command)
 	}
 	msg := fmt.Sprintf("Skywirenc.com (%v Nodes)", bot.skyMgrMonitor.GetConnectedNodeCount())
 	log.Debugf("Bot.handleCommandGetUptimeLink: %s", msg)
@@ -129,25 +133,17 @@ func (bot *Bot) handleCommandStart(ctx *BotContext, command, args string) error
 
 	if bot.skyMgrMonitor.IsRunning() {
 		log.Debug(wcconst.MsgMonitorAlreadyStarted)
-		bot.SendGAEvent("BotCommand", command+"-isrunning", "Handle"+command)
+		bot.RecordEvent("BotCommand", command+"-isrunning", "Handle"+command)
 		err := bot.Send(ctx, getSendModeforContext(ctx), "markdown", wcconst.MsgMonitorAlreadyStarted)
 		if err != nil {
 			logSendError("Bot.handleCommandStart", err)
 		}
 		return err
 	}
-	bot.SendGAEvent("BotCommand", command+"-notrunning", "Handle"+command)
+	bot.RecordEvent("BotCommand", command+"-notrunning", "Handle"+command)
 
 	log.Debug(wcconst.MsgMonitorStart)
-	cancelContext, cancelFunc := context.WithCancel(context.Background())
-	monitorStatusMsgChan := make(chan string)
-
-	// Start the Event Monitor - provide cancelContext
-	go bot.monitorEventLoop(cancelContext, ctx, monitorStatusMsgChan)
-	// Start monitoring the local Manager - provide cancelContext
-	go bot.skyMgrMonitor.RunManagerMonitor(cancelContext, cancelFunc, monitorStatusMsgChan, bot.config.Monitor.IntervalSec)
-	// Start monitoring the local Manager - provide cancelContext
-	//go bot.skyMgrMonitor.RunDiscoveryMonitor(cancelContext, monitorStatusMsgChan, bot.config.Monitor.DiscoveryMonitorIntMin)
+	bot.startMonitor()
 
 	err := bot.Send(ctx, getSendModeforContext(ctx), "markdown", wcconst.MsgMonitorStart)
 	if err != nil {
@@ -156,12 +152,26 @@ func (bot *Bot) handleCommandStart(ctx *BotContext, command, args string) error
 	return err
 }
 
+// startMonitor starts monitoring the local Manager, along with the Event Monitor
+// which sends its events (and the Heartbeat) to the configured chat
+func (bot *Bot) startMonitor() {
+	cancelContext, cancelFunc := context.WithCancel(bot.context())
+	monitorStatusMsgChan := make(chan string)
+
+	// Start the Event Monitor - provide cancelContext
+	bot.goBackground(func() { bot.monitorEventLoop(cancelContext, monitorStatusMsgChan) })
+	// Start monitoring the local Manager - provide cancelContext
+	go bot.skyMgrMonitor.RunManagerMonitor(cancelContext, cancelFunc, monitorStatusMsgChan, bot.getConfig().Monitor.IntervalSec)
+	// Start monitoring the local Manager - provide cancelContext
+	//go bot.skyMgrMonitor.RunDiscoveryMonitor(cancelContext, monitorStatusMsgChan, bot.config.Monitor.DiscoveryMonitorIntMin)
+}
+
 // Handler for stop command
 func (bot *Bot) handleCommandStop(ctx *BotContext, command, args string) error {
 	log.Debugf("Handle command: %s args: %s", command, args)
 
 	if bot.skyMgrMonitor.IsRunning() {
-		bot.SendGAEvent("BotCommand", command+"-isrunning", "Handle"+command)
+		bot.RecordEvent("BotCommand", command+"-isrunning", "Handle"+command)
 		log.Debug(wcconst.MsgMonitorStop)
 		bot.skyMgrMonitor.StopManagerMonitor()
 		log.Debug(wcconst.MsgMonitorStopped)
@@ -172,7 +182,7 @@ func (bot *Bot) handleCommandStop(ctx *BotContext, command, args string) error {
 		return err
 	}
 
-	bot.SendGAEvent("BotCommand", command+"-notrunning", "Handle"+command)
+	bot.RecordEvent("BotCommand", command+"-notrunning", "Handle"+command)
 	log.Debug(wcconst.MsgMonitorNotRunning)
 	err := bot.Send(ctx, getSendModeforContext(ctx), "markdown", wcconst.MsgMonitorNotRunning)
 	if err != nil {
@@ -187,7 +197,7 @@ func (bot *Bot) handleCommandStatus(ctx *BotContext, command, args string) error
 
 	if !bot.skyMgrMonitor.IsRunning() {
 		// Monitor not running
-		bot.SendGAEvent("BotCommand", command+"-notrunning", "Handle"+command)
+		bot.RecordEvent("BotCommand", command+"-notrunning", "Handle"+command)
 		err := bot.Send(ctx, getSendModeforContext(ctx), "markdown", wcconst.MsgMonitorNotRunning)
 		if err != nil {
 			logSendError("Bot.handleCommandStatus", err)
@@ -195,7 +205,7 @@ func (bot *Bot) handleCommandStatus(ctx *BotContext, command, args string) error
 		return err
 	}
 
-	bot.SendGAEvent("BotCommand", command+"-isrunning", "Handle"+command)
+	bot.RecordEvent("BotCommand", command+"-isrunning", "Handle"+command)
 	// Build Status Check Message
 	msg := bot.skyMgrMonitor.BuildConnectionStatusMsg(wcconst.MsgStatus)
 	err := bot.Send(ctx, getSendModeforContext(ctx), "markdown", msg)
@@ -205,36 +215,10 @@ func (bot *Bot) handleCommandStatus(ctx *BotContext, command, args string) error
 	return err
 }
 
-// Handler for help CheckUpdate
-func (bot *Bot) handleCommandCheckUpdate(ctx *BotContext, command, args string) error {
-	log.Debugf("Handle command: %s args: %s", command, args)
-	bot.SendGAEvent("BotCommand", command, "Handle"+command)
-
-	err := bot.Send(ctx, getSendModeforContext(ctx), "markdown", "Checking for updates...")
-	if err != nil {
-		logSendError("Bot.handleCommandCheckUpdate", err)
-		return err
-	}
-
-	updateAvailable, updateMsg := utils.UpdateAvailable("BigOokie", "skywire-wing-commander", wcconst.BotVersion)
-	if updateAvailable {
-		bot.SendGAEvent("BotCommand", command+"-updateavailable", "Handle"+command)
-		err = bot.Send(ctx, getSendModeforContext(ctx), "markdown", fmt.Sprintf("*Update available:* %s", updateMsg))
-	} else {
-		bot.SendGAEvent("BotCommand", command+"-uptodate", "Handle"+command)
-		err = bot.Send(ctx, getSendModeforContext(ctx), "markdown", fmt.Sprintf("*Up to date:* %s", updateMsg))
-	}
-
-	if err != nil {
-		logSendError("Bot.handleCommandCheckUpdate", err)
-	}
-	return err
-}
-
 // Handler for help handleCommandShowMenu
 func (bot *Bot) handleCommandShowMenu(ctx *BotContext, command, args string) error {
 	log.Debugf("Handle command: %s args: %s", command, args)
-	bot.SendGAEvent("BotCommand", command, "Handle"+command)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
 
 	err := bot.SendMainMenuMessage(ctx)
 	if err != nil {
@@ -284,45 +268,10 @@ func (bot *Bot) handleCommandListNodes(ctx *BotContext, command, args string) er
 }
 */
 
-// Handler for help DoUpdate
-func (bot *Bot) handleCommandDoUpdate(ctx *BotContext, command, args string) error {
-	log.Debugf("Handle command: %s args: %s", command, args)
-	bot.SendGAEvent("BotCommand", command, "Handle"+command)
-
-	err := bot.Send(ctx, getSendModeforContext(ctx), "markdown", "*Initiating update...*")
-	if err != nil {
-		logSendError("Bot.handleCommandCheckUpdate", err)
-		return err
-	}
-
-	updateAvailable, updateMsg := utils.UpdateAvailable("BigOokie", "skywire-wing-commander", wcconst.BotVersion)
-	if !updateAvailable {
-		return bot.Send(ctx, getSendModeforContext(ctx), "markdown", fmt.Sprintf("*Already up to date:* %s", updateMsg))
-	}
-
-	err = bot.Send(ctx, getSendModeforContext(ctx), "markdown", fmt.Sprintf("*Update available:* %s", updateMsg))
-	if err != nil {
-		logSendError("Bot.handleCommandCheckUpdate", err)
-		return err
-	}
-
-	if utils.DoUpgrade() {
-		err = bot.Send(ctx, getSendModeforContext(ctx), "markdown", "Upgrade succeeded.")
-	} else {
-		err = bot.Send(ctx, getSendModeforContext(ctx), "markdown", "Upgrade failed.")
-	}
-	if err != nil {
-		logSendError("Bot.handleCommandCheckUpdate", err)
-		return err
-	}
-
-	return nil
-}
-
 func (bot *Bot) handleDirectMessageFallback(ctx *BotContext, text string) (bool, error) {
 	errmsg := fmt.Sprintf("Sorry, I only take commands. '%s' is not a command.\n\n%s", text, wcconst.MsgHelpShort)
-	log.Debugf(errmsg)
-	bot.SendGAEvent("BotCommandError", text, "HandleMessageFallback")
+	log.Debug(errmsg)
+	bot.RecordEvent(wctelemetry.CategoryCommandError, "NotACommand", "HandleMessageFallback")
 	return true, bot.Reply(ctx, "markdown", errmsg)
 }
 
@@ -337,18 +286,24 @@ func (bot *Bot) AddGroupMessageHandler(handler MessageHandler) {
 }
 
 // monitorEventLoop monitors for event messages from the SkyMgrMonitor (when running).
-// Its also responsible for managing the Heartbeat (if configured)
-func (bot *Bot) monitorEventLoop(runctx context.Context, botctx *BotContext, statusMsgChan <-chan string) {
-	tickerHB := time.NewTicker(bot.config.Monitor.HeartbeatIntMin)
-	bot.SendGAEvent("BotMonitoring", "Start", "Bot Monitoring Started")
+// Its also responsible for managing the Heartbeat (if configured).
+// Events are sent to the configured chat (private or group).
+func (bot *Bot) monitorEventLoop(runctx context.Context, statusMsgChan <-chan string) {
+	tickerHB := time.NewTicker(bot.getConfig().Monitor.HeartbeatIntMin)
+	bot.RecordEvent("BotMonitoring", "Start", "Bot Monitoring Started")
 	for {
 		select {
 		// Monitor Status Message
-		case msg := <-statusMsgChan:
-			bot.SendGAEvent("BotMonitoring", "ReceiveMonitorStatusMessage", "Receive Monitor Status Message")
+		case msg, ok := <-statusMsgChan:
+			if !ok {
+				// The Manager monitor has stopped
+				log.Debugln("Bot.monitorEventLoop - Status channel closed.")
+				return
+			}
+			bot.RecordEvent("BotMonitoring", "ReceiveMonitorStatusMessage", "Receive Monitor Status Message")
 			if msg != "" {
 				log.Debugf("Bot.monitorEventLoop: Status event: %s", msg)
-				err := bot.Send(botctx, getSendModeforContext(botctx), "markdown", msg)
+				err := bot.SendNewMessage("markdown", msg)
 				if err != nil {
 					logSendError("Bot.monitorEventLoop", err)
 				}
@@ -357,12 +312,12 @@ func (bot *Bot) monitorEventLoop(runctx context.Context, botctx *BotContext, sta
 		// Heartbeat ticker event
 		case <-tickerHB.C:
 			log.Debug("Bot.monitorEventLoop - Heartbeat event")
-			bot.SendGAEvent("BotMonitoring", "ReceiveHeartBeat", "Receive Monitor HeartBeat")
+			bot.RecordEvent("BotMonitoring", "ReceiveHeartBeat", "Receive Monitor HeartBeat")
 			// Build Heartbeat Status Message
 			msg := bot.skyMgrMonitor.BuildConnectionStatusMsg(wcconst.MsgHeartbeat)
 			log.Debug(msg)
 			if msg != "" {
-				err := bot.Send(botctx, getSendModeforContext(botctx), "markdown", msg)
+				err := bot.SendNewMessage("markdown", msg)
 				if err != nil {
 					logSendError("Bot.handleCommandStatus", err)
 				}
@@ -371,7 +326,7 @@ func (bot *Bot) monitorEventLoop(runctx context.Context, botctx *BotContext, sta
 		// Context has been cancelled. Shutdown
 		case <-runctx.Done():
 			log.Debugln("Bot.monitorEventLoop - Done event.")
-			bot.SendGAEvent("BotMonitoring", "ReceivedStop", "Receive Monitor Stop")
+			bot.RecordEvent("BotMonitoring", "ReceivedStop", "Receive Monitor Stop")
 			return
 		}
 	}
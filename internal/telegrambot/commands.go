@@ -6,12 +6,14 @@
 package telegrambot
 
 // Command struct is used to define a Telegram Bot command, including
-// if its an Admin only command, the string command (i.e. `/start`) and
-// the function that will handle the command
+// if its an Admin only command, the string command (i.e. `/start`),
+// the function that will handle the command and if the command is
+// protected by two factor confirmation (when enabled)
 type Command struct {
 	Admin       bool
 	Command     string
 	Handlerfunc CommandHandler
+	Protected   bool
 }
 
 // Commands provides an array (slice) of Command structs
@@ -19,6 +21,9 @@ type Commands []Command
 
 func (bot *Bot) setCommandHandlers() {
 	for _, command := range commands {
+		if command.Protected {
+			bot.protectedCommands[command.Command] = true
+		}
 		if command.Admin {
 			bot.adminCommandHandlers[command.Command] = command.Handlerfunc
 		} else {
@@ -27,7 +32,7 @@ func (bot *Bot) setCommandHandlers() {
 	}
 
 	bot.AddPrivateMessageHandler((*Bot).handleDirectMessageFallback)
-	bot.AddGroupMessageHandler((*Bot).handleDirectMessageFallback)
+	bot.AddGroupMessageHandler((*Bot).handleGroupMentionCommand)
 }
 
 var commands = Commands{
@@ -35,55 +40,192 @@ var commands = Commands{
 		false,
 		"help",
 		(*Bot).handleCommandHelp,
+		false,
 	},
 	Command{
 		false,
 		"about",
 		(*Bot).handleCommandAbout,
+		false,
 	},
 	Command{
-		false,
+		true,
 		"start",
 		(*Bot).handleCommandStart,
+		false,
 	},
 	Command{
-		false,
+		true,
 		"stop",
 		(*Bot).handleCommandStop,
+		true,
 	},
 	Command{
 		false,
 		"status",
 		(*Bot).handleCommandStatus,
+		false,
 	},
 	Command{
-		false,
+		true,
 		"showconfig",
 		(*Bot).handleCommandShowConfig,
+		false,
 	},
 	Command{
 		false,
 		"checkupdate",
 		(*Bot).handleCommandCheckUpdate,
+		false,
 	},
 	Command{
-		false,
+		true,
 		"update",
 		(*Bot).handleCommandDoUpdate,
+		true,
 	},
 	Command{
 		false,
 		"uptime",
 		(*Bot).handleCommandGetUptimeLink,
+		false,
 	},
 	Command{
 		false,
 		"whitelist",
 		(*Bot).handleCommandGetWhitelistLink,
+		false,
 	},
 	Command{
 		false,
 		"menu",
 		(*Bot).handleCommandShowMenu,
+		false,
+	},
+	Command{
+		true,
+		"2fasetup",
+		(*Bot).handleCommandTwoFactorSetup,
+		false,
+	},
+	Command{
+		false,
+		"confirm",
+		(*Bot).handleCommandConfirm,
+		false,
+	},
+	Command{
+		false,
+		"cancel",
+		(*Bot).handleCommandCancel,
+		false,
+	},
+	Command{
+		true,
+		"skipversion",
+		(*Bot).handleCommandSkipVersion,
+		false,
+	},
+	Command{
+		false,
+		"host",
+		(*Bot).handleCommandHost,
+		false,
+	},
+	Command{
+		false,
+		"probes",
+		(*Bot).handleCommandProbes,
+		false,
+	},
+	Command{
+		false,
+		"versions",
+		(*Bot).handleCommandVersions,
+		false,
+	},
+	Command{
+		true,
+		"get",
+		(*Bot).handleCommandGet,
+		false,
+	},
+	Command{
+		true,
+		"set",
+		(*Bot).handleCommandSet,
+		false,
+	},
+	Command{
+		true,
+		"savesetting",
+		(*Bot).handleCommandSaveSetting,
+		false,
+	},
+	Command{
+		true,
+		"loglevel",
+		(*Bot).handleCommandLogLevel,
+		false,
+	},
+	Command{
+		true,
+		"logs",
+		(*Bot).handleCommandLogs,
+		false,
+	},
+	Command{
+		true,
+		"audit",
+		(*Bot).handleCommandAudit,
+		false,
+	},
+	Command{
+		true,
+		"stats",
+		(*Bot).handleCommandStats,
+		false,
+	},
+	Command{
+		true,
+		"nodes",
+		(*Bot).handleCommandNodes,
+		false,
+	},
+	Command{
+		true,
+		"node",
+		(*Bot).handleCommandNode,
+		false,
+	},
+	Command{
+		true,
+		"restartnode",
+		(*Bot).handleCommandRestartNode,
+		true,
+	},
+	Command{
+		true,
+		"startapp",
+		(*Bot).handleCommandNodeApp,
+		true,
+	},
+	Command{
+		true,
+		"stopapp",
+		(*Bot).handleCommandNodeApp,
+		true,
+	},
+	Command{
+		true,
+		"restartapp",
+		(*Bot).handleCommandNodeApp,
+		true,
+	},
+	Command{
+		true,
+		"rebootall",
+		(*Bot).handleCommandRebootAll,
+		true,
 	},
 }
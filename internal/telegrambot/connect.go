@@ -0,0 +1,146 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package telegrambot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	tgbotapi "gopkg.in/telegram-bot-api.v4"
+)
+
+// updatesTimeout is the time (in seconds) Telegram holds a request for updates open (long polling)
+const updatesTimeout = 60
+
+// requestTimeout is the time allowed for a request to Telegram, so a request to a connection
+// which has stalled fails (and is retried) rather than blocking. It allows for long polling.
+const requestTimeout = (updatesTimeout + 10) * time.Second
+
+// isConnected determines if Telegram could be reached when last contacted
+func (bot *Bot) isConnected() bool {
+	bot.m.Lock()
+	defer bot.m.Unlock()
+	return bot.connected
+}
+
+// setConnected records if Telegram could be reached, and reports if that changed
+func (bot *Bot) setConnected(connected bool) bool {
+	bot.m.Lock()
+	defer bot.m.Unlock()
+	changed := bot.connected != connected
+	bot.connected = connected
+	return changed
+}
+
+// connect identifies the Bot and checks the configured chat with Telegram. It reports if a
+// failure may be retried (i.e. Telegram could not be reached), rather than being permanent
+// (i.e. the API key or chat were rejected by Telegram).
+func (bot *Bot) connect() (bool, error) {
+	config := bot.getConfig()
+
+	self, err := bot.telegram.GetMe()
+	if err != nil {
+		retry, _ := retryDelay(err)
+		// Errors include the API URL, which contains the API key
+		return retry, fmt.Errorf("Failed to initialize Telegram API: %s", config.Redact(err.Error()))
+	}
+
+	chat, err := bot.telegram.GetChat(tgbotapi.ChatConfig{ChatID: config.Telegram.ChatID})
+	if err != nil {
+		retry, _ := retryDelay(err)
+		return retry, fmt.Errorf("Failed to get chat info from Telegram: %s", config.Redact(err.Error()))
+	}
+	if !chat.IsPrivate() && !chat.IsGroup() && !chat.IsSuperGroup() {
+		return false, errors.New("Only private and group chats are supported")
+	}
+
+	// Self is only set once, as it is read (without locking) when handling updates. Updates
+	// are only received after connecting, so it is set before the first update is handled.
+	if bot.telegram.Self.ID == 0 {
+		bot.telegram.Self = self
+	}
+	bot.setConnected(true)
+
+	log.Printf("Bot User: %d %s", self.ID, self.UserName)
+	log.Printf("Bot Chat: %s %d %s", chat.Type, chat.ID, chat.Title)
+	return false, nil
+}
+
+// removeWebhook removes the webhook registered with Telegram (if any), as updates
+// can not be polled for while one is registered (see serveWebhook)
+func (bot *Bot) removeWebhook() error {
+	info, err := bot.telegram.GetWebhookInfo()
+	if err != nil || !info.IsSet() {
+		return err
+	}
+	if _, err := bot.telegram.RemoveWebhook(); err != nil {
+		return err
+	}
+	log.Infof("Bot.removeWebhook: Removed the webhook registered with Telegram, as webhook.enabled is not set")
+	return nil
+}
+
+// pollUpdates receives updates from Telegram (using long polling) and sends them to updates,
+// until ctx is done. If Telegram can not be reached it is retried with backoff. Once it can
+// be reached again, messages which could not be delivered meanwhile are retried immediately.
+func (bot *Bot) pollUpdates(ctx context.Context, updates chan<- tgbotapi.Update) {
+	config := tgbotapi.NewUpdate(0)
+	config.Timeout = updatesTimeout
+	failures := 0
+	webhookRemoved := false
+
+	for ctx.Err() == nil {
+		var batch []tgbotapi.Update
+		var err error
+		if !bot.isConnected() {
+			_, err = bot.connect()
+		}
+		if err == nil && !webhookRemoved {
+			err = bot.removeWebhook()
+			webhookRemoved = err == nil
+		}
+		if err == nil {
+			batch, err = bot.telegram.GetUpdates(config)
+		}
+		if err != nil {
+			failures++
+			wait := backoff(failures)
+			// Errors include the API URL, which contains the API key
+			c := bot.getConfig()
+			reason := c.Redact(err.Error())
+			if bot.setConnected(false) || failures == 1 {
+				log.Warnf("Bot.pollUpdates: Telegram can not be reached (retrying in %v): %s", wait, reason)
+			} else {
+				log.Debugf("Bot.pollUpdates: Telegram can not be reached (retrying in %v): %s", wait, reason)
+			}
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		if failures > 0 {
+			log.Infof("Bot.pollUpdates: Telegram can be reached again after %d failed attempts", failures)
+			failures = 0
+			bot.outbox.retryNow()
+		}
+		for _, update := range batch {
+			if update.UpdateID < config.Offset {
+				continue
+			}
+			config.Offset = update.UpdateID + 1
+			select {
+			case updates <- update:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package telegrambot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	tgbotapi "gopkg.in/telegram-bot-api.v4"
+)
+
+const (
+	testGetMe       = `{"ok":true,"result":{"id":42,"username":"wcbot"}}`
+	testPrivateChat = `{"ok":true,"result":{"id":123456789,"type":"private"}}`
+)
+
+// fakeTelegramAPI responds to the requests used to connect to Telegram
+func fakeTelegramAPI(chat string) func(path string) (string, error) {
+	return func(path string) (string, error) {
+		switch {
+		case strings.HasSuffix(path, "/getMe"):
+			return testGetMe, nil
+		case strings.HasSuffix(path, "/getChat"):
+			return chat, nil
+		}
+		return "", nil
+	}
+}
+
+func Test_Connect(t *testing.T) {
+	testCases := []struct {
+		name    string
+		fail    func(path string) (string, error)
+		retry   bool
+		wantErr bool
+	}{
+		{"Connected", fakeTelegramAPI(testPrivateChat), false, false},
+		{"Group", fakeTelegramAPI(`{"ok":true,"result":{"id":-1,"type":"supergroup","title":"Nodes"}}`), false, false},
+		{"Unreachable", func(string) (string, error) { return "", errors.New("network is unreachable") }, true, true},
+		{"Unauthorized", func(string) (string, error) {
+			return `{"ok":false,"error_code":401,"description":"Unauthorized"}`, nil
+		}, false, true},
+		{"Channel", fakeTelegramAPI(`{"ok":true,"result":{"id":-1,"type":"channel"}}`), false, true},
+		{"Chat unreachable", func(path string) (string, error) {
+			if strings.HasSuffix(path, "/getChat") {
+				return "", errors.New("network is unreachable")
+			}
+			return testGetMe, nil
+		}, true, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bot, ft := newTestBot(t, reloadTestConfig())
+			defer removeTestState(bot)
+			// Errors include the API URL, which contains the API key
+			bot.telegram.Token = bot.config.Telegram.APIKey
+			ft.setFail(tc.fail)
+
+			retry, err := bot.connect()
+			if (err != nil) != tc.wantErr || retry != tc.retry {
+				t.Fatalf("Expected: error %v (retry %v), got %v (retry %v)", tc.wantErr, tc.retry, err, retry)
+			}
+			if err != nil && strings.Contains(err.Error(), bot.config.Telegram.APIKey) {
+				t.Errorf("Expected: the API key to be redacted, got %v", err)
+			}
+			if bot.isConnected() == tc.wantErr {
+				t.Errorf("Expected: connected to be %v", !tc.wantErr)
+			}
+			if !tc.wantErr && bot.telegram.Self.UserName != "wcbot" {
+				t.Errorf("Expected: the Bot user to be identified, got %+v", bot.telegram.Self)
+			}
+		})
+	}
+}
+
+func Test_PollUpdates_Reconnects(t *testing.T) {
+	bot, ft := newTestBot(t, reloadTestConfig())
+	defer removeTestState(bot)
+
+	// Telegram can not be reached when the startup message is sent
+	ft.setFail(func(string) (string, error) { return "", errors.New("network is unreachable") })
+	if err := bot.SendNewMessage("text", "Started"); err != nil {
+		t.Fatalf("Expected: the message to be queued, got %v", err)
+	}
+	// Delay the retry, so it can only be delivered once reconnected
+	bot.outbox.m.Lock()
+	bot.outbox.pending[0].due = time.Now().Add(time.Hour)
+	bot.outbox.m.Unlock()
+
+	polls := 0
+	connected := fakeTelegramAPI(testPrivateChat)
+	ft.setFail(func(path string) (string, error) {
+		if !strings.HasSuffix(path, "/getUpdates") {
+			return connected(path)
+		}
+		polls++
+		switch polls {
+		case 1:
+			return "", errors.New("network is unreachable")
+		case 2:
+			return `{"ok":true,"result":[{"update_id":5,"message":{"message_id":1,"text":"/help","chat":{"id":123456789,"type":"private"}}}]}`, nil
+		}
+		time.Sleep(10 * time.Millisecond)
+		return `{"ok":true,"result":[]}`, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bot.outbox.run(ctx)
+	updates := make(chan tgbotapi.Update)
+	go bot.pollUpdates(ctx, updates)
+
+	select {
+	case update := <-updates:
+		if update.UpdateID != 5 || update.Message.Text != "/help" {
+			t.Errorf("Unexpected update: %+v", update)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected: an update once reconnected")
+	}
+	if !bot.isConnected() {
+		t.Errorf("Expected: connected")
+	}
+
+	if sent := waitForSent(t, ft, 1); !strings.Contains(sent[0], "Started") {
+		t.Errorf("Expected: the startup message to be delivered, got %v", sent)
+	}
+}
+
+// syncBuffer is a bytes.Buffer which can be written to by concurrent loggers
+type syncBuffer struct {
+	m   sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.buf.String()
+}
+
+func Test_APIKeyNotLeaked(t *testing.T) {
+	bot, ft := newTestBot(t, reloadTestConfig())
+	defer removeTestState(bot)
+	apiKey := bot.config.Telegram.APIKey
+	// Errors include the API URL, which contains the API key
+	bot.telegram.Token = apiKey
+	bot.outbox = bot.newOutbox()
+
+	var logged syncBuffer
+	level := log.GetLevel()
+	log.SetOutput(&logged)
+	log.SetLevel(log.DebugLevel)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetLevel(level)
+	}()
+
+	// Telegram can not be reached
+	ft.setFail(func(string) (string, error) { return "", errors.New("network is unreachable") })
+	var errs []error
+	_, err := bot.connect()
+	errs = append(errs, err, bot.CheckHealth())
+	if err := bot.SendNewMessage("text", "Node disconnected"); err != nil {
+		t.Fatal(err)
+	}
+	bot.outbox.retryNow()
+	bot.outbox.attempt()
+
+	ctx := newTestCommandCtx(1001, "admin", "/2fasetup")
+	ctx.User.Admin = true
+	errs = append(errs, bot.handleCommandTwoFactorSetup(ctx, "2fasetup", ""))
+
+	pollctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	bot.pollUpdates(pollctx, make(chan tgbotapi.Update))
+
+	for _, err := range errs {
+		if err == nil {
+			t.Fatal("Expected: an error when Telegram can not be reached")
+		}
+		if strings.Contains(err.Error(), apiKey) {
+			t.Errorf("Expected: the API key to be redacted, got %v", err)
+		}
+	}
+
+	// Once Telegram can be reached again the queued messages are delivered
+	ft.setFail(nil)
+	bot.outbox.retryNow()
+	if !bot.outbox.drain(time.Now().Add(5 * time.Second)) {
+		t.Fatal("Expected: the queue to be drained")
+	}
+	sent := waitForSent(t, ft, 2)
+	for _, s := range sent {
+		// The request path contains the API key, so only the message itself is checked
+		text, _ := url.QueryUnescape(strings.SplitN(s, "?", 2)[1])
+		if strings.Contains(text, apiKey) {
+			t.Errorf("Expected: the API key not to be sent, got %s", text)
+		}
+	}
+
+	output := logged.String()
+	if !strings.Contains(output, "will retry") || !strings.Contains(output, "can not be reached") {
+		t.Errorf("Expected: the failures to be logged, got %s", output)
+	}
+	if strings.Contains(output, apiKey) {
+		t.Errorf("Expected: the API key not to be logged, got %s", output)
+	}
+}
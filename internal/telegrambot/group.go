@@ -0,0 +1,180 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package telegrambot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	log "github.com/sirupsen/logrus"
+	tgbotapi "gopkg.in/telegram-bot-api.v4"
+)
+
+// isGroupChat determines if the message in the provided BotContext was sent within a group chat
+func (ctx *BotContext) isGroupChat() bool {
+	return ctx != nil && ctx.message != nil && ctx.message.Chat != nil &&
+		(ctx.message.Chat.IsGroup() || ctx.message.Chat.IsSuperGroup())
+}
+
+func (bot *Bot) handleUserJoin(ctx *BotContext, user *tgbotapi.User) error {
+	if user.ID == bot.telegram.Self.ID {
+		log.Infof("Bot.handleUserJoin: I have joined the group %d", ctx.message.Chat.ID)
+		return nil
+	}
+
+	u := &User{ID: user.ID, UserName: user.UserName, FirstName: user.FirstName, LastName: user.LastName}
+	u.Admin = bot.isAdminID(u.ID)
+	log.Infof("Bot.handleUserJoin: User joined: %s (authorized: %v)", u.NameAndTags(), u.Admin || bot.isMemberID(u.ID))
+	return nil
+}
+
+func (bot *Bot) handleUserLeft(ctx *BotContext, user *tgbotapi.User) error {
+	if user.ID == bot.telegram.Self.ID {
+		log.Infof("Bot.handleUserLeft: I have left the group %d", ctx.message.Chat.ID)
+		return nil
+	}
+
+	u := &User{ID: user.ID, UserName: user.UserName, FirstName: user.FirstName, LastName: user.LastName}
+	u.Admin = bot.isAdminID(u.ID)
+	log.Infof("Bot.handleUserLeft: User left: %s", u.NameAndTags())
+	return nil
+}
+
+// removeMyName removes any mention of the Bot (i.e. `@botname`) from the provided text
+// and reports if a mention was found
+func (bot *Bot) removeMyName(text string) (string, bool) {
+	var removed bool
+	var words []string
+	for _, word := range strings.Fields(text) {
+		if strings.EqualFold(word, "@"+bot.telegram.Self.UserName) {
+			removed = true
+			continue
+		}
+		words = append(words, word)
+	}
+	return strings.Join(words, " "), removed
+}
+
+// isReplyToMe determines if the message in the provided BotContext is a reply to a message from the Bot
+func (bot *Bot) isReplyToMe(ctx *BotContext) bool {
+	if re := ctx.message.ReplyToMessage; re != nil {
+		if u := re.From; u != nil {
+			if u.ID == bot.telegram.Self.ID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isCommandToMe determines if the command in the provided BotContext is addressed to the Bot.
+// Within groups, commands may be addressed to a specific bot (i.e. `/status@botname`).
+// Commands without a bot name are assumed to be for us.
+func (bot *Bot) isCommandToMe(ctx *BotContext) bool {
+	entities := ctx.message.Entities
+	if entities == nil || len(*entities) == 0 {
+		return false
+	}
+
+	entity := (*entities)[0]
+	if entity.Offset != 0 || entity.Length > len(ctx.message.Text) {
+		return false
+	}
+
+	command := ctx.message.Text[1:entity.Length]
+	if i := strings.Index(command, "@"); i >= 0 {
+		return strings.EqualFold(command[i+1:], bot.telegram.Self.UserName)
+	}
+	return true
+}
+
+func (bot *Bot) handleGroupMessage(ctx *BotContext) error {
+	var gerr error
+
+	if u := ctx.message.NewChatMembers; u != nil {
+		for _, user := range *u {
+			if err := bot.handleUserJoin(ctx, &user); err != nil {
+				gerr = err
+			}
+		}
+	}
+
+	if u := ctx.message.LeftChatMember; u != nil {
+		if err := bot.handleUserLeft(ctx, u); err != nil {
+			gerr = err
+		}
+	}
+
+	if ctx.User == nil {
+		return gerr
+	}
+
+	if ctx.message.IsCommand() {
+		if !bot.isCommandToMe(ctx) {
+			log.Debugf("Bot.handleGroupMessage: Ignoring command for another bot: %s", ctx.message.Text)
+			return gerr
+		}
+
+		cmd, args := ctx.message.Command(), ctx.message.CommandArguments()
+		if !bot.authorizeUser(ctx, cmd) {
+			log.Debugf("Bot.handleGroupMessage: Ignoring command from unauthorized user %s", ctx.User.NameAndTags())
+			return gerr
+		}
+		return bot.handleGroupCommand(ctx, cmd, args)
+	}
+
+	msgWithoutName, mentioned := bot.removeMyName(ctx.message.Text)
+	if mentioned || bot.isReplyToMe(ctx) {
+		var cmd string
+		if fields := strings.Fields(msgWithoutName); len(fields) > 0 {
+			cmd = strings.TrimPrefix(fields[0], "/")
+		}
+		if !bot.authorizeUser(ctx, cmd) {
+			log.Debugf("Bot.handleGroupMessage: Ignoring mention from unauthorized user %s", ctx.User.NameAndTags())
+			return gerr
+		}
+
+		for i := len(bot.groupMessageHandlers) - 1; i >= 0; i-- {
+			handler := bot.groupMessageHandlers[i]
+			next, err := handler(bot, ctx, msgWithoutName)
+			if err != nil {
+				return fmt.Errorf("group message handler failed: %v", err)
+			}
+			if !next {
+				break
+			}
+		}
+	}
+	return gerr
+}
+
+// handleGroupCommand dispatches a command received within a group chat and replies
+// to the sender within the group if it could not be handled
+func (bot *Bot) handleGroupCommand(ctx *BotContext, cmd, args string) error {
+	err := bot.handleCommand(ctx, cmd, args)
+	if err == errAdminOnly {
+		log.Debugf("Bot.handleGroupCommand: '/%s' requested by non-Admin %s", cmd, ctx.User.NameAndTags())
+		return bot.Reply(ctx, "text", fmt.Sprintf(wcconst.MsgAdminOnly, cmd))
+	} else if err != nil {
+		log.Debugf("Bot.handleGroupCommand: '/%s' failed: %v", cmd, err)
+		return bot.Reply(ctx, "markdown", fmt.Sprintf("Sorry,'/%s' is an unknown command.\n\n%s", cmd, wcconst.MsgHelpShort))
+	}
+	return nil
+}
+
+// handleGroupMentionCommand treats the first word of a message which mentions (or replies to)
+// the Bot as a command, i.e. `@botname status` is handled the same as `/status`
+func (bot *Bot) handleGroupMentionCommand(ctx *BotContext, text string) (bool, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return true, nil
+	}
+
+	cmd := strings.ToLower(strings.TrimPrefix(fields[0], "/"))
+	args := strings.Join(fields[1:], " ")
+	return false, bot.handleGroupCommand(ctx, cmd, args)
+}
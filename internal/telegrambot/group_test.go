@@ -0,0 +1,111 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package telegrambot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+	tgbotapi "gopkg.in/telegram-bot-api.v4"
+)
+
+const testGroupChatID = -100123
+
+// newTestGroupCtx creates a BotContext for a group message sent by the provided user
+func newTestGroupCtx(id int, username, text string) *BotContext {
+	ctx := newTestCommandCtx(id, username, text)
+	ctx.message.Chat = &tgbotapi.Chat{ID: testGroupChatID, Type: "supergroup", Title: "Skyfleet"}
+	return ctx
+}
+
+func newTestGroupBot(t *testing.T) (*Bot, *fakeTelegram) {
+	var config wcconfig.Config
+	config.Telegram.ChatID = testGroupChatID
+	config.Telegram.Admin = "@TESTADMIN"
+	config.Telegram.AdminIDs = []int{1001}
+	config.Telegram.Members = []int{2002}
+	bot, ft := newTestBot(t, config)
+	bot.telegram.Self = tgbotapi.User{ID: 42, UserName: "WingCommanderBot"}
+	return bot, ft
+}
+
+func Test_RemoveMyName(t *testing.T) {
+	bot, _ := newTestGroupBot(t)
+	defer removeTestState(bot)
+
+	text, mentioned := bot.removeMyName("@wingcommanderbot status please")
+	if !mentioned {
+		t.Error("Expected: Mention should be detected (case insensitive)")
+	}
+	if text != "status please" {
+		t.Errorf("Unexpected text: %q", text)
+	}
+
+	if _, mentioned := bot.removeMyName("status @SomeoneElse"); mentioned {
+		t.Error("Expected: Mention of another user should not be detected")
+	}
+}
+
+func Test_IsCommandToMe(t *testing.T) {
+	bot, _ := newTestGroupBot(t)
+	defer removeTestState(bot)
+
+	if !bot.isCommandToMe(newTestGroupCtx(1001, "", "/status")) {
+		t.Error("Expected: Command without a bot name should be for us")
+	}
+	if !bot.isCommandToMe(newTestGroupCtx(1001, "", "/status@WingCommanderBot")) {
+		t.Error("Expected: Command addressed to us should be for us")
+	}
+	if bot.isCommandToMe(newTestGroupCtx(1001, "", "/status@OtherBot")) {
+		t.Error("Expected: Command addressed to another bot should not be for us")
+	}
+}
+
+func Test_HandleGroupMessage_Authorization(t *testing.T) {
+	bot, ft := newTestGroupBot(t)
+	defer removeTestState(bot)
+
+	// Members can use non-Admin commands
+	if err := bot.handleMessage(newTestGroupCtx(2002, "member", "/help")); err != nil {
+		t.Error(err)
+	}
+	if n := len(ft.sent("sendMessage")); n != 1 {
+		t.Errorf("Expected: 1 message to be sent for /help, got %d", n)
+	}
+
+	// Members can not use Admin commands
+	if err := bot.handleMessage(newTestGroupCtx(2002, "member", "/stop")); err != nil {
+		t.Error(err)
+	}
+	sent := ft.sent("sendMessage")
+	if n := len(sent); n != 2 || !strings.Contains(sent[1], "restricted") {
+		t.Errorf("Expected: Admin only reply for /stop, got %v", sent)
+	}
+
+	// Regular group chatter and commands from unknown users are ignored
+	if err := bot.handleMessage(newTestGroupCtx(3003, "stranger", "hello everyone")); err != nil {
+		t.Error(err)
+	}
+	if n := len(ft.sent("sendMessage")); n != 2 {
+		t.Errorf("Expected: No messages to be sent for group chatter, got %d", n)
+	}
+	if err := bot.handleMessage(newTestGroupCtx(3003, "stranger", "/help@WingCommanderBot")); err != nil {
+		t.Error(err)
+	}
+	sent = ft.sent("sendMessage")
+	if n := len(sent); n != 3 || !strings.Contains(sent[2], "unknown+Telegram+user") {
+		t.Errorf("Expected: Unknown user to be reported, got %v", sent)
+	}
+
+	// Admins can use Admin commands by mentioning the Bot
+	if err := bot.handleMessage(newTestGroupCtx(1001, "admin", "@WingCommanderBot stop")); err != nil {
+		t.Error(err)
+	}
+	sent = ft.sent("sendMessage")
+	if n := len(sent); n != 4 || strings.Contains(sent[3], "restricted") {
+		t.Errorf("Expected: Admin to be able to /stop, got %v", sent)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package telegrambot
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/hostmon"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcaudit"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	log "github.com/sirupsen/logrus"
+)
+
+// hostName returns the name of the host Wing Commander is running on
+func hostName() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "(unknown)"
+	}
+	return name
+}
+
+// hostThresholds returns the configured limits of the host metrics
+func (bot *Bot) hostThresholds() hostmon.Thresholds {
+	host := bot.getConfig().Host
+	return hostmon.Thresholds{
+		MaxLoad:        host.MaxLoad,
+		MaxMemPct:      host.MaxMemPct,
+		MinDiskFreePct: host.MinDiskFreePct,
+		MaxTempC:       host.MaxTempC,
+	}
+}
+
+// checkHost collects the host metrics and alerts the Admin when a metric exceeds its
+// limit. Each breach is only alerted once, and the Admin is notified once it recovers.
+func (bot *Bot) checkHost() error {
+	m, err := bot.getHostCollector().Collect()
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]bool)
+	var alerts []string
+	bot.m.Lock()
+	for _, b := range bot.hostThresholds().Check(m) {
+		current[b.Metric] = true
+		if !bot.hostBreaches[b.Metric] {
+			alerts = append(alerts, b.Message)
+		}
+	}
+	var recovered []string
+	for metric := range bot.hostBreaches {
+		if !current[metric] {
+			recovered = append(recovered, metric)
+		}
+	}
+	bot.hostBreaches = current
+	bot.m.Unlock()
+
+	if len(alerts) > 0 {
+		log.Warnf("Bot.checkHost: %s", strings.Join(alerts, "; "))
+		if err := bot.SendNewMessage("text", fmt.Sprintf(wcconst.MsgHostAlert, hostName(), strings.Join(alerts, "\n"))); err != nil {
+			return err
+		}
+	}
+	if len(recovered) > 0 {
+		log.Infof("Bot.checkHost: Recovered: %v", recovered)
+		return bot.SendNewMessage("text", fmt.Sprintf(wcconst.MsgHostRecovered, hostName(), strings.Join(recovered, ", ")))
+	}
+	return nil
+}
+
+// hostMonitorLoop periodically checks the host metrics until the provided context is cancelled.
+// The check interval is configured by `host.checkintmin` (0 disables the checks).
+func (bot *Bot) hostMonitorLoop(runctx context.Context) {
+	interval := bot.getConfig().Host.CheckIntMin
+	if interval <= 0 {
+		log.Infoln("Bot.hostMonitorLoop: Host monitoring is disabled.")
+		return
+	}
+	if runtime.GOOS != "linux" {
+		log.Infof("Bot.hostMonitorLoop: Host monitoring is not supported on %s.", runtime.GOOS)
+		return
+	}
+
+	log.Infof("Bot.hostMonitorLoop: Checking host resources every %v", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := bot.checkHost(); err != nil {
+			log.Errorf("Bot.hostMonitorLoop: Host check failed: %v", err)
+		}
+
+		select {
+		case <-runctx.Done():
+			log.Debugln("Bot.hostMonitorLoop: Stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Handler for host command
+func (bot *Bot) handleCommandHost(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	m, err := bot.getHostCollector().Collect()
+	if err != nil {
+		log.Errorf("Bot.handleCommandHost: %v", err)
+		ctx.setAuditOutcome(wcaudit.OutcomeFailed, err)
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgHostFailed, err))
+	}
+
+	text := m.String()
+	for _, b := range bot.hostThresholds().Check(m) {
+		text += "\n⚠️ " + b.Message
+	}
+
+	err = bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgHost, hostName(), text))
+	if err != nil {
+		logSendError("Bot.handleCommandHost", err)
+	}
+	return err
+}
@@ -0,0 +1,116 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package telegrambot
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/hostmon"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+)
+
+// writeHostFixture writes the /proc and /sys files read by hostmon under root,
+// with the thermal zone reporting the provided temperature (in millidegrees)
+func writeHostFixture(t *testing.T, root, millic string) {
+	files := map[string]string{
+		"proc/loadavg":                         "0.10 0.20 0.30 1/100 1234\n",
+		"proc/stat":                            "cpu  1 2 3 4\ncpu0 1 2 3 4\ncpu1 1 2 3 4\n",
+		"proc/meminfo":                         "MemTotal: 1000 kB\nMemAvailable: 500 kB\n",
+		"proc/uptime":                          "3600.00 7000.00\n",
+		"sys/class/thermal/thermal_zone0/temp": millic + "\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newTestHostBot(t *testing.T) (*Bot, *fakeTelegram, string) {
+	if runtime.GOOS != "linux" {
+		t.Skip("host monitoring is only supported on Linux")
+	}
+
+	var config wcconfig.Config
+	config.Telegram.AdminIDs = []int{1001}
+	config.Host.MaxTempC = 60
+	bot, ft := newTestBot(t, config)
+
+	root := filepath.Join(filepath.Dir(bot.state.Path()), "host")
+	writeHostFixture(t, root, "45000")
+	bot.hostCollector = &hostmon.Collector{Root: root, DiskPath: root}
+	return bot, ft, root
+}
+
+func Test_CheckHost_AlertsOnceAndRecovers(t *testing.T) {
+	bot, ft, root := newTestHostBot(t)
+	defer removeTestState(bot)
+
+	if err := bot.checkHost(); err != nil {
+		t.Fatal(err)
+	}
+	if sent := ft.sent("sendMessage"); len(sent) != 0 {
+		t.Fatalf("Expected: no alert, got %v", sent)
+	}
+
+	writeHostFixture(t, root, "71500")
+	for i := 0; i < 2; i++ {
+		if err := bot.checkHost(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sent := ft.sent("sendMessage")
+	if len(sent) != 1 {
+		t.Fatalf("Expected: 1 alert, got %d", len(sent))
+	}
+	msg, _ := url.QueryUnescape(sent[0])
+	if !strings.Contains(msg, "Temperature 71.5°C exceeds 60.0°C") {
+		t.Errorf("Unexpected alert: %s", msg)
+	}
+
+	writeHostFixture(t, root, "50000")
+	if err := bot.checkHost(); err != nil {
+		t.Fatal(err)
+	}
+	sent = ft.sent("sendMessage")
+	if len(sent) != 2 {
+		t.Fatalf("Expected: recovery notification, got %d messages", len(sent))
+	}
+	msg, _ = url.QueryUnescape(sent[1])
+	if !strings.Contains(msg, "recovered: temperature") {
+		t.Errorf("Unexpected recovery notification: %s", msg)
+	}
+}
+
+func Test_HandleCommandHost(t *testing.T) {
+	bot, ft, _ := newTestHostBot(t)
+	defer removeTestState(bot)
+
+	ctx := newTestCommandCtx(1001, "admin", "/host")
+	if err := bot.handleMessage(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := ft.sent("sendMessage")
+	if len(sent) != 1 {
+		t.Fatalf("Expected: 1 message, got %d", len(sent))
+	}
+	msg, _ := url.QueryUnescape(sent[0])
+	for _, expect := range []string{"Load: 0.10 0.20 0.30 (2 CPUs)", "Temperature: 45.0°C", "Uptime: 1h0m0s"} {
+		if !strings.Contains(msg, expect) {
+			t.Errorf("Expected: %q in %s", expect, msg)
+		}
+	}
+}
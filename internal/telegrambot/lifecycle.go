@@ -0,0 +1,104 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package telegrambot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	log "github.com/sirupsen/logrus"
+	tgbotapi "gopkg.in/telegram-bot-api.v4"
+)
+
+// context returns the context the Bot is running within (see Start). The background loops and
+// the Manager monitor are cancelled when it is done.
+func (bot *Bot) context() context.Context {
+	bot.m.Lock()
+	defer bot.m.Unlock()
+	if bot.runctx == nil {
+		return context.Background()
+	}
+	return bot.runctx
+}
+
+// goBackground runs fn in the background. Shutdown waits for it to return, so that
+// notifications which are being sent when the Bot is stopped are not lost.
+func (bot *Bot) goBackground(fn func()) {
+	bot.background.Add(1)
+	go func() {
+		defer bot.background.Done()
+		fn()
+	}()
+}
+
+// waitBackground waits up to timeout for the functions run by goBackground to return,
+// and reports if they did
+func (bot *Bot) waitBackground(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		bot.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// stopLoops stops the background loops and the Manager monitor
+func (bot *Bot) stopLoops() {
+	bot.m.Lock()
+	for name, stop := range bot.loops {
+		stop()
+		delete(bot.loops, name)
+	}
+	bot.m.Unlock()
+
+	bot.skyMgrMonitor.StopManagerMonitor()
+}
+
+// sendBefore sends c directly (without queuing it), giving up at the deadline
+func (bot *Bot) sendBefore(deadline time.Time, c tgbotapi.Chattable) error {
+	api := *bot.telegram
+	api.Client = &http.Client{Transport: bot.telegram.Client.Transport, Timeout: time.Until(deadline)}
+	_, err := api.Send(c)
+	return err
+}
+
+// Shutdown stops the Bot. The background loops and the Manager monitor are stopped, and
+// notifications which are being sent, or are waiting to be retried, are given up to timeout
+// to be delivered. Alerts which are still waiting are delivered after a restart. A final
+// message, including the reason for the shutdown, is then sent to the configured chat if
+// there is time left. The context provided to Start should be cancelled first.
+func (bot *Bot) Shutdown(reason string, timeout time.Duration) {
+	log.Infof("Bot.Shutdown: Shutting down (%s)", reason)
+	defer log.Infoln("Bot.Shutdown: Complete")
+
+	deadline := time.Now().Add(timeout)
+	bot.stopLoops()
+	if !bot.waitBackground(time.Until(deadline)) {
+		log.Warnf("Bot.Shutdown: Background tasks did not stop within %v", timeout)
+	}
+	if !bot.outbox.drain(deadline) {
+		log.Warnf("Bot.Shutdown: %d messages could not be delivered within %v", bot.outbox.getStats().Pending, timeout)
+	}
+
+	// This is sent once, after the queued messages. It is not queued (nor persisted), as it
+	// would otherwise be delivered after the next start if Telegram can not be reached.
+	config := bot.getConfig()
+	msg, _ := newMessage(config.Telegram.ChatID, "markdown", fmt.Sprintf(wcconst.MsgShuttingDown, reason))
+	if time.Until(deadline) <= 0 {
+		log.Warnf("Bot.Shutdown: The shutdown message was not sent, as no time is left within %v", timeout)
+	} else if err := bot.sendBefore(deadline, msg); err != nil {
+		log.Errorf("Bot.Shutdown: Failed to send the shutdown message: %s", config.Redact(err.Error()))
+	}
+	bot.telemetry.Close()
+}
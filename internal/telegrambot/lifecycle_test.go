@@ -0,0 +1,101 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package telegrambot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Shutdown_DrainsNotifications(t *testing.T) {
+	bot, ft := newTestBot(t, reloadTestConfig())
+	defer removeTestState(bot)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bot.runctx = ctx
+
+	// A background loop which is sending a notification when the Bot is stopped
+	loopctx, stop := context.WithCancel(bot.context())
+	bot.loops["host"] = stop
+	bot.goBackground(func() {
+		<-loopctx.Done()
+		time.Sleep(50 * time.Millisecond)
+		if err := bot.SendNewMessage("text", "notification"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	cancel()
+	bot.Shutdown("terminated", 5*time.Second)
+
+	sent := ft.sent("sendMessage")
+	if len(sent) != 2 || !strings.Contains(sent[0], "notification") {
+		t.Fatalf("Expected: the notification to be sent before shutting down, got %v", sent)
+	}
+	if msg := lastReloadMsg(ft); !strings.Contains(msg, "shutting down* (terminated)") {
+		t.Errorf("Expected: a shutting down message, got %s", msg)
+	}
+	if len(bot.loops) != 0 {
+		t.Errorf("Expected: all loops to be stopped, got %v", bot.loops)
+	}
+}
+
+func Test_Shutdown_Timeout(t *testing.T) {
+	bot, ft := newTestBot(t, reloadTestConfig())
+	defer removeTestState(bot)
+
+	// A background task which does not stop does not prevent the shutdown
+	block := make(chan struct{})
+	defer close(block)
+	bot.goBackground(func() { <-block })
+
+	start := time.Now()
+	bot.Shutdown("interrupt", 50*time.Millisecond)
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Expected: Shutdown to give up after its timeout")
+	}
+	// No time is left to send the shutting down message
+	if sent := ft.sent("sendMessage"); len(sent) != 0 {
+		t.Errorf("Expected: no shutting down message, got %v", sent)
+	}
+}
+
+// stalledTransport is a http.RoundTripper whose requests never complete (until cancelled)
+type stalledTransport struct{}
+
+func (stalledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func Test_Shutdown_StalledTelegram(t *testing.T) {
+	bot, _ := newTestBot(t, reloadTestConfig())
+	defer removeTestState(bot)
+
+	// The shutting down message is given up at the deadline, when Telegram does not respond
+	bot.telegram.Client.Transport = stalledTransport{}
+	start := time.Now()
+	bot.Shutdown("terminated", 100*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected: Shutdown to give up at its timeout, took %v", elapsed)
+	}
+}
+
+func Test_Shutdown_NotPersisted(t *testing.T) {
+	bot, ft := newTestBot(t, reloadTestConfig())
+	defer removeTestState(bot)
+	bot.outbox = bot.newOutbox()
+
+	// The shutting down message is not delivered after the next start
+	ft.setFail(func(string) (string, error) { return "", errors.New("network is unreachable") })
+	bot.Shutdown("terminated", 50*time.Millisecond)
+	if alerts := bot.state.GetPendingAlerts(); len(alerts) != 0 {
+		t.Errorf("Expected: the shutting down message not to be persisted, got %+v", alerts)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package telegrambot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	log "github.com/sirupsen/logrus"
+)
+
+// telegramLogger logs the output of the Telegram Bot API to the application log. Requests and
+// responses (which are only produced when `telegram.debug` is set) are logged at the debug level,
+// so full payloads are only logged when both are enabled. Other output (such as failing to get
+// updates) is logged as a warning.
+type telegramLogger struct{}
+
+func (telegramLogger) Println(v ...interface{}) {
+	log.Warnf("Telegram API: %s", strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
+
+func (telegramLogger) Printf(format string, v ...interface{}) {
+	log.Debugf("Telegram API: "+strings.TrimSuffix(format, "\n"), v...)
+}
+
+// Handler for loglevel command. Shows the log level, or changes it while running
+// (as /set log.level would).
+func (bot *Bot) handleCommandLogLevel(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	if level := strings.ToLower(strings.TrimSpace(args)); level != "" {
+		return bot.setSetting(ctx, "log.level", level)
+	}
+
+	err := bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgLogLevel, log.GetLevel()))
+	if err != nil {
+		logSendError("Bot.handleCommandLogLevel", err)
+	}
+	return err
+}
@@ -0,0 +1,83 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package telegrambot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	"github.com/BigOokie/skywire-wing-commander/internal/wclog"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultLogEntries is the number of log entries shown by /logs when no number is provided
+	defaultLogEntries = 20
+	// maxLogMessageLen is the length of the longest log shown within a message (Telegram
+	// messages are limited to 4096 characters). Longer logs are sent as a document.
+	maxLogMessageLen = 3500
+)
+
+// parseLogsArgs parses the arguments of /logs, which are an optional number of entries and an
+// optional minimum level (in any order). When only a level is provided, all retained entries at
+// or above it are returned.
+func parseLogsArgs(args string) (n int, level log.Level, err error) {
+	n, level = defaultLogEntries, log.TraceLevel
+	nSet, levelSet := false, false
+	for _, arg := range strings.Fields(args) {
+		if i, err := strconv.Atoi(arg); err == nil && i > 0 {
+			n, nSet = i, true
+			continue
+		}
+		if level, err = log.ParseLevel(arg); err != nil {
+			return 0, 0, err
+		}
+		levelSet = true
+	}
+	if levelSet && !nSet {
+		n = 0
+	}
+	return n, level, nil
+}
+
+// Handler for logs command. Shows the most recent log entries, optionally filtered by level.
+func (bot *Bot) handleCommandLogs(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	n, level, err := parseLogsArgs(args)
+	if err != nil {
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", wcconst.MsgLogsUsage)
+	}
+
+	entries := wclog.Recent(n, level)
+	if len(entries) == 0 {
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", wcconst.MsgLogsEmpty)
+	}
+
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		lines[i] = e.String()
+	}
+	// Log entries may include secrets, such as errors including the Telegram API URL
+	config := bot.getConfig()
+	text := config.Redact(strings.Join(lines, "\n"))
+
+	if len(text) <= maxLogMessageLen {
+		err = bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgLogs, len(entries), text))
+	} else {
+		name := fmt.Sprintf("wcbot-%s.log", time.Now().Format("20060102-150405"))
+		err = bot.SendDocument(ctx, getSendModeforContext(ctx), name, []byte(text+"\n"),
+			fmt.Sprintf(wcconst.MsgLogsDocument, len(entries)))
+	}
+	if err != nil {
+		logSendError("Bot.handleCommandLogs", err)
+	}
+	return err
+}
@@ -0,0 +1,211 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package telegrambot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/skymgrmon"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcaudit"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	log "github.com/sirupsen/logrus"
+	tgbotapi "gopkg.in/telegram-bot-api.v4"
+)
+
+// nodeKeyPrefixLen is the number of characters of a Node key used to identify the Node
+// within inline button data (Telegram limits callback data to 64 bytes)
+const nodeKeyPrefixLen = 16
+
+// nodeControlCommands are commands which act on the Skyminer. These always require
+// confirmation, even when two factor confirmation is not enabled.
+var nodeControlCommands = map[string]bool{
+	"restartnode": true,
+	"startapp":    true,
+	"stopapp":     true,
+	"restartapp":  true,
+	"rebootall":   true,
+}
+
+// nodeKeyCommands are the node control commands whose first argument is a Node key
+var nodeKeyCommands = map[string]bool{
+	"restartnode": true,
+	"startapp":    true,
+	"stopapp":     true,
+	"restartapp":  true,
+}
+
+// resolveNodeKeyArg replaces the (possibly abbreviated) Node key argument of a node control
+// command with the full key. The full key is then shown when the command is confirmed, and
+// the confirmation can only act on that Node.
+func (bot *Bot) resolveNodeKeyArg(command, args string) (string, error) {
+	fields := strings.Fields(args)
+	if !nodeKeyCommands[command] || len(fields) == 0 {
+		return args, nil
+	}
+	key, err := bot.skyMgrMonitor.FindNodeKey(fields[0])
+	if err != nil {
+		return args, err
+	}
+	fields[0] = key
+	return strings.Join(fields, " "), nil
+}
+
+// shortNodeKey returns the abbreviated form of a Node key
+func shortNodeKey(key string) string {
+	if len(key) > nodeKeyPrefixLen {
+		return key[:nodeKeyPrefixLen]
+	}
+	return key
+}
+
+// logControlAction logs the outcome of a control action performed on the Skyminer.
+// Failures are reported to the user (not returned), so are also flagged for the audit log.
+func (bot *Bot) logControlAction(ctx *BotContext, action, target string, err error) {
+	if err != nil {
+		ctx.setAuditOutcome(wcaudit.OutcomeFailed, err)
+		log.Errorf("Bot.NodeControl: %s %s requested by %s failed: %v", action, target, ctx.User.NameAndTags(), err)
+		return
+	}
+	log.Infof("Bot.NodeControl: %s %s requested by %s succeeded", action, target, ctx.User.NameAndTags())
+}
+
+// replyControlResult sends the outcome of a control action to the user
+func (bot *Bot) replyControlResult(ctx *BotContext, from, success string, err error) error {
+	format, msg := "markdown", success
+	if err != nil {
+		// Errors may contain characters which are not valid markdown
+		format, msg = "text", fmt.Sprintf(wcconst.MsgNodeControlFailed, err)
+	}
+	sendErr := bot.Send(ctx, getSendModeforContext(ctx), format, msg)
+	if sendErr != nil {
+		logSendError(from, sendErr)
+	}
+	return sendErr
+}
+
+// Handler for nodes command. Presents an inline keyboard to pick a Node to control.
+func (bot *Bot) handleCommandNodes(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	nodes, err := bot.skyMgrMonitor.GetAllNodes()
+	if err != nil {
+		log.Errorf("Bot.handleCommandNodes: %v", err)
+		return bot.Send(ctx, getSendModeforContext(ctx), "markdown", wcconst.MsgErrorGetNodes)
+	}
+
+	if len(nodes) == 0 {
+		return bot.Send(ctx, getSendModeforContext(ctx), "markdown", wcconst.MsgNoConnectedNodes)
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, n := range nodes {
+		prefix := shortNodeKey(n.Key)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(prefix+"…", "node "+prefix)))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔁 Reboot all Nodes", "rebootall")))
+
+	err = bot.SendReplyInlineKeyboard(ctx, tgbotapi.NewInlineKeyboardMarkup(rows...), wcconst.MsgSelectNode)
+	if err != nil {
+		logSendError("Bot.handleCommandNodes", err)
+	}
+	return err
+}
+
+// Handler for node command. Presents an inline keyboard of control actions for a Node.
+func (bot *Bot) handleCommandNode(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	key, err := bot.skyMgrMonitor.FindNodeKey(strings.TrimSpace(args))
+	if err != nil {
+		return bot.replyControlResult(ctx, "Bot.handleCommandNode", "", err)
+	}
+
+	prefix := shortNodeKey(key)
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔁 Restart Node", "restartnode "+prefix)),
+	}
+	for _, app := range skymgrmon.SupportedApps {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("▶️ "+app, fmt.Sprintf("startapp %s %s", prefix, app)),
+			tgbotapi.NewInlineKeyboardButtonData("⏹ "+app, fmt.Sprintf("stopapp %s %s", prefix, app)),
+			tgbotapi.NewInlineKeyboardButtonData("🔁 "+app, fmt.Sprintf("restartapp %s %s", prefix, app)),
+		))
+	}
+
+	err = bot.SendReplyInlineKeyboard(ctx, tgbotapi.NewInlineKeyboardMarkup(rows...), fmt.Sprintf(wcconst.MsgSelectNodeAction, key))
+	if err != nil {
+		logSendError("Bot.handleCommandNode", err)
+	}
+	return err
+}
+
+// Handler for restartnode command
+func (bot *Bot) handleCommandRestartNode(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	key, err := bot.skyMgrMonitor.FindNodeKey(strings.TrimSpace(args))
+	if err == nil {
+		err = bot.skyMgrMonitor.RestartNode(key)
+	}
+	bot.logControlAction(ctx, command, args, err)
+	return bot.replyControlResult(ctx, "Bot.handleCommandRestartNode", fmt.Sprintf(wcconst.MsgNodeRestarted, key), err)
+}
+
+// Handler for rebootall command
+func (bot *Bot) handleCommandRebootAll(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	count, err := bot.skyMgrMonitor.RebootAllNodes()
+	bot.logControlAction(ctx, command, fmt.Sprintf("(%d nodes)", count), err)
+	return bot.replyControlResult(ctx, "Bot.handleCommandRebootAll", fmt.Sprintf(wcconst.MsgNodesRebooted, count), err)
+}
+
+// parseNodeAppArgs parses the `<node key> <app>` arguments used by the app control commands
+func (bot *Bot) parseNodeAppArgs(args string) (key, app string, err error) {
+	fields := strings.Fields(args)
+	if len(fields) != 2 {
+		return "", "", fmt.Errorf("expected arguments: <node key> <app>")
+	}
+
+	app = fields[1]
+	if !skymgrmon.IsSupportedApp(app) {
+		return "", "", fmt.Errorf("unsupported app %s (supported: %s)", app, strings.Join(skymgrmon.SupportedApps, ", "))
+	}
+
+	key, err = bot.skyMgrMonitor.FindNodeKey(fields[0])
+	return key, app, err
+}
+
+// Handler for startapp, stopapp and restartapp commands
+func (bot *Bot) handleCommandNodeApp(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	key, app, err := bot.parseNodeAppArgs(args)
+	var done string
+	if err == nil {
+		switch command {
+		case "startapp":
+			err = bot.skyMgrMonitor.StartApp(key, app)
+			done = "started"
+		case "stopapp":
+			err = bot.skyMgrMonitor.StopApp(key, app)
+			done = "stopped"
+		default:
+			err = bot.skyMgrMonitor.RestartApp(key, app)
+			done = "restarted"
+		}
+	}
+	bot.logControlAction(ctx, command, args, err)
+	return bot.replyControlResult(ctx, "Bot.handleCommandNodeApp", fmt.Sprintf(wcconst.MsgNodeAppControlled, app, done, key), err)
+}
@@ -0,0 +1,139 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package telegrambot
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/skymgrmon"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+)
+
+const testNodeKey = "02a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90"
+
+// newFakeManager creates a fake Skywire Manager with a single connected Node
+// which records the reboot requests made to it
+func newFakeManager() (*httptest.Server, func() []string) {
+	var m sync.Mutex
+	var reboots []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/conn/getAll", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"key":%q,"type":"TCP"}]`, testNodeKey)
+	})
+	mux.HandleFunc("/conn/getNode", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"addr":"192.168.0.2:5000"}`)
+	})
+	mux.HandleFunc("/node/reboot", func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		reboots = append(reboots, r.FormValue("addr"))
+		m.Unlock()
+		fmt.Fprint(w, "true")
+	})
+
+	return httptest.NewServer(mux), func() []string {
+		m.Lock()
+		defer m.Unlock()
+		return append([]string(nil), reboots...)
+	}
+}
+
+func Test_NodeControl_RestartNodeRequiresConfirmation(t *testing.T) {
+	server, reboots := newFakeManager()
+	defer server.Close()
+
+	var config wcconfig.Config
+	config.Telegram.AdminIDs = []int{1001}
+	bot, ft := newTestBot(t, config)
+	defer removeTestState(bot)
+	bot.skyMgrMonitor = skymgrmon.NewMonitor(server.Listener.Addr().String(), "")
+
+	// The node picker lists the connected Nodes
+	if err := bot.handleMessage(newTestCommandCtx(1001, "admin", "/nodes")); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(lastSent(ft), "node+"+shortNodeKey(testNodeKey)) {
+		t.Errorf("Expected: Node picker, got %s", lastSent(ft))
+	}
+
+	// Restarting a Node requires confirmation even without two factor enabled
+	ctx := newTestCommandCtx(1001, "admin", "/restartnode")
+	ctx.User.Admin = true
+	if err := bot.handleCommand(ctx, "restartnode", shortNodeKey(testNodeKey)); err != nil {
+		t.Error(err)
+	}
+	if len(reboots()) != 0 {
+		t.Fatal("Expected: Node should not be restarted before confirmation")
+	}
+	// The confirmation shows (and is bound to) the full key of the Node
+	if !strings.Contains(lastSent(ft), testNodeKey) {
+		t.Errorf("Expected: Confirmation of the full Node key, got %s", lastSent(ft))
+	}
+
+	var token string
+	for k, pending := range bot.pendingConfirmations {
+		token = k
+		if pending.args != testNodeKey {
+			t.Errorf("Expected: Confirmation of %s, got %s", testNodeKey, pending.args)
+		}
+	}
+	if err := bot.handleCommand(ctx, "confirm", token); err != nil {
+		t.Error(err)
+	}
+	if r := reboots(); len(r) != 1 || r[0] != "192.168.0.2:5000" {
+		t.Errorf("Unexpected reboot requests: %v", r)
+	}
+	if !strings.Contains(lastSent(ft), "restart+requested") {
+		t.Errorf("Expected: Restart reply, got %s", lastSent(ft))
+	}
+}
+
+func Test_NodeControl_ShortKeyRefused(t *testing.T) {
+	server, reboots := newFakeManager()
+	defer server.Close()
+
+	var config wcconfig.Config
+	config.Telegram.AdminIDs = []int{1001}
+	bot, ft := newTestBot(t, config)
+	defer removeTestState(bot)
+	bot.skyMgrMonitor = skymgrmon.NewMonitor(server.Listener.Addr().String(), "")
+
+	ctx := newTestCommandCtx(1001, "admin", "/restartnode 02")
+	ctx.User.Admin = true
+	if err := bot.handleCommand(ctx, "restartnode", "02"); err != nil {
+		t.Error(err)
+	}
+	if len(bot.pendingConfirmations) != 0 || len(reboots()) != 0 {
+		t.Error("Expected: No confirmation should be requested for a short Node key")
+	}
+	if !strings.Contains(lastSent(ft), "too+short") {
+		t.Errorf("Expected: Short key reply, got %s", lastSent(ft))
+	}
+}
+
+func Test_NodeControl_NonAdminRefused(t *testing.T) {
+	server, reboots := newFakeManager()
+	defer server.Close()
+
+	var config wcconfig.Config
+	config.Telegram.AdminIDs = []int{1001}
+	config.Telegram.Members = []int{2002}
+	bot, _ := newTestBot(t, config)
+	defer removeTestState(bot)
+	bot.skyMgrMonitor = skymgrmon.NewMonitor(server.Listener.Addr().String(), "")
+
+	ctx := newTestCommandCtx(2002, "member", "/rebootall")
+	if err := bot.handleCommand(ctx, "rebootall", ""); err != errAdminOnly {
+		t.Errorf("Expected errAdminOnly, got %v", err)
+	}
+	if len(reboots()) != 0 {
+		t.Error("Expected: No Nodes should be rebooted")
+	}
+}
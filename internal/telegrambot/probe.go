@@ -0,0 +1,137 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package telegrambot
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/netprobe"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	log "github.com/sirupsen/logrus"
+)
+
+// managerNodeHosts maps the host (IP) of each Node connected to the Manager to its key.
+// Failures are logged, as probe results are still useful without the Manager's view.
+func (bot *Bot) managerNodeHosts() map[string]string {
+	hosts := make(map[string]string)
+	nodes, err := bot.skyMgrMonitor.GetAllNodes()
+	if err != nil {
+		log.Warnf("Bot.managerNodeHosts: Failed to get connected Nodes: %v", err)
+		return hosts
+	}
+
+	for _, n := range nodes {
+		addr, err := bot.skyMgrMonitor.GetNodeAddress(n.Key)
+		if err != nil {
+			log.Warnf("Bot.managerNodeHosts: Failed to get the address of %s: %v", n.Key, err)
+			continue
+		}
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			hosts[host] = n.Key
+		}
+	}
+	return hosts
+}
+
+// describeProbeEvent describes a change in the reachability of a probe target, correlated
+// with the Nodes connected to the Manager (hosts, see managerNodeHosts)
+func describeProbeEvent(e netprobe.Event, hosts map[string]string) string {
+	if e.Reachable {
+		return fmt.Sprintf(wcconst.MsgProbeReachable, e.Target, e.Latency)
+	}
+	if key, ok := hosts[e.Host]; ok {
+		return fmt.Sprintf(wcconst.MsgProbeUnreachable, e.Target, shortNodeKey(key), e.Err)
+	}
+	return fmt.Sprintf(wcconst.MsgProbeUnreachableNotFound, e.Target, e.Err)
+}
+
+// runProbes probes every target and alerts the Admin of any change in their reachability
+func (bot *Bot) runProbes() error {
+	probes := bot.getProbeMonitor()
+	if probes == nil {
+		return nil
+	}
+
+	events := probes.ProbeAll()
+	if len(events) == 0 {
+		return nil
+	}
+
+	hosts := bot.managerNodeHosts()
+	var lines []string
+	for _, e := range events {
+		line := describeProbeEvent(e, hosts)
+		log.Infof("Bot.runProbes: %s", line)
+		lines = append(lines, line)
+	}
+	return bot.SendNewMessage("text", strings.Join(lines, "\n"))
+}
+
+// probeLoop periodically probes the configured targets until the provided context is cancelled.
+// The interval is configured by `probe.intervalsec` (0, or no `probe.targets`, disables the probes).
+func (bot *Bot) probeLoop(runctx context.Context) {
+	interval := bot.getConfig().Probe.IntervalSec
+	if bot.getProbeMonitor() == nil || interval <= 0 {
+		log.Infoln("Bot.probeLoop: Network probes are disabled.")
+		return
+	}
+
+	log.Infof("Bot.probeLoop: Probing %d targets every %v", len(bot.getConfig().Probe.Targets), interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := bot.runProbes(); err != nil {
+			log.Errorf("Bot.probeLoop: %v", err)
+		}
+
+		select {
+		case <-runctx.Done():
+			log.Debugln("Bot.probeLoop: Stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Handler for probes command
+func (bot *Bot) handleCommandProbes(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	probes := bot.getProbeMonitor()
+	if probes == nil {
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", wcconst.MsgProbesDisabled)
+	}
+
+	hosts := bot.managerNodeHosts()
+	var lines []string
+	for _, s := range probes.Statuses() {
+		node := "not connected to the Manager"
+		if key, ok := hosts[s.Host]; ok {
+			node = "Node " + shortNodeKey(key)
+		}
+
+		switch {
+		case !s.Probed:
+			lines = append(lines, fmt.Sprintf("⏳ %s (%s): not probed yet", s.Target, node))
+		case s.Reachable:
+			lines = append(lines, fmt.Sprintf("✅ %s (%s): %v, avg %v, loss %.0f%%", s.Target, node, s.Latency, s.AvgLatency, s.LossPct))
+		default:
+			lines = append(lines, fmt.Sprintf("⚠️ %s (%s): %v, loss %.0f%%", s.Target, node, s.Err, s.LossPct))
+		}
+	}
+
+	err := bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgProbes, strings.Join(lines, "\n")))
+	if err != nil {
+		logSendError("Bot.handleCommandProbes", err)
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package telegrambot
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/netprobe"
+	"github.com/BigOokie/skywire-wing-commander/internal/skymgrmon"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+)
+
+// unreachableProber reports every target as unreachable
+type unreachableProber struct{}
+
+func (unreachableProber) Probe(address string, timeout time.Duration) (time.Duration, error) {
+	return 0, errors.New("i/o timeout")
+}
+
+func Test_RunProbes_CorrelatesWithManager(t *testing.T) {
+	server, _ := newFakeManager()
+	defer server.Close()
+
+	var config wcconfig.Config
+	config.Telegram.AdminIDs = []int{1001}
+	bot, ft := newTestBot(t, config)
+	defer removeTestState(bot)
+	bot.skyMgrMonitor = skymgrmon.NewMonitor(server.Listener.Addr().String(), "")
+
+	// The fake Manager reports its Node connected from 192.168.0.2
+	mon, err := netprobe.NewMonitor([]string{"192.168.0.2:8000", "192.168.0.9"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mon.SetProber(unreachableProber{})
+	bot.probeMonitor = mon
+
+	if err := bot.runProbes(); err != nil {
+		t.Fatal(err)
+	}
+	sent := ft.sent("sendMessage")
+	if len(sent) != 1 {
+		t.Fatalf("Expected: 1 alert, got %d", len(sent))
+	}
+	msg, _ := url.QueryUnescape(sent[0])
+	for _, expect := range []string{
+		"192.168.0.2:8000 is unreachable, but Node " + shortNodeKey(testNodeKey) + " is connected to the Manager",
+		"192.168.0.9 is unreachable (and not connected to the Manager)",
+	} {
+		if !strings.Contains(msg, expect) {
+			t.Errorf("Expected: %q in %s", expect, msg)
+		}
+	}
+
+	// Unchanged reachability is not alerted again
+	if err := bot.runProbes(); err != nil {
+		t.Fatal(err)
+	}
+	if sent := ft.sent("sendMessage"); len(sent) != 1 {
+		t.Errorf("Expected: no further alerts, got %d messages", len(sent))
+	}
+
+	ctx := newTestCommandCtx(1001, "admin", "/probes")
+	if err := bot.handleMessage(ctx); err != nil {
+		t.Fatal(err)
+	}
+	sent = ft.sent("sendMessage")
+	msg, _ = url.QueryUnescape(sent[len(sent)-1])
+	if !strings.Contains(msg, "192.168.0.2:8000 (Node "+shortNodeKey(testNodeKey)+"): i/o timeout, loss 100%") {
+		t.Errorf("Unexpected /probes reply: %s", msg)
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package telegrambot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/hostmon"
+	"github.com/BigOokie/skywire-wing-commander/internal/netprobe"
+	"github.com/BigOokie/skywire-wing-commander/internal/skyversion"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	"github.com/BigOokie/skywire-wing-commander/internal/wclog"
+	log "github.com/sirupsen/logrus"
+)
+
+// backgroundLoop is a periodic check run in the background while the Bot is running
+type backgroundLoop struct {
+	run func(*Bot, context.Context)
+	// Configuration keys (or sections, i.e. `host.`) the loop depends on. The loop
+	// is restarted when any of them are changed by a configuration reload.
+	keys []string
+}
+
+// backgroundLoops are the background loops started by Bot.Start, by name
+var backgroundLoops = map[string]backgroundLoop{
+	"update":   {(*Bot).updateCheckLoop, []string{"wingcommander.updatecheckintmin", "wingcommander.updatechannel"}},
+	"versions": {(*Bot).nodeVersionLoop, []string{"monitor.versioncheckintmin", "skymanager.skywirerepo", "skymanager.skywireversion"}},
+	"host":     {(*Bot).hostMonitorLoop, []string{"host."}},
+	"probe":    {(*Bot).probeLoop, []string{"probe."}},
+}
+
+// monitorKeys are the configuration keys used by the Manager monitor (see startMonitor)
+var monitorKeys = []string{"monitor.intervalsec", "monitor.heartbeatintmin", "skymanager.discoveryaddress"}
+
+// affects determines if any of the changed configuration keys match one of the provided
+// keys or sections (a key ending in `.`)
+func affects(changed []string, keys ...string) bool {
+	for _, c := range changed {
+		for _, k := range keys {
+			if c == k || strings.HasSuffix(k, ".") && strings.HasPrefix(c, k) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getConfig returns the current configuration. It may be replaced at runtime (see ReloadConfig).
+func (bot *Bot) getConfig() wcconfig.Config {
+	bot.configM.RLock()
+	defer bot.configM.RUnlock()
+	return bot.config
+}
+
+// getVersionSource returns the source of the latest Skywire version
+func (bot *Bot) getVersionSource() skyversion.Source {
+	bot.configM.RLock()
+	defer bot.configM.RUnlock()
+	return bot.versionSource
+}
+
+// getHostCollector returns the collector of the host metrics
+func (bot *Bot) getHostCollector() *hostmon.Collector {
+	bot.configM.RLock()
+	defer bot.configM.RUnlock()
+	return bot.hostCollector
+}
+
+// getProbeMonitor returns the network probe monitor (nil if no targets are configured)
+func (bot *Bot) getProbeMonitor() *netprobe.Monitor {
+	bot.configM.RLock()
+	defer bot.configM.RUnlock()
+	return bot.probeMonitor
+}
+
+// startLoop starts the named background loop (see backgroundLoops), stopping it first if it is running
+func (bot *Bot) startLoop(name string) {
+	runctx, cancel := context.WithCancel(bot.context())
+	bot.m.Lock()
+	if stop, ok := bot.loops[name]; ok {
+		stop()
+	}
+	bot.loops[name] = cancel
+	bot.m.Unlock()
+
+	bot.goBackground(func() { backgroundLoops[name].run(bot, runctx) })
+}
+
+// restartLoops restarts the running background loops which depend on the changed configuration keys
+func (bot *Bot) restartLoops(changed []string) {
+	for name, l := range backgroundLoops {
+		bot.m.Lock()
+		_, running := bot.loops[name]
+		bot.m.Unlock()
+
+		if running && affects(changed, l.keys...) {
+			log.Infof("Bot.restartLoops: Restarting the %s loop", name)
+			bot.startLoop(name)
+		}
+	}
+}
+
+// applyConfig replaces the current configuration with c, which differs by the changed keys.
+// Components and background loops which depend on the changed keys are recreated.
+func (bot *Bot) applyConfig(c wcconfig.Config, changed []string) error {
+	var err error
+	versionSource := bot.getVersionSource()
+	if affects(changed, "skymanager.skywirerepo", "skymanager.skywireversion") {
+		if versionSource, err = newVersionSource(c); err != nil {
+			return fmt.Errorf("Failed to initialize Skywire version source: %v", err)
+		}
+	}
+
+	hostCollector := bot.getHostCollector()
+	if affects(changed, "host.diskpath") {
+		hostCollector = hostmon.NewCollector(c.Host.DiskPath)
+	}
+
+	probeMonitor := bot.getProbeMonitor()
+	if affects(changed, "probe.targets", "probe.timeoutsec") {
+		probeMonitor = nil
+		if len(c.Probe.Targets) > 0 {
+			if probeMonitor, err = netprobe.NewMonitor(c.Probe.Targets, c.Probe.TimeoutSec); err != nil {
+				return fmt.Errorf("Failed to initialize network probes: %v", err)
+			}
+		}
+	}
+
+	// The Manager monitor reads its configuration when started, so is restarted if it is running
+	restartMonitor := bot.skyMgrMonitor.IsRunning() && affects(changed, monitorKeys...)
+	if restartMonitor {
+		bot.skyMgrMonitor.StopManagerMonitor()
+	}
+
+	bot.configM.Lock()
+	bot.config = c
+	bot.versionSource = versionSource
+	bot.hostCollector = hostCollector
+	bot.probeMonitor = probeMonitor
+	bot.configM.Unlock()
+
+	bot.skyMgrMonitor.SetDiscoveryAddress(c.SkyManager.DiscoveryAddress)
+	if affects(changed, "telegram.debug") {
+		// Debug is read (without locking) while sending, so is only written when changed
+		bot.telegram.Debug = c.Telegram.Debug
+	}
+	if affects(changed, "log.") {
+		if err := wclog.Configure(c.LogOptions()); err != nil {
+			log.Errorf("Bot.applyConfig: Failed to configure logging: %v", err)
+		}
+	}
+
+	bot.restartLoops(changed)
+	if restartMonitor {
+		log.Infoln("Bot.applyConfig: Restarting the Manager monitor")
+		bot.startMonitor()
+	}
+	return nil
+}
+
+// describeChanges describes the change of each configuration key from a to b. Secret values are masked.
+func describeChanges(a, b wcconfig.Config, keys []string) string {
+	var lines []string
+	for _, key := range keys {
+		from, _ := a.Value(key)
+		to, _ := b.Value(key)
+		lines = append(lines, fmt.Sprintf("%s: %s → %s", key, from, to))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ReloadConfig applies a configuration reloaded from config.toml (see wcconfig.WatchConfigParameters).
+// Configurations which fail to load or validate are rejected. Changes which are safe to apply while
+// running (see wcconfig.IsReloadable) are applied immediately, while other changes are ignored until
+// restart. The outcome is reported to the configured chat.
+func (bot *Bot) ReloadConfig(c wcconfig.Config, err error) {
+	if err != nil {
+		log.Errorf("Bot.ReloadConfig: Rejected: %v", err)
+		bot.reportConfigReload(fmt.Sprintf(wcconst.MsgConfigRejected, err))
+		return
+	}
+
+	issues := c.Validate()
+	if issues.HasErrors() {
+		log.Errorf("Bot.ReloadConfig: Rejected:\n%s", issues)
+		bot.reportConfigReload(fmt.Sprintf(wcconst.MsgConfigRejected, issues))
+		return
+	}
+	for _, vi := range issues {
+		log.Warnf("Bot.ReloadConfig: %s", vi)
+	}
+
+	current, applied, restart, err := bot.applyReload(c)
+	if err != nil {
+		log.Errorf("Bot.ReloadConfig: Rejected: %v", err)
+		bot.reportConfigReload(fmt.Sprintf(wcconst.MsgConfigRejected, err))
+		return
+	}
+	if len(applied) == 0 && len(restart) == 0 {
+		log.Debugln("Bot.ReloadConfig: No changes")
+		return
+	}
+
+	var details string
+	if len(applied) > 0 {
+		details += fmt.Sprintf(wcconst.MsgConfigApplied, describeChanges(current, c, applied))
+	}
+	if len(restart) > 0 {
+		details += fmt.Sprintf(wcconst.MsgConfigRestartRequired, describeChanges(current, c, restart))
+	}
+	log.Infof("Bot.ReloadConfig: Applied: %v Requires restart: %v", applied, restart)
+	bot.reportConfigReload(fmt.Sprintf(wcconst.MsgConfigReloaded, details))
+}
+
+// applyReload applies the changes of c which can be applied while running. It returns the
+// configuration it replaced, along with the keys which were applied and those which require
+// a restart. Concurrent reloads (i.e. by the file watcher, SIGHUP and /set) are serialized,
+// so one can not overwrite the changes of another.
+func (bot *Bot) applyReload(c wcconfig.Config) (current wcconfig.Config, applied, restart []string, err error) {
+	bot.reloadM.Lock()
+	defer bot.reloadM.Unlock()
+
+	current = bot.getConfig()
+	for _, key := range wcconfig.Diff(current, c) {
+		if wcconfig.IsReloadable(key) {
+			applied = append(applied, key)
+		} else {
+			restart = append(restart, key)
+		}
+	}
+
+	// Keep the current value of keys which can not be changed while running
+	next := c
+	for _, key := range restart {
+		if err := wcconfig.CopyKey(&next, current, key); err != nil {
+			log.Errorf("Bot.applyReload: %v", err)
+		}
+	}
+
+	if len(applied) > 0 {
+		err = bot.applyConfig(next, applied)
+	}
+	return current, applied, restart, err
+}
+
+// reportConfigReload reports the outcome of a configuration reload to the configured chat
+func (bot *Bot) reportConfigReload(msg string) {
+	if err := bot.SendNewMessage("text", msg); err != nil {
+		logSendError("Bot.ReloadConfig", err)
+	}
+}
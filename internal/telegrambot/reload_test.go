@@ -0,0 +1,211 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package telegrambot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/skymgrmon"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+)
+
+// reloadTestConfig returns a Config which passes validation
+func reloadTestConfig() wcconfig.Config {
+	var c wcconfig.Config
+	c.Telegram.APIKey = "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw"
+	c.Telegram.ChatID = 123456789
+	c.Telegram.Admin = "@TESTUSER"
+	c.SkyManager.Address = "127.0.0.1:8000"
+	c.SkyManager.DiscoveryAddress = "testnet.skywire.skycoin.com:8001"
+	c.SkyManager.SkywireRepo = "skycoin/skywire"
+	c.Monitor.IntervalSec = 10 * time.Second
+	c.Monitor.HeartbeatIntMin = 120 * time.Minute
+	c.WingCommander.UpdateChannel = wcconfig.UpdateChannelStable
+	c.Host.MaxMemPct = 90
+	c.Host.MinDiskFreePct = 10
+	return c
+}
+
+// lastReloadMsg returns the (unescaped) last message sent to the fakeTelegram
+func lastReloadMsg(ft *fakeTelegram) string {
+	msg, _ := url.QueryUnescape(lastSent(ft))
+	return msg
+}
+
+func Test_ReloadConfig_Applied(t *testing.T) {
+	bot, ft := newTestBot(t, reloadTestConfig())
+	defer removeTestState(bot)
+
+	// Only running loops are restarted
+	hostctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	bot.loops["host"] = stop
+
+	c := reloadTestConfig()
+	c.Monitor.HeartbeatIntMin = 60 * time.Minute
+	c.Host.MaxTempC = 70
+	c.Probe.Targets = []string{"192.168.0.2:8000"}
+	bot.ReloadConfig(c, nil)
+
+	if diff := wcconfig.Diff(bot.getConfig(), c); len(diff) != 0 {
+		t.Errorf("Expected: the configuration to be applied, differs by %v", diff)
+	}
+	if bot.getProbeMonitor() == nil {
+		t.Error("Expected: the network probes to be created")
+	}
+	select {
+	case <-hostctx.Done():
+	default:
+		t.Error("Expected: the host loop to be restarted")
+	}
+	if _, ok := bot.loops["probe"]; ok {
+		t.Error("Expected: the probe loop not to be started (it was not running)")
+	}
+
+	msg := lastReloadMsg(ft)
+	for _, expect := range []string{"monitor.heartbeatintmin: 2h0m0s → 1h0m0s", "host.maxtempc: 0 → 70", `probe.targets: [] → [192.168.0.2:8000]`} {
+		if !strings.Contains(msg, expect) {
+			t.Errorf("Expected: %q in %s", expect, msg)
+		}
+	}
+	if strings.Contains(msg, "restart") {
+		t.Errorf("Unexpected restart required: %s", msg)
+	}
+
+	// Reloading an unchanged configuration is not reported
+	bot.ReloadConfig(c, nil)
+	if sent := ft.sent("sendMessage"); len(sent) != 1 {
+		t.Errorf("Expected: 1 message, got %d", len(sent))
+	}
+}
+
+func Test_ReloadConfig_RestartRequired(t *testing.T) {
+	bot, ft := newTestBot(t, reloadTestConfig())
+	defer removeTestState(bot)
+
+	c := reloadTestConfig()
+	c.Telegram.APIKey = "987654321:BBHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw"
+	c.SkyManager.Address = "127.0.0.1:8001"
+	c.Monitor.IntervalSec = 30 * time.Second
+	bot.ReloadConfig(c, nil)
+
+	config := bot.getConfig()
+	if config.Monitor.IntervalSec != 30*time.Second {
+		t.Errorf("Expected: monitor.intervalsec to be applied, got %v", config.Monitor.IntervalSec)
+	}
+	if config.SkyManager.Address != "127.0.0.1:8000" || config.Telegram.APIKey != reloadTestConfig().Telegram.APIKey {
+		t.Errorf("Expected: keys requiring a restart to be unchanged: %s", config.String())
+	}
+
+	msg := lastReloadMsg(ft)
+	if !strings.Contains(msg, "Requires a restart") || !strings.Contains(msg, `skymanager.address: "127.0.0.1:8000" → "127.0.0.1:8001"`) {
+		t.Errorf("Expected: skymanager.address to require a restart: %s", msg)
+	}
+	if strings.Contains(msg, "987654321") {
+		t.Errorf("Expected: the API key not to be reported: %s", msg)
+	}
+}
+
+func Test_ReloadConfig_Rejected(t *testing.T) {
+	bot, ft := newTestBot(t, reloadTestConfig())
+	defer removeTestState(bot)
+
+	bot.ReloadConfig(wcconfig.Config{}, errors.New("toml: line 3: unexpected EOF"))
+	if msg := lastReloadMsg(ft); !strings.Contains(msg, "rejected") || !strings.Contains(msg, "unexpected EOF") {
+		t.Errorf("Unexpected message: %s", msg)
+	}
+
+	c := reloadTestConfig()
+	c.Monitor.IntervalSec = 0
+	bot.ReloadConfig(c, nil)
+	if msg := lastReloadMsg(ft); !strings.Contains(msg, "rejected") || !strings.Contains(msg, "monitor.intervalsec") {
+		t.Errorf("Unexpected message: %s", msg)
+	}
+	if bot.getConfig().Monitor.IntervalSec != 10*time.Second {
+		t.Error("Expected: the rejected configuration not to be applied")
+	}
+}
+
+func Test_ReloadConfig_Concurrent(t *testing.T) {
+	bot, ft := newTestBot(t, reloadTestConfig())
+	defer removeTestState(bot)
+
+	// Reloads are serialized, so each one is reported as a change from the one before it
+	var wg sync.WaitGroup
+	for i := 1; i <= 5; i++ {
+		c := reloadTestConfig()
+		c.Monitor.HeartbeatIntMin = time.Duration(i) * time.Minute
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bot.ReloadConfig(c, nil)
+		}()
+	}
+	wg.Wait()
+
+	if sent := ft.sent("sendMessage"); len(sent) != 5 {
+		t.Errorf("Expected: 5 reloads to be reported, got %d", len(sent))
+	}
+	heartbeat := bot.getConfig().Monitor.HeartbeatIntMin
+	if heartbeat < time.Minute || heartbeat > 5*time.Minute {
+		t.Errorf("Expected: one of the reloaded configurations, got %v", heartbeat)
+	}
+}
+
+func Test_ReloadConfig_MonitorRunning(t *testing.T) {
+	server, _ := newFakeManager()
+	defer server.Close()
+
+	c := reloadTestConfig()
+	c.Monitor.IntervalSec = 5 * time.Millisecond
+	bot, _ := newTestBot(t, c)
+	defer removeTestState(bot)
+	bot.skyMgrMonitor = skymgrmon.NewMonitor(server.Listener.Addr().String(), server.Listener.Addr().String())
+
+	waitRunning := func() {
+		deadline := time.Now().Add(5 * time.Second)
+		for !bot.skyMgrMonitor.IsRunning() {
+			if time.Now().After(deadline) {
+				t.Fatal("Expected: the Manager monitor to be running")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	bot.startMonitor()
+	waitRunning()
+
+	// The heartbeat reads the Discovery address while it is changed
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			bot.skyMgrMonitor.ConnectedDiscNodeCount()
+		}
+	}()
+
+	// The monitor is restarted by each reload, while it is polling the Manager
+	for i := 1; i <= 10; i++ {
+		c.Monitor.IntervalSec = time.Duration(i) * time.Millisecond
+		c.SkyManager.DiscoveryAddress = fmt.Sprintf("127.0.0.1:%d", 8000+i)
+		bot.ReloadConfig(c, nil)
+		waitRunning()
+	}
+	<-done
+
+	if bot.skyMgrMonitor.GetDiscoveryAddress() != "127.0.0.1:8010" {
+		t.Errorf("Expected: the Discovery address to be applied, got %s", bot.skyMgrMonitor.GetDiscoveryAddress())
+	}
+	bot.skyMgrMonitor.StopManagerMonitor()
+	if !bot.waitBackground(5 * time.Second) {
+		t.Error("Expected: the monitor event loop to stop")
+	}
+}
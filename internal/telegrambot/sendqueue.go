@@ -0,0 +1,496 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package telegrambot
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcstate"
+	log "github.com/sirupsen/logrus"
+	tgbotapi "gopkg.in/telegram-bot-api.v4"
+)
+
+const (
+	// sendQueueSize is the maximum number of messages waiting to be retried. Once exceeded,
+	// the oldest message which is not an alert (or else the oldest alert) is dropped.
+	sendQueueSize = 100
+	// sendMaxAge is the age after which a message which could not be delivered is dropped
+	sendMaxAge = 24 * time.Hour
+	// sendInitialBackoff and sendMaxBackoff bound the delay between attempts to deliver a
+	// message, unless Telegram provides the delay (retry_after)
+	sendInitialBackoff = 2 * time.Second
+	sendMaxBackoff     = 5 * time.Minute
+	// sendBusyWait is the interval at which a queued message which is due is retried while
+	// another message is being sent (it is delivered once that message has been sent)
+	sendBusyWait = 100 * time.Millisecond
+	// Telegram permits about one message per second within a chat, and 20 messages per
+	// minute within a group, after a short burst
+	privateChatRate = time.Second
+	groupChatRate   = 3 * time.Second
+	chatBurst       = 3
+)
+
+// outboundMessage is a message which is waiting to be retried
+type outboundMessage struct {
+	chatID   int64
+	c        tgbotapi.Chattable
+	alert    *wcstate.PendingAlert
+	queued   time.Time
+	due      time.Time
+	attempts int
+}
+
+// chatLimit is a token bucket which limits the rate of messages sent to a chat
+type chatLimit struct {
+	tokens float64
+	last   time.Time
+}
+
+// sendQueueStats are the statistics of the messages delivered by a sendQueue
+type sendQueueStats struct {
+	Pending int
+	Delayed int
+	Dropped int
+}
+
+// sendQueue delivers messages to Telegram within its rate limits. A message which can not be
+// delivered (because Telegram is unreachable, or is rate limiting the Bot) is queued and retried
+// with backoff, honouring the retry_after provided by Telegram. Queued messages are delivered in
+// order. Alerts which are queued are persisted, so that they are delivered after a restart.
+type sendQueue struct {
+	send func(tgbotapi.Chattable) error
+	// redact (if set) removes secrets from errors before they are logged, as the errors of
+	// tgbotapi include the API URL, which contains the API key
+	redact func(string) string
+	// persist (if set) is called with the queued alerts whenever they change
+	persist func([]wcstate.PendingAlert)
+	// onDrained (if set) is called once the queue is empty, with the number of messages which
+	// were delayed since it was last empty and the longest delay
+	onDrained func(delayed int, maxDelay time.Duration)
+	// The rate of messages to private and group chats (0 is unlimited), after a burst
+	privateRate, groupRate time.Duration
+	burst                  int
+
+	m       sync.Mutex
+	pending []*outboundMessage
+	sending *outboundMessage
+	limits  map[int64]*chatLimit
+	wake    chan struct{}
+	stats   sendQueueStats
+	// delayed messages (and the longest delay) since the queue was last empty, and if any
+	// message could not be delivered when sent (rather than only being rate limited)
+	backlog         int
+	backlogMaxDelay time.Duration
+	undelivered     bool
+	// persisted determines if the last alerts persisted were not empty
+	persisted bool
+}
+
+// newSendQueue creates a sendQueue which delivers messages using send
+func newSendQueue(send func(tgbotapi.Chattable) error) *sendQueue {
+	return &sendQueue{
+		send:        send,
+		privateRate: privateChatRate,
+		groupRate:   groupChatRate,
+		burst:       chatBurst,
+		limits:      make(map[int64]*chatLimit),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// newOutbox creates the sendQueue used to deliver messages. Alerts which are waiting to be
+// delivered are persisted to the State, and those persisted before a restart are queued.
+func (bot *Bot) newOutbox() *sendQueue {
+	q := newSendQueue(func(c tgbotapi.Chattable) error {
+		_, err := bot.telegram.Send(c)
+		return err
+	})
+	q.redact = func(s string) string {
+		config := bot.getConfig()
+		return config.Redact(s)
+	}
+	q.onDrained = bot.reportDelayed
+	if bot.state != nil {
+		q.persist = func(alerts []wcstate.PendingAlert) {
+			if err := bot.state.SetPendingAlerts(alerts); err != nil {
+				log.Errorf("Bot.newOutbox: Failed to persist pending alerts: %v", err)
+			}
+		}
+		q.restore(bot.state.GetPendingAlerts())
+	}
+	return q
+}
+
+// reportDelayed reports the number of messages which were delayed, as they could not be
+// delivered when sent, once they have all been delivered
+func (bot *Bot) reportDelayed(delayed int, maxDelay time.Duration) {
+	msg, _ := newMessage(bot.getConfig().Telegram.ChatID, "text",
+		fmt.Sprintf(wcconst.MsgSendDelayed, delayed, maxDelay.Round(time.Second)))
+	if err := bot.outbox.send(msg); err != nil {
+		logSendError("Bot.reportDelayed", err)
+	}
+}
+
+// File uploads (i.e. documents) return the description of an error from Telegram, rather than
+// a tgbotapi.Error, so errors are also classified by their description
+var (
+	uploadRetryAfterPattern = regexp.MustCompile(`^Too Many Requests: retry after (\d+)`)
+	uploadRejectedPattern   = regexp.MustCompile(`^(Bad Request|Unauthorized|Forbidden|Not Found|Conflict|Request Entity Too Large)\b`)
+)
+
+// retryDelay determines if a message which failed to send should be retried, and the delay
+// requested by Telegram (if any). Messages rejected by Telegram (i.e. due to invalid markdown,
+// or as the chat does not exist) are not retried.
+func retryDelay(err error) (bool, time.Duration) {
+	if apiErr, ok := err.(tgbotapi.Error); ok {
+		if apiErr.RetryAfter > 0 {
+			return true, time.Duration(apiErr.RetryAfter) * time.Second
+		}
+		return false, 0
+	}
+	if m := uploadRetryAfterPattern.FindStringSubmatch(err.Error()); m != nil {
+		after, _ := strconv.Atoi(m[1])
+		return true, time.Duration(after) * time.Second
+	}
+	if uploadRejectedPattern.MatchString(err.Error()) {
+		return false, 0
+	}
+	// Network errors and unexpected responses (i.e. from a proxy) are retried
+	return true, 0
+}
+
+// backoff returns the delay before the next attempt to deliver a message
+// which has failed the provided number of attempts
+func backoff(attempts int) time.Duration {
+	d := sendInitialBackoff
+	for i := 1; i < attempts && d < sendMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > sendMaxBackoff {
+		d = sendMaxBackoff
+	}
+	return d
+}
+
+// errorText returns the text of err, with secrets removed
+func (q *sendQueue) errorText(err error) string {
+	if q.redact == nil {
+		return err.Error()
+	}
+	return q.redact(err.Error())
+}
+
+// reserve reserves the sending of a message to chatID, and returns how long to wait before
+// sending it to stay within the rate limit of the chat. The caller must hold the lock.
+func (q *sendQueue) reserve(chatID int64, now time.Time) time.Duration {
+	rate := q.privateRate
+	if chatID < 0 {
+		rate = q.groupRate
+	}
+	if rate <= 0 {
+		return 0
+	}
+
+	l, ok := q.limits[chatID]
+	if !ok {
+		l = &chatLimit{tokens: float64(q.burst), last: now}
+		q.limits[chatID] = l
+	}
+	l.tokens = math.Min(float64(q.burst), l.tokens+float64(now.Sub(l.last))/float64(rate))
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens * float64(rate))
+}
+
+// reserveNow reserves the sending of a message to chatID if it can be sent now, within the
+// rate limit of the chat. The caller must hold the lock.
+func (q *sendQueue) reserveNow(chatID int64, now time.Time) bool {
+	if q.reserve(chatID, now) > 0 {
+		// Return the reservation, as the message is queued (see attempt)
+		q.limits[chatID].tokens++
+		return false
+	}
+	return true
+}
+
+// deliver sends c to chatID. If it can not be delivered now (or must wait for the rate limit of
+// the chat) it is queued and nil is returned, so an error is only returned if Telegram rejects
+// the message. alert describes an alert so that it can be persisted until it is delivered (nil
+// for other messages).
+func (q *sendQueue) deliver(chatID int64, c tgbotapi.Chattable, alert *wcstate.PendingAlert) error {
+	now := time.Now()
+	msg := &outboundMessage{chatID: chatID, c: c, alert: alert, queued: now, due: now}
+
+	q.m.Lock()
+	if len(q.pending) > 0 || q.sending != nil || !q.reserveNow(chatID, now) {
+		// Wait behind the messages which are already queued or being sent, so messages are
+		// delivered in order
+		q.enqueue(msg)
+		q.m.Unlock()
+		return nil
+	}
+	q.sending = msg
+	q.m.Unlock()
+
+	err := q.send(c)
+
+	q.m.Lock()
+	defer q.m.Unlock()
+	q.sending = nil
+	if err == nil {
+		q.saveAlerts()
+		q.wakeUp()
+		return nil
+	}
+	retry, after := retryDelay(err)
+	if !retry {
+		q.saveAlerts()
+		q.wakeUp()
+		return err
+	}
+
+	log.Warnf("Bot.deliver: Failed to send message to %d (will retry): %s", chatID, q.errorText(err))
+	q.undelivered = true
+	q.retryLater(msg, after)
+	// Messages queued while it was being sent are delivered after it
+	q.pending = append([]*outboundMessage{msg}, q.pending...)
+	q.saveAlerts()
+	q.wakeUp()
+	return nil
+}
+
+// wakeUp wakes the queue (see run), i.e. once a message has been queued
+func (q *sendQueue) wakeUp() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// retryLater schedules the next attempt to deliver msg, after the provided
+// delay (if requested by Telegram) or its backoff. The caller must hold the lock.
+func (q *sendQueue) retryLater(msg *outboundMessage, after time.Duration) {
+	msg.attempts++
+	if after <= 0 {
+		after = backoff(msg.attempts)
+	}
+	msg.due = time.Now().Add(after)
+}
+
+// enqueue adds msg to the end of the queue. The caller must hold the lock.
+func (q *sendQueue) enqueue(msg *outboundMessage) {
+	q.pending = append(q.pending, msg)
+	if len(q.pending) > sendQueueSize {
+		drop := 0
+		for i, m := range q.pending {
+			if m.alert == nil {
+				drop = i
+				break
+			}
+		}
+		log.Errorf("Bot.enqueue: Too many messages are waiting to be delivered. Dropping a message to %d", q.pending[drop].chatID)
+		q.pending = append(q.pending[:drop], q.pending[drop+1:]...)
+		q.stats.Dropped++
+	}
+	q.saveAlerts()
+	q.wakeUp()
+}
+
+// saveAlerts persists the queued alerts. The caller must hold the lock.
+func (q *sendQueue) saveAlerts() {
+	if q.persist == nil {
+		return
+	}
+	var alerts []wcstate.PendingAlert
+	if q.sending != nil && q.sending.alert != nil {
+		alerts = append(alerts, *q.sending.alert)
+	}
+	for _, m := range q.pending {
+		if m.alert != nil {
+			alerts = append(alerts, *m.alert)
+		}
+	}
+	if len(alerts) == 0 && !q.persisted {
+		return
+	}
+	q.persist(alerts)
+	q.persisted = len(alerts) > 0
+}
+
+// restore queues alerts which were persisted (see wcstate.PendingAlert) before a restart
+func (q *sendQueue) restore(alerts []wcstate.PendingAlert) {
+	q.m.Lock()
+	defer q.m.Unlock()
+	for i := range alerts {
+		alert := alerts[i]
+		msg, err := newMessage(alert.ChatID, alert.Format, alert.Text)
+		if err != nil {
+			log.Errorf("Bot.restore: Dropping alert: %v", err)
+			continue
+		}
+		q.pending = append(q.pending, &outboundMessage{
+			chatID: alert.ChatID, c: msg, alert: &alert, queued: alert.Queued, due: time.Now(),
+		})
+		q.undelivered = true
+	}
+	if len(alerts) > 0 {
+		log.Infof("Bot.restore: %d alerts from before the restart are waiting to be delivered", len(alerts))
+	}
+}
+
+// retryNow makes the queued messages due immediately (i.e. once Telegram can be reached again)
+func (q *sendQueue) retryNow() {
+	q.m.Lock()
+	now := time.Now()
+	for _, msg := range q.pending {
+		msg.due = now
+	}
+	q.m.Unlock()
+	q.wakeUp()
+}
+
+// next returns the time until the first queued message is due, and if there is one. Queued
+// messages are not attempted while another message is being sent (see deliver).
+func (q *sendQueue) next() (time.Duration, bool) {
+	q.m.Lock()
+	defer q.m.Unlock()
+	if len(q.pending) == 0 {
+		return 0, false
+	}
+	wait := time.Until(q.pending[0].due)
+	if q.sending != nil && wait < sendBusyWait {
+		wait = sendBusyWait
+	}
+	return wait, true
+}
+
+// attempt attempts to deliver the first queued message
+func (q *sendQueue) attempt() {
+	q.m.Lock()
+	if len(q.pending) == 0 || q.sending != nil {
+		q.m.Unlock()
+		return
+	}
+	msg := q.pending[0]
+	q.pending = q.pending[1:]
+
+	if time.Since(msg.queued) > sendMaxAge {
+		log.Errorf("Bot.attempt: Dropping a message to %d which could not be delivered within %v", msg.chatID, sendMaxAge)
+		q.stats.Dropped++
+		q.saveAlerts()
+		q.m.Unlock()
+		return
+	}
+	q.sending = msg
+	wait := q.reserve(msg.chatID, time.Now())
+	q.m.Unlock()
+	time.Sleep(wait)
+
+	err := q.send(msg.c)
+
+	q.m.Lock()
+	q.sending = nil
+	var drained bool
+	var delayed int
+	var maxDelay time.Duration
+	if err == nil {
+		// Messages which were only queued for the rate limit of the chat are not reported
+		if q.undelivered {
+			delay := time.Since(msg.queued)
+			log.Infof("Bot.attempt: Delivered a message to %d after %v", msg.chatID, delay.Round(time.Second))
+			q.stats.Delayed++
+			q.backlog++
+			if delay > q.backlogMaxDelay {
+				q.backlogMaxDelay = delay
+			}
+		}
+		if len(q.pending) == 0 && q.undelivered {
+			drained, delayed, maxDelay = true, q.backlog, q.backlogMaxDelay
+			q.backlog, q.backlogMaxDelay, q.undelivered = 0, 0, false
+		}
+	} else if retry, after := retryDelay(err); retry {
+		log.Warnf("Bot.attempt: Failed to send message to %d (attempt %d, will retry): %s", msg.chatID, msg.attempts+1, q.errorText(err))
+		q.undelivered = true
+		q.retryLater(msg, after)
+		q.pending = append([]*outboundMessage{msg}, q.pending...)
+	} else {
+		log.Errorf("Bot.attempt: Dropping a message to %d which was rejected by Telegram: %s", msg.chatID, q.errorText(err))
+		q.stats.Dropped++
+	}
+	q.saveAlerts()
+	q.m.Unlock()
+
+	if drained && q.onDrained != nil {
+		q.onDrained(delayed, maxDelay)
+	}
+}
+
+// run delivers queued messages as they become due, until ctx is done
+func (q *sendQueue) run(ctx context.Context) {
+	for {
+		wait, ok := q.next()
+		if ok && wait <= 0 {
+			q.attempt()
+			continue
+		}
+
+		var timer *time.Timer
+		var due <-chan time.Time
+		if ok {
+			timer = time.NewTimer(wait)
+			due = timer.C
+		}
+		select {
+		case <-due:
+		case <-q.wake:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// drain attempts to deliver the queued messages which are due before the deadline
+// (i.e. on shutdown), and reports if the queue was emptied
+func (q *sendQueue) drain(deadline time.Time) bool {
+	for {
+		wait, ok := q.next()
+		if !ok {
+			return true
+		}
+		if time.Now().Add(wait).After(deadline) {
+			return false
+		}
+		time.Sleep(wait)
+		q.attempt()
+	}
+}
+
+// getStats returns the statistics of the messages delivered by the queue
+func (q *sendQueue) getStats() sendQueueStats {
+	q.m.Lock()
+	defer q.m.Unlock()
+	s := q.stats
+	s.Pending = len(q.pending)
+	if q.sending != nil {
+		s.Pending++
+	}
+	return s
+}
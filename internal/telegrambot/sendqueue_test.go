@@ -0,0 +1,277 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package telegrambot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/wcstate"
+)
+
+// tooManyRequests is the response of Telegram when the Bot is rate limited
+const tooManyRequests = `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`
+
+// waitForSent waits for the provided number of messages to be sent
+func waitForSent(t *testing.T, ft *fakeTelegram, n int) []string {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if sent := ft.sent("sendMessage"); len(sent) >= n {
+			return sent
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expected: %d messages to be sent, got %v", n, ft.sent("sendMessage"))
+	return nil
+}
+
+func Test_SendQueue_RetryAfter(t *testing.T) {
+	bot, ft := newTestBot(t, reloadTestConfig())
+	defer removeTestState(bot)
+	bot.outbox = bot.newOutbox()
+
+	// Telegram rate limits the first attempt
+	limited := 0
+	ft.setFail(func(path string) (string, error) {
+		if strings.HasSuffix(path, "/sendMessage") && limited == 0 {
+			limited++
+			return tooManyRequests, nil
+		}
+		return "", nil
+	})
+
+	start := time.Now()
+	if err := bot.SendNewMessage("text", "Node disconnected"); err != nil {
+		t.Fatalf("Expected: the alert to be queued, got %v", err)
+	}
+	// Messages sent meanwhile are delivered in order
+	if err := bot.SendNewMessage("text", "Node connected"); err != nil {
+		t.Fatal(err)
+	}
+	if alerts := bot.state.GetPendingAlerts(); len(alerts) != 2 || alerts[0].Text != "Node disconnected" {
+		t.Errorf("Expected: the queued alerts to be persisted, got %+v", alerts)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bot.outbox.run(ctx)
+
+	// The first request was rate limited
+	sent := waitForSent(t, ft, 4)[1:]
+	if time.Since(start) < time.Second {
+		t.Errorf("Expected: retry_after to be honoured")
+	}
+	for i, expect := range []string{"Node+disconnected", "Node+connected", "messages+could+not+be+delivered+when+sent"} {
+		if !strings.Contains(sent[i], expect) {
+			t.Errorf("Message %d: Expected: %q, got %s", i, expect, sent[i])
+		}
+	}
+	if alerts := bot.state.GetPendingAlerts(); len(alerts) != 0 {
+		t.Errorf("Expected: no pending alerts once delivered, got %+v", alerts)
+	}
+	if stats := bot.outbox.getStats(); stats.Delayed != 2 || stats.Pending != 0 || stats.Dropped != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func Test_SendQueue_Rejected(t *testing.T) {
+	bot, ft := newTestBot(t, reloadTestConfig())
+	defer removeTestState(bot)
+
+	// Messages rejected by Telegram are not retried
+	ft.setFail(func(path string) (string, error) {
+		return `{"ok":false,"error_code":400,"description":"Bad Request: can't parse entities"}`, nil
+	})
+	if err := bot.SendNewMessage("markdown", "*unterminated"); err == nil {
+		t.Error("Expected: an error for a rejected message")
+	}
+	if stats := bot.outbox.getStats(); stats.Pending != 0 {
+		t.Errorf("Expected: the rejected message not to be queued, got %+v", stats)
+	}
+
+	// Network errors are retried
+	ft.setFail(func(path string) (string, error) { return "", errors.New("network is unreachable") })
+	if err := bot.SendNewMessage("text", "Node disconnected"); err != nil {
+		t.Errorf("Expected: the alert to be queued, got %v", err)
+	}
+	if stats := bot.outbox.getStats(); stats.Pending != 1 {
+		t.Errorf("Expected: the alert to be queued, got %+v", stats)
+	}
+}
+
+func Test_SendQueue_Restore(t *testing.T) {
+	bot, ft := newTestBot(t, reloadTestConfig())
+	defer removeTestState(bot)
+
+	// Alerts persisted before a restart are delivered
+	alerts := []wcstate.PendingAlert{{ChatID: 1001, Format: "text", Text: "Node disconnected", Queued: time.Now().Add(-time.Minute)}}
+	if err := bot.state.SetPendingAlerts(alerts); err != nil {
+		t.Fatal(err)
+	}
+	bot.outbox = bot.newOutbox()
+	if stats := bot.outbox.getStats(); stats.Pending != 1 {
+		t.Fatalf("Expected: the persisted alert to be queued, got %+v", stats)
+	}
+
+	if !bot.outbox.drain(time.Now().Add(5 * time.Second)) {
+		t.Error("Expected: the queue to be drained")
+	}
+	sent := waitForSent(t, ft, 2)
+	if !strings.Contains(sent[0], "Node+disconnected") || !strings.Contains(sent[1], "up+to+1m0s+late") {
+		t.Errorf("Unexpected messages: %v", sent)
+	}
+}
+
+func Test_SendQueue_RateLimit(t *testing.T) {
+	q := newSendQueue(nil)
+	now := time.Now()
+
+	// A burst is permitted, after which messages are spaced by the rate of the chat
+	for i := 0; i < chatBurst; i++ {
+		if wait := q.reserve(1001, now); wait != 0 {
+			t.Errorf("Message %d: Expected: no wait, got %v", i, wait)
+		}
+	}
+	if wait := q.reserve(1001, now); wait != privateChatRate {
+		t.Errorf("Expected: a wait of %v, got %v", privateChatRate, wait)
+	}
+	if wait := q.reserve(-1001, now.Add(time.Second)); wait != 0 {
+		t.Errorf("Expected: chats to be limited separately, got %v", wait)
+	}
+	// The bucket refills over time
+	if wait := q.reserve(1001, now.Add(10*time.Second)); wait != 0 {
+		t.Errorf("Expected: no wait once the bucket has refilled, got %v", wait)
+	}
+
+	if d := backoff(1); d != sendInitialBackoff {
+		t.Errorf("Unexpected backoff: %v", d)
+	}
+	if d := backoff(100); d != sendMaxBackoff {
+		t.Errorf("Unexpected maximum backoff: %v", d)
+	}
+}
+
+func Test_SendQueue_InFlight(t *testing.T) {
+	bot, ft := newTestBot(t, reloadTestConfig())
+	defer removeTestState(bot)
+	bot.outbox = bot.newOutbox()
+
+	// The first message fails, after another message has been sent meanwhile
+	sending, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	ft.setFail(func(path string) (string, error) {
+		var err error
+		once.Do(func() {
+			close(sending)
+			<-release
+			err = errors.New("network is unreachable")
+		})
+		return "", err
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- bot.SendNewMessage("text", "Node disconnected") }()
+	<-sending
+	if err := bot.SendNewMessage("text", "Node connected"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := bot.outbox.getStats(); stats.Pending != 2 || len(ft.sent("sendMessage")) != 0 {
+		t.Errorf("Expected: the message to wait for the message being sent, got %+v", stats)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Expected: the alert to be queued, got %v", err)
+	}
+
+	// Messages are delivered in the order they were sent
+	bot.outbox.retryNow()
+	if !bot.outbox.drain(time.Now().Add(5 * time.Second)) {
+		t.Error("Expected: the queue to be drained")
+	}
+	sent := waitForSent(t, ft, 3)
+	for i, expect := range []string{"Node+disconnected", "Node+connected", "messages+could+not+be+delivered+when+sent"} {
+		if !strings.Contains(sent[i], expect) {
+			t.Errorf("Message %d: Expected: %q, got %s", i, expect, sent[i])
+		}
+	}
+}
+
+func Test_SendQueue_RateLimited(t *testing.T) {
+	bot, ft := newTestBot(t, reloadTestConfig())
+	defer removeTestState(bot)
+	bot.outbox = bot.newOutbox()
+
+	// Messages beyond the burst are queued, rather than waiting for the rate limit of the chat
+	start := time.Now()
+	for i := 0; i <= chatBurst; i++ {
+		if err := bot.SendNewMessage("text", fmt.Sprintf("Message %d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if time.Since(start) >= privateChatRate/2 {
+		t.Errorf("Expected: sending not to wait for the rate limit, took %v", time.Since(start))
+	}
+	if stats := bot.outbox.getStats(); stats.Pending != 1 {
+		t.Errorf("Expected: the last message to be queued, got %+v", stats)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bot.outbox.run(ctx)
+
+	// Messages which were only rate limited are not reported as delayed
+	sent := waitForSent(t, ft, chatBurst+1)
+	if !strings.Contains(sent[chatBurst], fmt.Sprintf("Message+%d", chatBurst)) {
+		t.Errorf("Unexpected message: %s", sent[chatBurst])
+	}
+	time.Sleep(100 * time.Millisecond)
+	if sent := ft.sent("sendMessage"); len(sent) != chatBurst+1 {
+		t.Errorf("Expected: no delayed messages report, got %v", sent[chatBurst+1:])
+	}
+	if stats := bot.outbox.getStats(); stats.Delayed != 0 || stats.Pending != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func Test_SendQueue_DocumentRejected(t *testing.T) {
+	bot, ft := newTestBot(t, reloadTestConfig())
+	defer removeTestState(bot)
+
+	// Documents rejected by Telegram are dropped after one attempt
+	ft.setFail(func(path string) (string, error) {
+		return `{"ok":false,"error_code":400,"description":"Bad Request: file is too big"}`, nil
+	})
+	ctx := newTestCommandCtx(1001, "admin", "/logs")
+	if err := bot.SendDocument(ctx, "reply", "wcbot.log", []byte("log"), "Log"); err == nil {
+		t.Error("Expected: an error for a rejected document")
+	}
+	if sent := ft.sent("sendDocument"); len(sent) != 1 {
+		t.Errorf("Expected: 1 attempt, got %d", len(sent))
+	}
+	if stats := bot.outbox.getStats(); stats.Pending != 0 {
+		t.Errorf("Expected: the rejected document not to be queued, got %+v", stats)
+	}
+
+	testCases := []struct {
+		err   error
+		retry bool
+		after time.Duration
+	}{
+		{errors.New("Too Many Requests: retry after 5"), true, 5 * time.Second},
+		{errors.New("Forbidden: bot was blocked by the user"), false, 0},
+		{errors.New("Request Entity Too Large"), false, 0},
+		{errors.New(`Post "https://api.telegram.org/bot********/sendDocument": network is unreachable`), true, 0},
+	}
+	for _, tc := range testCases {
+		if retry, after := retryDelay(tc.err); retry != tc.retry || after != tc.after {
+			t.Errorf("%v: Expected: retry %v after %v, got %v after %v", tc.err, tc.retry, tc.after, retry, after)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package telegrambot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/wcaudit"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	log "github.com/sirupsen/logrus"
+	tgbotapi "gopkg.in/telegram-bot-api.v4"
+)
+
+// SetConfigFile sets the path of the configuration file settings changed using /set can be saved to,
+// and the profile (if any) they are saved within
+func (bot *Bot) SetConfigFile(path, profile string) {
+	bot.configM.Lock()
+	defer bot.configM.Unlock()
+	bot.configFile = path
+	bot.configProfile = profile
+}
+
+// getConfigFile returns the path of the configuration file ("" if it is unknown) and its profile
+func (bot *Bot) getConfigFile() (string, string) {
+	bot.configM.RLock()
+	defer bot.configM.RUnlock()
+	return bot.configFile, bot.configProfile
+}
+
+// Handler for get command. Shows the value of a setting, or all settings.
+func (bot *Bot) handleCommandGet(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	config := bot.getConfig()
+	keys := wcconfig.Keys()
+	if key := strings.ToLower(strings.TrimSpace(args)); key != "" {
+		keys = []string{key}
+	}
+
+	var lines []string
+	for _, key := range keys {
+		value, err := config.Value(key)
+		if err != nil {
+			return bot.Send(ctx, getSendModeforContext(ctx), "text", wcconst.MsgGetUsage)
+		}
+		if wcconfig.IsReloadable(key) {
+			key += " *"
+		}
+		lines = append(lines, fmt.Sprintf("%s = %s", key, value))
+	}
+
+	err := bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgGet, strings.Join(lines, "\n")))
+	if err != nil {
+		logSendError("Bot.handleCommandGet", err)
+	}
+	return err
+}
+
+// Handler for set command. Changes a setting which can be applied while running
+// (see wcconfig.IsReloadable), and offers to save it to config.toml.
+func (bot *Bot) handleCommandSet(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	fields := strings.Fields(args)
+	if len(fields) < 2 {
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", wcconst.MsgSetUsage)
+	}
+	key := strings.ToLower(fields[0])
+	value := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(args), fields[0]))
+
+	return bot.setSetting(ctx, key, value)
+}
+
+// setSetting changes a setting which can be applied while running (see wcconfig.IsReloadable),
+// and offers to save it to config.toml. Used by /set and commands which change a single setting.
+func (bot *Bot) setSetting(ctx *BotContext, key, value string) error {
+	// Serialized with reloads of config.toml, so neither overwrites the other (see applyReload)
+	bot.reloadM.Lock()
+	defer bot.reloadM.Unlock()
+
+	current := bot.getConfig()
+	before, err := current.Value(key)
+	if err != nil {
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", wcconst.MsgSetUsage)
+	}
+	if !wcconfig.IsReloadable(key) {
+		ctx.setAuditOutcome(wcaudit.OutcomeFailed, fmt.Errorf("%s requires a restart", key))
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgSetNotReloadable, key))
+	}
+
+	config := current
+	if err := config.Set(key, value); err != nil {
+		ctx.setAuditOutcome(wcaudit.OutcomeFailed, err)
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgSetInvalid, key, err))
+	}
+	after, _ := config.Value(key)
+	if len(wcconfig.Diff(current, config)) == 0 {
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgSetUnchanged, key, after))
+	}
+
+	if issues := config.Validate(); issues.HasErrors() {
+		ctx.setAuditOutcome(wcaudit.OutcomeFailed, fmt.Errorf("invalid %s", key))
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgSetInvalid, key, "\n"+issues.String()))
+	}
+	if err := bot.applyConfig(config, []string{key}); err != nil {
+		ctx.setAuditOutcome(wcaudit.OutcomeFailed, err)
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgSetInvalid, key, err))
+	}
+	log.Infof("Bot.setSetting: %s changed from %s to %s by %s", key, before, after, ctx.User.NameAndTags())
+
+	if path, _ := bot.getConfigFile(); path == "" {
+		err = bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgSetApplied, key, before, after))
+	} else {
+		kb := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💾 Save to config.toml", "savesetting "+key),
+		))
+		err = bot.SendReplyInlineKeyboard(ctx, kb, fmt.Sprintf(wcconst.MsgSetAppliedSaveHint, key, before, after))
+	}
+	if err != nil {
+		logSendError("Bot.setSetting", err)
+	}
+	return err
+}
+
+// Handler for savesetting command (sent by the Save inline button following /set).
+// Writes the current value of the setting to config.toml, preserving the rest of the file.
+func (bot *Bot) handleCommandSaveSetting(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	key := strings.ToLower(strings.TrimSpace(args))
+	path, profile := bot.getConfigFile()
+	if key == "" || !wcconfig.IsReloadable(key) || path == "" {
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", wcconst.MsgSetUsage)
+	}
+
+	if err := wcconfig.WriteKey(path, profile, bot.getConfig(), key); err != nil {
+		log.Errorf("Bot.handleCommandSaveSetting: %v", err)
+		ctx.setAuditOutcome(wcaudit.OutcomeFailed, err)
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgSaveSettingFailed, key, err))
+	}
+
+	log.Infof("Bot.handleCommandSaveSetting: %s saved to %s by %s", key, path, ctx.User.NameAndTags())
+	err := bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgSaveSetting, key, path))
+	if err != nil {
+		logSendError("Bot.handleCommandSaveSetting", err)
+	}
+	return err
+}
@@ -0,0 +1,173 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package telegrambot
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func Test_HandleCommandGet(t *testing.T) {
+	config := reloadTestConfig()
+	config.Telegram.AdminIDs = []int{1001}
+	bot, ft := newTestBot(t, config)
+	defer removeTestState(bot)
+
+	for text, expect := range map[string]string{
+		"/get monitor.intervalsec": "monitor.intervalsec * = 10s",
+		"/get telegram.apikey":     "telegram.apikey = ********",
+		"/get":                     `skymanager.address = "127.0.0.1:8000"`,
+		"/get monitor.unknown":     "Usage: /get",
+	} {
+		if err := bot.handleMessage(newTestCommandCtx(1001, "admin", text)); err != nil {
+			t.Fatal(err)
+		}
+		if msg := lastReloadMsg(ft); !strings.Contains(msg, expect) {
+			t.Errorf("%s: Expected: %q in %s", text, expect, msg)
+		}
+	}
+}
+
+func Test_HandleCommandSet(t *testing.T) {
+	config := reloadTestConfig()
+	config.Telegram.AdminIDs = []int{1001}
+	bot, ft := newTestBot(t, config)
+	defer removeTestState(bot)
+
+	path := filepath.Join(filepath.Dir(bot.state.Path()), "config.toml")
+	if err := ioutil.WriteFile(path, []byte("[monitor]\n# Heartbeat interval (minutes)\nheartbeatintmin = 120\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	bot.SetConfigFile(path, "")
+
+	for text, expect := range map[string]string{
+		"/set monitor.intervalsec":          "Usage: /set",
+		"/set skymanager.address 1.2.3.4:1": "skymanager.address can not be changed while running",
+		"/set monitor.intervalsec often":    "monitor.intervalsec was not changed: monitor.intervalsec must be a number of seconds",
+		"/set monitor.intervalsec 0":        "monitor.intervalsec was not changed: \nerror: monitor.intervalsec",
+		"/set monitor.intervalsec 10":       "monitor.intervalsec is already 10s",
+	} {
+		if err := bot.handleMessage(newTestCommandCtx(1001, "admin", text)); err != nil {
+			t.Fatal(err)
+		}
+		if msg := lastReloadMsg(ft); !strings.Contains(msg, expect) {
+			t.Errorf("%s: Expected: %q in %s", text, expect, msg)
+		}
+	}
+	if bot.getConfig().Monitor.IntervalSec != 10*time.Second {
+		t.Errorf("Expected: monitor.intervalsec to be unchanged, got %v", bot.getConfig().Monitor.IntervalSec)
+	}
+
+	if err := bot.handleMessage(newTestCommandCtx(1001, "admin", "/set monitor.heartbeatintmin 60")); err != nil {
+		t.Fatal(err)
+	}
+	if bot.getConfig().Monitor.HeartbeatIntMin != time.Hour {
+		t.Errorf("Expected: monitor.heartbeatintmin to be applied, got %v", bot.getConfig().Monitor.HeartbeatIntMin)
+	}
+	msg := lastReloadMsg(ft)
+	if !strings.Contains(msg, "monitor.heartbeatintmin: 2h0m0s → 1h0m0s") || !strings.Contains(msg, "savesetting monitor.heartbeatintmin") {
+		t.Errorf("Unexpected reply: %s", msg)
+	}
+	// The reply warns the setting is lost when config.toml is reloaded, unless it is saved
+	if !strings.Contains(msg, "config.toml is reloaded") {
+		t.Errorf("Expected: a warning the setting lasts until config.toml is reloaded, got %s", msg)
+	}
+
+	if err := bot.handleMessage(newTestCommandCtx(1001, "admin", "/savesetting monitor.heartbeatintmin")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "[monitor]\n# Heartbeat interval (minutes)\nheartbeatintmin = 60\n" {
+		t.Errorf("Unexpected config.toml:\n%s", data)
+	}
+}
+
+func Test_HandleCommandSet_AdminOnly(t *testing.T) {
+	config := reloadTestConfig()
+	config.Telegram.AdminIDs = []int{1001}
+	config.Telegram.Members = []int{1002}
+	bot, _ := newTestBot(t, config)
+	defer removeTestState(bot)
+
+	if err := bot.handleCommand(newTestCommandCtx(1002, "member", "/set monitor.heartbeatintmin 60"), "set", "monitor.heartbeatintmin 60"); err != errAdminOnly {
+		t.Errorf("Expected: errAdminOnly, got %v", err)
+	}
+	if bot.getConfig().Monitor.HeartbeatIntMin != 120*time.Minute {
+		t.Error("Expected: the setting to be unchanged")
+	}
+}
+
+func Test_HandleCommandLogLevel(t *testing.T) {
+	config := reloadTestConfig()
+	config.Telegram.AdminIDs = []int{1001}
+	bot, ft := newTestBot(t, config)
+	defer removeTestState(bot)
+	defer log.SetLevel(log.GetLevel())
+	log.SetLevel(log.InfoLevel)
+
+	// The commands are run in order, as the level is changed
+	for _, tc := range []struct{ text, expect string }{
+		{"/loglevel", "The log level is info"},
+		{"/loglevel verbose", "log.level was not changed: \nerror: log.level"},
+		{"/loglevel DEBUG", `log.level: "" → "debug"`},
+	} {
+		if err := bot.handleMessage(newTestCommandCtx(1001, "admin", tc.text)); err != nil {
+			t.Fatal(err)
+		}
+		if msg := lastReloadMsg(ft); !strings.Contains(msg, tc.expect) {
+			t.Errorf("%s: Expected: %q in %s", tc.text, tc.expect, msg)
+		}
+	}
+	if log.GetLevel() != log.DebugLevel || bot.getConfig().Log.Level != "debug" {
+		t.Errorf("Expected: the debug log level to be applied, got %v (%q)", log.GetLevel(), bot.getConfig().Log.Level)
+	}
+}
+
+func Test_HandleCommandLogs(t *testing.T) {
+	config := reloadTestConfig()
+	config.Telegram.AdminIDs = []int{1001}
+	bot, ft := newTestBot(t, config)
+	defer removeTestState(bot)
+	defer log.SetLevel(log.GetLevel())
+	log.SetLevel(log.InfoLevel)
+
+	log.Warnf("Test_HandleCommandLogs: warning with key %s", config.Telegram.APIKey)
+	log.Infof("Test_HandleCommandLogs: info")
+
+	for _, tc := range []struct{ text, expect string }{
+		{"/logs often", "Usage: /logs"},
+		{"/logs 2", "Last 2 log entries:"},
+		{"/logs 1", "INFO  Test_HandleCommandLogs: info"},
+		{"/logs 1 warn", "WARNING Test_HandleCommandLogs: warning with key ********"},
+		{"/logs panic", "No matching log entries"},
+	} {
+		if err := bot.handleMessage(newTestCommandCtx(1001, "admin", tc.text)); err != nil {
+			t.Fatal(err)
+		}
+		if msg := lastReloadMsg(ft); !strings.Contains(msg, tc.expect) {
+			t.Errorf("%s: Expected: %q in %s", tc.text, tc.expect, msg)
+		}
+	}
+
+	// Logs which are too long for a message are sent as a document
+	for i := 0; i < 100; i++ {
+		log.Infof("Test_HandleCommandLogs: entry %d", i)
+	}
+	if err := bot.handleMessage(newTestCommandCtx(1001, "admin", "/logs 100")); err != nil {
+		t.Fatal(err)
+	}
+	docs := ft.sent("sendDocument")
+	if len(docs) != 1 || !strings.Contains(docs[0], "Test_HandleCommandLogs: entry 99") || !strings.Contains(docs[0], "Last 100 log entries") {
+		t.Errorf("Expected: the log entries to be sent as a document, got %v", docs)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package telegrambot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/skyversion"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcaudit"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultSkywireRepo is used if no Skywire GitHub repository has been configured
+const defaultSkywireRepo = "skycoin/skywire"
+
+// newVersionSource creates the Source of the latest Skywire version. A configured
+// `skymanager.skywireversion` takes precedence over the Skywire GitHub releases.
+func newVersionSource(config wcconfig.Config) (skyversion.Source, error) {
+	if config.SkyManager.SkywireVersion != "" {
+		return skyversion.StaticSource(config.SkyManager.SkywireVersion), nil
+	}
+
+	repo := config.SkyManager.SkywireRepo
+	if repo == "" {
+		repo = defaultSkywireRepo
+	}
+	return skyversion.NewGitHubSource(repo)
+}
+
+// nodeVersionReport requests the Skywire version run by each Node connected to the
+// Manager, and compares them against the latest Skywire version. Nodes whose version
+// can not be determined are reported as skyversion.UnknownVersion.
+func (bot *Bot) nodeVersionReport() (skyversion.Report, error) {
+	nodes, err := bot.skyMgrMonitor.GetAllNodes()
+	if err != nil {
+		return skyversion.Report{}, err
+	}
+
+	versions := make(map[string]string)
+	for _, n := range nodes {
+		sw, err := bot.skyMgrMonitor.GetNodeSoftware(n.Key)
+		if err != nil || sw.Version == "" {
+			log.Warnf("Bot.nodeVersionReport: Failed to get the version of %s: %v", n.Key, err)
+			versions[n.Key] = skyversion.UnknownVersion
+			continue
+		}
+		versions[n.Key] = sw.Version
+	}
+
+	// An unknown latest version still allows Nodes running different versions to be reported
+	latest, err := bot.getVersionSource().LatestVersion()
+	if err != nil {
+		log.Warnf("Bot.nodeVersionReport: Failed to get the latest Skywire version: %v", err)
+		latest = ""
+	}
+	return skyversion.Compare(versions, latest), nil
+}
+
+// versionIssues describes the issues identified by the provided Report (one per line)
+func versionIssues(r skyversion.Report) string {
+	var issues []string
+	if r.Mixed() {
+		issues = append(issues, fmt.Sprintf(wcconst.MsgSkywireVersionsMixed, strings.Join(r.Versions, ", ")))
+	}
+	if len(r.Outdated) > 0 {
+		issues = append(issues, fmt.Sprintf(wcconst.MsgSkywireVersionsOutdated, len(r.Outdated), r.Latest))
+	}
+	return strings.Join(issues, "\n")
+}
+
+// formatVersionReport renders the version run by each Node, flagging outdated Nodes
+func formatVersionReport(r skyversion.Report) string {
+	outdated := make(map[string]bool)
+	for _, key := range r.Outdated {
+		outdated[key] = true
+	}
+
+	var keys []string
+	for key := range r.Nodes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var lines []string
+	for _, key := range keys {
+		marker := "✅"
+		if outdated[key] || r.Nodes[key] == skyversion.UnknownVersion {
+			marker = "⚠️"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s: %s", marker, shortNodeKey(key), r.Nodes[key]))
+	}
+
+	if issues := versionIssues(r); issues != "" {
+		lines = append(lines, "", issues)
+	}
+
+	latest := r.Latest
+	if latest == "" {
+		latest = wcconst.MsgSkywireLatestUnknown
+	}
+	return fmt.Sprintf(wcconst.MsgSkywireVersions, latest, strings.Join(lines, "\n"))
+}
+
+// checkNodeVersions alerts the Admin if Nodes are outdated or running different versions.
+// Each set of issues is only alerted once, until it changes.
+func (bot *Bot) checkNodeVersions() error {
+	report, err := bot.nodeVersionReport()
+	if err != nil {
+		return err
+	}
+
+	signature := report.Signature()
+	bot.m.Lock()
+	alerted := signature == bot.lastVersionAlert
+	bot.lastVersionAlert = signature
+	bot.m.Unlock()
+
+	if !report.NeedsAttention() || alerted {
+		log.Debugf("Bot.checkNodeVersions: Nothing new to report (%v)", report.Versions)
+		return nil
+	}
+
+	log.Warnf("Bot.checkNodeVersions: %s", versionIssues(report))
+	return bot.SendNewMessage("text", fmt.Sprintf(wcconst.MsgSkywireVersionsAlert, versionIssues(report)))
+}
+
+// nodeVersionLoop periodically checks the Skywire versions run by the Nodes until the provided context
+// is cancelled. The check interval is configured by `monitor.versioncheckintmin` (0 disables the checks).
+func (bot *Bot) nodeVersionLoop(runctx context.Context) {
+	interval := bot.getConfig().Monitor.VersionCheckIntMin
+	if interval <= 0 {
+		log.Infoln("Bot.nodeVersionLoop: Skywire version checks are disabled.")
+		return
+	}
+
+	log.Infof("Bot.nodeVersionLoop: Checking Skywire versions every %v", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := bot.checkNodeVersions(); err != nil {
+			log.Errorf("Bot.nodeVersionLoop: Skywire version check failed: %v", err)
+		}
+
+		select {
+		case <-runctx.Done():
+			log.Debugln("Bot.nodeVersionLoop: Stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Handler for versions command
+func (bot *Bot) handleCommandVersions(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	report, err := bot.nodeVersionReport()
+	if err != nil {
+		log.Errorf("Bot.handleCommandVersions: %v", err)
+		ctx.setAuditOutcome(wcaudit.OutcomeFailed, err)
+		return bot.Send(ctx, getSendModeforContext(ctx), "markdown", wcconst.MsgErrorGetNodes)
+	}
+
+	if len(report.Nodes) == 0 {
+		return bot.Send(ctx, getSendModeforContext(ctx), "markdown", wcconst.MsgNoConnectedNodes)
+	}
+
+	// Versions may contain characters which are not valid markdown
+	err = bot.Send(ctx, getSendModeforContext(ctx), "text", formatVersionReport(report))
+	if err != nil {
+		logSendError("Bot.handleCommandVersions", err)
+	}
+	return err
+}
@@ -0,0 +1,164 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package telegrambot
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/skymgrmon"
+	"github.com/BigOokie/skywire-wing-commander/internal/skyversion"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+)
+
+const testNodeKey2 = "03ffeeddccbbaa99887766554433221100ffeeddccbbaa99887766554433221100"
+
+// newFakeVersionManager creates a fake Skywire Manager with two connected Nodes running
+// Skywire 0.1.0. The returned function changes the version reported by a Node (by address).
+func newFakeVersionManager() (*httptest.Server, func(addr, version string)) {
+	var m sync.Mutex
+	versions := map[string]string{"192.168.0.2:5000": "0.1.0", "192.168.0.3:5000": "0.1.0"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/conn/getAll", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"key":%q,"type":"TCP"},{"key":%q,"type":"TCP"}]`, testNodeKey, testNodeKey2)
+	})
+	mux.HandleFunc("/conn/getNode", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("key") == testNodeKey {
+			fmt.Fprint(w, `{"addr":"192.168.0.2:5000"}`)
+		} else {
+			fmt.Fprint(w, `{"addr":"192.168.0.3:5000"}`)
+		}
+	})
+	mux.HandleFunc("/node/getInfo", func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		defer m.Unlock()
+		fmt.Fprintf(w, `{"version":%q,"tag":"dev","os":"linux"}`, versions[r.FormValue("addr")])
+	})
+
+	return httptest.NewServer(mux), func(addr, version string) {
+		m.Lock()
+		defer m.Unlock()
+		versions[addr] = version
+	}
+}
+
+func newTestVersionBot(t *testing.T) (*Bot, *fakeTelegram, *httptest.Server, func(addr, version string)) {
+	var config wcconfig.Config
+	config.Telegram.AdminIDs = []int{1001}
+	bot, ft := newTestBot(t, config)
+
+	server, setVersion := newFakeVersionManager()
+	bot.skyMgrMonitor = skymgrmon.NewMonitor(server.Listener.Addr().String(), "")
+	bot.versionSource = skyversion.StaticSource("v0.1.0")
+	return bot, ft, server, setVersion
+}
+
+func Test_CheckNodeVersions_AlertsOnce(t *testing.T) {
+	bot, ft, server, setVersion := newTestVersionBot(t)
+	defer removeTestState(bot)
+	defer server.Close()
+
+	// All Nodes up to date
+	if err := bot.checkNodeVersions(); err != nil {
+		t.Fatal(err)
+	}
+	if sent := ft.sent("sendMessage"); len(sent) != 0 {
+		t.Fatalf("Expected: no alert, got %v", sent)
+	}
+
+	// A newer release makes every Node outdated
+	bot.versionSource = skyversion.StaticSource("v0.2.0")
+	for i := 0; i < 2; i++ {
+		if err := bot.checkNodeVersions(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sent := ft.sent("sendMessage")
+	if len(sent) != 1 {
+		t.Fatalf("Expected: 1 alert, got %d", len(sent))
+	}
+	msg, _ := url.QueryUnescape(sent[0])
+	if !strings.Contains(msg, "2 Node(s) are running a version older than v0.2.0") {
+		t.Errorf("Unexpected alert: %s", msg)
+	}
+
+	// Upgrading one Node leaves the Nodes running different versions
+	setVersion("192.168.0.2:5000", "0.2.0")
+	if err := bot.checkNodeVersions(); err != nil {
+		t.Fatal(err)
+	}
+	sent = ft.sent("sendMessage")
+	if len(sent) != 2 {
+		t.Fatalf("Expected: 2 alerts, got %d", len(sent))
+	}
+	msg, _ = url.QueryUnescape(sent[1])
+	if !strings.Contains(msg, "different versions: 0.1.0, 0.2.0") || !strings.Contains(msg, "1 Node(s)") {
+		t.Errorf("Unexpected alert: %s", msg)
+	}
+}
+
+func Test_CheckNodeVersions_UnknownVersion(t *testing.T) {
+	bot, ft, server, setVersion := newTestVersionBot(t)
+	defer removeTestState(bot)
+	defer server.Close()
+
+	// A Node whose version can not be determined (i.e. as it is briefly unreachable) is not
+	// considered to be running a different version, so does not trigger (or re-trigger) an alert
+	for _, version := range []string{"", "0.1.0", ""} {
+		setVersion("192.168.0.3:5000", version)
+		if err := bot.checkNodeVersions(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sent := ft.sent("sendMessage"); len(sent) != 0 {
+		t.Fatalf("Expected: no alert, got %v", sent)
+	}
+
+	// It is still flagged by /versions
+	ctx := newTestCommandCtx(1001, "admin", "/versions")
+	if err := bot.handleMessage(ctx); err != nil {
+		t.Fatal(err)
+	}
+	msg := lastReloadMsg(ft)
+	if expect := "⚠️ " + shortNodeKey(testNodeKey2) + ": " + skyversion.UnknownVersion; !strings.Contains(msg, expect) {
+		t.Errorf("Expected: %q in %s", expect, msg)
+	}
+	if strings.Contains(msg, "different versions") {
+		t.Errorf("Expected: the Nodes not to be reported as running different versions, got %s", msg)
+	}
+}
+
+func Test_HandleCommandVersions(t *testing.T) {
+	bot, ft, server, setVersion := newTestVersionBot(t)
+	defer removeTestState(bot)
+	defer server.Close()
+
+	setVersion("192.168.0.3:5000", "0.0.9")
+	ctx := newTestCommandCtx(1001, "admin", "/versions")
+	if err := bot.handleMessage(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := ft.sent("sendMessage")
+	if len(sent) != 1 {
+		t.Fatalf("Expected: 1 message, got %d", len(sent))
+	}
+	msg, _ := url.QueryUnescape(sent[0])
+	for _, expect := range []string{
+		"latest release: v0.1.0",
+		"✅ " + shortNodeKey(testNodeKey) + ": 0.1.0",
+		"⚠️ " + shortNodeKey(testNodeKey2) + ": 0.0.9",
+	} {
+		if !strings.Contains(msg, expect) {
+			t.Errorf("Expected: %q in %s", expect, msg)
+		}
+	}
+}
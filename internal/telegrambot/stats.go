@@ -0,0 +1,63 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package telegrambot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	"github.com/BigOokie/skywire-wing-commander/internal/wctelemetry"
+	log "github.com/sirupsen/logrus"
+)
+
+// formatCounts renders counts by name (most frequent first) on a single line
+func formatCounts(counts map[string]int) string {
+	if len(counts) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("/%s %d", name, counts[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatStats renders the usage statistics, and the statistics of messages which could not be
+// delivered when sent, shown by /stats
+func formatStats(s wctelemetry.Snapshot, q sendQueueStats) string {
+	remote := wcconst.MsgStatsLocalOnly
+	if s.Remote {
+		remote = fmt.Sprintf(wcconst.MsgStatsRemote, s.Sent, s.Failed, s.Dropped)
+	}
+	return fmt.Sprintf(wcconst.MsgStats, s.Started.Format("2006-01-02 15:04:05"), s.Uptime.Round(time.Second),
+		formatCounts(s.Commands), formatCounts(s.Errors), remote,
+		fmt.Sprintf(wcconst.MsgStatsMessages, q.Delayed, q.Pending, q.Dropped))
+}
+
+// Handler for stats command. Shows the usage statistics recorded since startup.
+func (bot *Bot) handleCommandStats(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	err := bot.Send(ctx, getSendModeforContext(ctx), "text", formatStats(bot.telemetry.Snapshot(), bot.outbox.getStats()))
+	if err != nil {
+		logSendError("Bot.handleCommandStats", err)
+	}
+	return err
+}
@@ -6,15 +6,25 @@
 package telegrambot
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/BigOokie/skywire-wing-commander/internal/hostmon"
+	"github.com/BigOokie/skywire-wing-commander/internal/netprobe"
 	"github.com/BigOokie/skywire-wing-commander/internal/skymgrmon"
+	"github.com/BigOokie/skywire-wing-commander/internal/skyversion"
+	"github.com/BigOokie/skywire-wing-commander/internal/updater"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcaudit"
 	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
 	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
-	"github.com/cloudfoundry/jibber_jabber"
-	ga "github.com/jpillora/go-ogle-analytics"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcstate"
+	"github.com/BigOokie/skywire-wing-commander/internal/wctelemetry"
 	log "github.com/sirupsen/logrus"
 	tgbotapi "gopkg.in/telegram-bot-api.v4"
 )
@@ -22,20 +32,53 @@ import (
 // Bot provides management of the interface to the Telegram Bot
 type Bot struct {
 	config                 wcconfig.Config
+	state                  *wcstate.State
+	audit                  *wcaudit.Log
 	telegram               *tgbotapi.BotAPI
 	skyMgrMonitor          *skymgrmon.SkyManagerMonitor
 	commandHandlers        map[string]CommandHandler
 	adminCommandHandlers   map[string]CommandHandler
 	privateMessageHandlers []MessageHandler
 	groupMessageHandlers   []MessageHandler
-	gaclient               *ga.Client
+	telemetry              *wctelemetry.Telemetry
+	protectedCommands      map[string]bool
+	pendingConfirmations   map[string]*pendingConfirmation
+	lastTOTPStep           uint64
+	unknownUsers           map[int]bool
+	updater                *updater.Updater
+	pendingRestart         *restartRequest
+	versionSource          skyversion.Source
+	lastVersionAlert       string
+	hostCollector          *hostmon.Collector
+	hostBreaches           map[string]bool
+	probeMonitor           *netprobe.Monitor
+	loops                  map[string]context.CancelFunc
+	connected              bool
+	runctx                 context.Context
+	outbox                 *sendQueue
+	background             sync.WaitGroup
+	configFile             string
+	configProfile          string
+	m                      sync.Mutex
+	configM                sync.RWMutex
+	// reloadM serializes configuration changes (see ReloadConfig), from reading the
+	// current configuration until the new configuration has been applied
+	reloadM sync.Mutex
 }
 
+// errAdminOnly is returned by handleCommand when a non-Admin requests an Admin command
+var errAdminOnly = errors.New("command is restricted to admins")
+
 // BotContext provides context for Bot Messages
 type BotContext struct {
 	message *tgbotapi.Message
 	cbQuery *tgbotapi.CallbackQuery
 	User    *User
+
+	// Audit outcome reported by command handlers (see setAuditOutcome)
+	auditOutcome string
+	auditErr     error
+	auditArgs    *string
 }
 
 // IsCallBackQuery will evaluate the BotContext and determine if it is a CallBackQueyr or not
@@ -147,21 +190,35 @@ func (bot *Bot) handleForwardedMessageFrom(ctx *Context, id int) error {
 }
 */
 
+// handleCommand dispatches the command to its handler and records the outcome in the audit log
 func (bot *Bot) handleCommand(ctx *BotContext, command, args string) error {
+	err := bot.routeCommand(ctx, command, args)
+	bot.auditCommand(ctx, command, args, err)
+	return err
+}
+
+func (bot *Bot) routeCommand(ctx *BotContext, command, args string) error {
+	if !ctx.User.Admin {
+		if _, found := bot.adminCommandHandlers[command]; found {
+			return errAdminOnly
+		}
+	}
+
 	if !ctx.User.Banned {
 		handler, found := bot.commandHandlers[command]
 		if found {
-			return handler(bot, ctx, command, args)
+			return bot.dispatchCommand(ctx, handler, command, args)
 		}
 	}
 
 	if ctx.User.Admin {
 		handler, found := bot.adminCommandHandlers[command]
 		if found {
-			return handler(bot, ctx, command, args)
+			return bot.dispatchCommand(ctx, handler, command, args)
 		}
 	}
 
+	ctx.setAuditOutcome(wcaudit.OutcomeUnknown, nil)
 	return fmt.Errorf("Command not found: %s", command)
 }
 
@@ -180,11 +237,14 @@ func (bot *Bot) handlePrivateMessage(ctx *BotContext) error {
 	if ctx.message.IsCommand() {
 		cmd, args := ctx.message.Command(), ctx.message.CommandArguments()
 		err := bot.handleCommand(ctx, cmd, args)
-		if err != nil {
+		if err == errAdminOnly {
+			log.Debugf("Bot.handlePrivateMessage: '/%s' requested by non-Admin %s", cmd, ctx.User.NameAndTags())
+			return bot.Reply(ctx, "text", fmt.Sprintf(wcconst.MsgAdminOnly, cmd))
+		} else if err != nil {
 			errmsg := fmt.Sprintf("Sorry,'/%s' is an unknown command.\n\n%s", cmd, wcconst.MsgHelpShort)
 
 			//log.Debugf("Command: '/%s %s' failed: %v", cmd, args, err)
-			log.Debugf(errmsg)
+			log.Debug(errmsg)
 			//return bot.Reply(ctx, "markdown", fmt.Sprintf("Command failed: %v", err))
 			return bot.Reply(ctx, "markdown", errmsg)
 		}
@@ -205,118 +265,6 @@ func (bot *Bot) handlePrivateMessage(ctx *BotContext) error {
 	return nil
 }
 
-/*
-func (bot *Bot) handleUserJoin(ctx *Context, user *tgbotapi.User) error {
-	if user.ID == bot.telegram.Self.ID {
-		log.Printf("i have joined the group")
-		return nil
-	}
-	dbuser := bot.db.GetUser(user.ID)
-	if dbuser == nil {
-		dbuser = &User{
-			ID:        user.ID,
-			UserName:  user.UserName,
-			FirstName: user.FirstName,
-			LastName:  user.LastName,
-		}
-	}
-	dbuser.Enlisted = true
-	if err := bot.db.PutUser(dbuser); err != nil {
-		log.Printf("failed to save the user")
-		return err
-	}
-
-	log.Printf("user joined: %s", dbuser.NameAndTags())
-	return nil
-}
-*/
-
-/*
-func (bot *Bot) handleUserLeft(ctx *Context, user *tgbotapi.User) error {
-	if user.ID == bot.telegram.Self.ID {
-		log.Printf("i have left the group")
-		return nil
-	}
-	dbuser := bot.db.GetUser(user.ID)
-	if dbuser != nil {
-		dbuser.Enlisted = false
-		if err := bot.db.PutUser(dbuser); err != nil {
-			log.Printf("failed to save the user")
-			return err
-		}
-
-		log.Printf("user left: %s", dbuser.NameAndTags())
-	}
-	return nil
-}
-*/
-
-/*
-func (bot *Bot) removeMyName(text string) (string, bool) {
-	var removed bool
-	var words []string
-	for _, word := range strings.Fields(text) {
-		if word == "@"+bot.telegram.Self.UserName {
-			removed = true
-			continue
-		}
-		words = append(words, word)
-	}
-	return strings.Join(words, " "), removed
-}
-*/
-
-/*
-func (bot *Bot) isReplyToMe(ctx *BotContext) bool {
-	if re := ctx.message.ReplyToMessage; re != nil {
-		if u := re.From; u != nil {
-			if u.ID == bot.telegram.Self.ID {
-				return true
-			}
-		}
-	}
-	return false
-}
-*/
-
-/*
-func (bot *Bot) handleGroupMessage(ctx *BotContext) error {
-	var gerr error
-
-		if u := ctx.message.NewChatMembers; u != nil {
-			for _, user := range *u {
-				if err := bot.handleUserJoin(ctx, &user); err != nil {
-					gerr = err
-				}
-			}
-		}
-
-		if u := ctx.message.LeftChatMember; u != nil {
-			if err := bot.handleUserLeft(ctx, u); err != nil {
-				gerr = err
-			}
-		}
-
-	if ctx.User != nil {
-		msgWithoutName, mentioned := bot.removeMyName(ctx.message.Text)
-
-		if mentioned || bot.isReplyToMe(ctx) {
-			for i := len(bot.groupMessageHandlers) - 1; i >= 0; i-- {
-				handler := bot.groupMessageHandlers[i]
-				next, err := handler(bot, ctx, msgWithoutName)
-				if err != nil {
-					return fmt.Errorf("group message handler failed: %v", err)
-				}
-				if !next {
-					break
-				}
-			}
-		}
-	}
-	return gerr
-}
-*/
-
 // SendReplyInlineKeyboard will send a reply using the provided inline keyboard
 func (bot *Bot) SendReplyInlineKeyboard(ctx *BotContext, kb tgbotapi.InlineKeyboardMarkup, text string) error {
 	log.Debug("Bot.SendReplyInlineKeyboard: Start")
@@ -325,17 +273,16 @@ func (bot *Bot) SendReplyInlineKeyboard(ctx *BotContext, kb tgbotapi.InlineKeybo
 	var msg tgbotapi.MessageConfig
 
 	if ctx == nil {
-		msg = tgbotapi.NewMessage(bot.config.Telegram.ChatID, text)
-	} else if ctx.IsCallBackQuery() {
-		msg = tgbotapi.NewMessage(int64(ctx.cbQuery.From.ID), text)
+		msg = tgbotapi.NewMessage(bot.getConfig().Telegram.ChatID, text)
 	} else {
-		msg = tgbotapi.NewMessage(int64(ctx.message.From.ID), text)
+		// Reply within the chat the message (or button) originated from.
+		// For private chats this is the user, for group chats it is the group.
+		msg = tgbotapi.NewMessage(ctx.message.Chat.ID, text)
 	}
 	msg.ParseMode = "Markdown"
 	msg.ReplyMarkup = kb
 
-	_, err := bot.telegram.Send(msg)
-	return err
+	return bot.outbox.deliver(msg.ChatID, msg, nil)
 }
 
 // Send will send a new message from the Bot using the provided BotContext
@@ -350,7 +297,7 @@ func (bot *Bot) Send(ctx *BotContext, mode, format, text string) error {
 		msg = tgbotapi.NewMessage(ctx.message.Chat.ID, text)
 		msg.ReplyToMessageID = ctx.message.MessageID
 	case "yell":
-		msg = tgbotapi.NewMessage(bot.config.Telegram.ChatID, text)
+		msg = tgbotapi.NewMessage(bot.getConfig().Telegram.ChatID, text)
 	default:
 		return fmt.Errorf("unsupported message mode: %s", mode)
 	}
@@ -364,8 +311,30 @@ func (bot *Bot) Send(ctx *BotContext, mode, format, text string) error {
 	default:
 		return fmt.Errorf("unsupported message format: %s", format)
 	}
-	_, err := bot.telegram.Send(msg)
-	return err
+	return bot.outbox.deliver(msg.ChatID, msg, nil)
+}
+
+// SendDocument sends a file (named name, containing data) with a caption, using the provided
+// message mode (see Send). Used for content which is too long for a message.
+func (bot *Bot) SendDocument(ctx *BotContext, mode, name string, data []byte, caption string) error {
+	var chatID int64
+	switch mode {
+	case "whisper":
+		chatID = int64(ctx.message.From.ID)
+	case "reply":
+		chatID = ctx.message.Chat.ID
+	case "yell":
+		chatID = bot.getConfig().Telegram.ChatID
+	default:
+		return fmt.Errorf("unsupported message mode: %s", mode)
+	}
+
+	doc := tgbotapi.NewDocumentUpload(chatID, tgbotapi.FileBytes{Name: name, Bytes: data})
+	doc.Caption = caption
+	if mode == "reply" {
+		doc.ReplyToMessageID = ctx.message.MessageID
+	}
+	return bot.outbox.deliver(chatID, doc, nil)
 }
 
 /*
@@ -408,9 +377,9 @@ func (bot *Bot) Ask(ctx *BotContext, text string) error {
 }
 */
 
-// SendNewMessage will send a new message without requiring a BotContext.
-func (bot *Bot) SendNewMessage(format, text string) error {
-	msg := tgbotapi.NewMessage(bot.config.Telegram.ChatID, text)
+// newMessage creates a message to chatID in the provided format
+func newMessage(chatID int64, format, text string) (tgbotapi.MessageConfig, error) {
+	msg := tgbotapi.NewMessage(chatID, text)
 
 	switch format {
 	case "markdown":
@@ -420,10 +389,20 @@ func (bot *Bot) SendNewMessage(format, text string) error {
 	case "text":
 		msg.ParseMode = ""
 	default:
-		return fmt.Errorf("unsupported message format: %s", format)
+		return msg, fmt.Errorf("unsupported message format: %s", format)
 	}
-	_, err := bot.telegram.Send(msg)
-	return err
+	return msg, nil
+}
+
+// SendNewMessage will send a new message without requiring a BotContext.
+// These messages are alerts, which are retried until they are delivered (see sendQueue).
+func (bot *Bot) SendNewMessage(format, text string) error {
+	chatID := bot.getConfig().Telegram.ChatID
+	msg, err := newMessage(chatID, format, text)
+	if err != nil {
+		return err
+	}
+	return bot.outbox.deliver(chatID, msg, &wcstate.PendingAlert{ChatID: chatID, Format: format, Text: text, Queued: time.Now()})
 }
 
 // Reply will respond to a message received by the Bot in the BotContext (ctx).
@@ -433,128 +412,131 @@ func (bot *Bot) Reply(ctx *BotContext, format, text string) error {
 }
 
 func (bot *Bot) handleMessage(ctx *BotContext) error {
-	// Check to ensure the User sending the message is registered in the Bots config
-	// as the Admin user. Ignore any message or command from anyone else
-	// Fixed #10
-	if fmt.Sprintf("@%s", ctx.message.Chat.UserName) != bot.config.Telegram.Admin {
-		log.Debugf("Bot.handleMessage: Ignoring message from non-owner user chat %d (%s)", ctx.message.Chat.ID, "@"+ctx.message.Chat.UserName)
-		return nil
+	if (ctx.message.Chat.IsGroup() || ctx.message.Chat.IsSuperGroup()) && ctx.message.Chat.ID == bot.getConfig().Telegram.ChatID {
+		// Authorization of group messages is performed once we know the message
+		// is addressed to the Bot - otherwise regular group chatter would be reported
+		log.Debug("Bot.handleMessage: handleGroupMessage")
+		return bot.handleGroupMessage(ctx)
 	}
 
-	// If this is NOT a prive chat then DONT respond
+	// If this is NOT a private chat or our configured group then DONT respond
 	if !ctx.message.Chat.IsPrivate() {
 		log.Debugf("Bot.handleMessage: Unknown chat %d (%s)", ctx.message.Chat.ID, ctx.message.Chat.UserName)
 		return nil
 	}
 
+	// Check to ensure the User sending the message is bound (by Telegram user ID)
+	// as an authorized user. Ignore any message or command from anyone else
+	// Fixed #10
+	var command string
+	if ctx.message.IsCommand() {
+		command = ctx.message.Command()
+	}
+	if !bot.authorizeUser(ctx, command) {
+		log.Debugf("Bot.handleMessage: Ignoring message from unauthorized user chat %d (%s)", ctx.message.Chat.ID, "@"+ctx.message.Chat.UserName)
+		return nil
+	}
+
 	log.Debug("Bot.handleMessage: handlePrivateMessage")
 	return bot.handlePrivateMessage(ctx)
-
-	/*
-		if (ctx.message.Chat.IsGroup() || ctx.message.Chat.IsSuperGroup()) && ctx.message.Chat.ID == bot.config.Telegram.ChatID {
-			log.Debug("Bot.handleMessage - handleGroupMessage")
-			//return bot.handleGroupMessage(ctx)
-			log.Printf("unknown chat %d (%s)", ctx.message.Chat.ID, ctx.message.Chat.UserName)
-			return nil
-		} else if ctx.message.Chat.IsPrivate() {
-			log.Debug("Bot.handleMessage - handlePrivateMessage")
-			return bot.handlePrivateMessage(ctx)
-		} else {
-			log.Debugf("unknown chat %d (%s)", ctx.message.Chat.ID, ctx.message.Chat.UserName)
-			return nil
-		}
-	*/
 }
 
 func (bot *Bot) handleCallbackQuery(ctx *BotContext) error {
-	// Check to ensure the User sending the message is registered in the Bots config
-	// as the Admin user. Ignore any message or command from anyone else
-	// Fixed #10
-	if fmt.Sprintf("@%s", ctx.message.Chat.UserName) != bot.config.Telegram.Admin {
-		log.Debugf("Bot.handleCallbackQuery: Ignoring message from non-owner user chat %d (%s)", ctx.message.Chat.ID, "@"+ctx.message.Chat.UserName)
+	// Only respond to buttons within a private chat or our configured group
+	if !ctx.message.Chat.IsPrivate() && ctx.message.Chat.ID != bot.getConfig().Telegram.ChatID {
+		log.Debugf("Bot.handleCallbackQuery: Unknown chat %d (%s)", ctx.message.Chat.ID, ctx.message.Chat.UserName)
 		return nil
 	}
 
-	// If this is NOT a prive chat then DONT respond
-	if !ctx.message.Chat.IsPrivate() {
-		log.Debugf("Bot.handleCallbackQuery: Unknown chat %d (%s)", ctx.message.Chat.ID, ctx.message.Chat.UserName)
+	// Check to ensure the User pressing the button is bound (by Telegram user ID)
+	// as an authorized user. Ignore any callback from anyone else
+	// Fixed #10
+	if !bot.authorizeUser(ctx, ctx.cbQuery.Data) {
+		log.Debugf("Bot.handleCallbackQuery: Ignoring callback from unauthorized user %d (%s)", ctx.cbQuery.From.ID, "@"+ctx.cbQuery.From.UserName)
 		return nil
 	}
 
-	//log.Debug("Bot.handleMessage: handlePrivateMessage")
-	//return bot.handlePrivateMessage(ctx)
-	return bot.handleCommand(ctx, ctx.cbQuery.Data, "")
-}
-
-// initGAClient will initialise the GA client and send the first event
-func (bot *Bot) initGAClient() {
-	log.Debugf("InitGAClient: Start")
-	defer log.Debugf("InitGAClient: End")
-	var err error
-	bot.gaclient, err = ga.NewClient(wcconst.AnalyticsID)
-	if err != nil {
-		panic(err)
+	// Callback data is formatted as a command optionally followed by arguments
+	cmd, args := splitCallbackData(ctx.cbQuery.Data)
+	err := bot.handleCommand(ctx, cmd, args)
+	if err == errAdminOnly {
+		log.Debugf("Bot.handleCallbackQuery: '%s' requested by non-Admin %s", cmd, ctx.User.NameAndTags())
+		return bot.Send(ctx, "yell", "text", fmt.Sprintf(wcconst.MsgAdminOnly, cmd))
 	}
+	return err
+}
 
-	bot.gaclient.DataSource("app")
-	bot.gaclient.ClientID(bot.config.AppAnalytics.UserID)
-	bot.gaclient.UserID(bot.config.AppAnalytics.UserID)
-	bot.gaclient.ApplicationName("Wing Commander")
-	bot.gaclient.ApplicationVersion(wcconst.BotVersion)
-	userLocale, err := jibber_jabber.DetectIETF()
-	if err == nil {
-		bot.gaclient.UserLanguage(userLocale)
+// newTelemetry creates the Telemetry used to record usage statistics. Statistics are
+// always kept locally (see /stats), and are only sent to `wingcommander.analyticsurl`
+// if the user has opted in using `wingcommander.analyticsenabled`.
+func newTelemetry(config wcconfig.Config) *wctelemetry.Telemetry {
+	if !config.WingCommander.AnalyticsEnabled || config.WingCommander.AnalyticsURL == "" {
+		return wctelemetry.New(nil)
 	}
-	bot.gaclient.UseTLS = true
-	bot.SendGAEvent("AppInit", "InitGAClient", "Init GA Client")
+	log.Infof("Bot.newTelemetry: Usage statistics will be sent to %s", config.WingCommander.AnalyticsURL)
+	return wctelemetry.New(wctelemetry.NewHTTPSink(config.WingCommander.AnalyticsURL,
+		config.AppAnalytics.UserID, wcconst.BotVersion))
 }
 
-// SendGAEvent will send a GA Event on the
-func (bot *Bot) SendGAEvent(category, action, label string) {
-	if bot.config.WingCommander.AnalyticsEnabled && bot.gaclient != nil {
-		log.Debugf("Bot.SendGAEvent: Start: Cat: %s Act: %s Lab: %s", category, action, label)
-		defer log.Debugf("Bot.SendGAEvent: End")
-		err := bot.gaclient.Send(ga.NewEvent(category, action).Label(label))
-		if err != nil {
-			log.Errorf("Bot.SendGAEvent: Error: %v", err)
-		}
-	}
+// RecordEvent records an application usage event (see newTelemetry). It never blocks.
+func (bot *Bot) RecordEvent(category, action, label string) {
+	log.Debugf("Bot.RecordEvent: Cat: %s Act: %s Lab: %s", category, action, label)
+	bot.telemetry.Record(category, action, label)
 }
 
 // NewBot will create a new instance of a Bot struct based on the passed Config structure
 // which supplies runtime configuration for the bot.
-func NewBot(config wcconfig.Config) (*Bot, error) {
+// The provided State is used to persist the Admin user IDs bound at runtime, and
+// the provided audit Log is used to record the commands issued to the bot.
+func NewBot(config wcconfig.Config, state *wcstate.State, auditlog *wcaudit.Log) (*Bot, error) {
 	var bot = Bot{
 		config:               wcconfig.Config{},
+		state:                state,
+		audit:                auditlog,
 		commandHandlers:      make(map[string]CommandHandler),
 		adminCommandHandlers: make(map[string]CommandHandler),
+		protectedCommands:    make(map[string]bool),
+		pendingConfirmations: make(map[string]*pendingConfirmation),
+		unknownUsers:         make(map[int]bool),
+		hostBreaches:         make(map[string]bool),
+		loops:                make(map[string]context.CancelFunc),
 	}
 	bot.config = config
 	var err error
 
-	if config.WingCommander.AnalyticsEnabled {
-		bot.initGAClient()
-	}
+	bot.telemetry = newTelemetry(config)
+	bot.RecordEvent("AppInit", "NewBot", "Bot Created")
 
 	bot.skyMgrMonitor = skymgrmon.NewMonitor(config.SkyManager.Address, config.SkyManager.DiscoveryAddress)
+	bot.hostCollector = hostmon.NewCollector(config.Host.DiskPath)
 
-	if bot.telegram, err = tgbotapi.NewBotAPI(config.Telegram.APIKey); err != nil {
-		return nil, fmt.Errorf("Failed to initialize Telegram API: %v", err)
+	if len(config.Probe.Targets) > 0 {
+		if bot.probeMonitor, err = netprobe.NewMonitor(config.Probe.Targets, config.Probe.TimeoutSec); err != nil {
+			return nil, fmt.Errorf("Failed to initialize network probes: %v", err)
+		}
 	}
 
-	bot.telegram.Debug = config.Telegram.Debug
-
-	chat, err := bot.telegram.GetChat(tgbotapi.ChatConfig{config.Telegram.ChatID, ""})
-	if err != nil {
-		return nil, fmt.Errorf("Failed to get chat info from Telegram: %v", err)
+	if bot.updater, err = newUpdater(config); err != nil {
+		return nil, fmt.Errorf("Failed to initialize updater: %v", err)
 	}
 
-	if !chat.IsPrivate() {
-		return nil, fmt.Errorf("Only private chats are supported")
+	if bot.versionSource, err = newVersionSource(config); err != nil {
+		return nil, fmt.Errorf("Failed to initialize Skywire version source: %v", err)
 	}
 
-	log.Printf("Bot User: %d %s", bot.telegram.Self.ID, bot.telegram.Self.UserName)
-	log.Printf("Bot Chat: %s %d %s", chat.Type, chat.ID, chat.Title)
+	// The Bot is created even if Telegram can not be reached, so monitoring starts regardless.
+	// It connects in the background (see pollUpdates), and messages are delivered once connected.
+	bot.telegram = &tgbotapi.BotAPI{Token: config.Telegram.APIKey, Client: &http.Client{Timeout: requestTimeout}, Buffer: 100}
+	bot.telegram.Debug = config.Telegram.Debug
+	tgbotapi.SetLogger(telegramLogger{})
+	bot.outbox = bot.newOutbox()
+
+	if retry, err := bot.connect(); err != nil {
+		if !retry {
+			return nil, err
+		}
+		log.Warnf("NewBot: Telegram can not be reached (will keep retrying): %v", err)
+	}
 
 	bot.setCommandHandlers()
 	return &bot, nil
@@ -580,7 +562,21 @@ func (bot *Bot) handleUpdate(update *tgbotapi.Update) error {
 			cbQuery: update.CallbackQuery}
 	}
 
-	if u := ctx.message.From; u != nil {
+	// Other updates (i.e. edited messages, channel posts or the Bot being added to a group),
+	// and buttons of inline messages, have no message to handle
+	if ctx.message == nil {
+		log.Debugf("Bot.handleUpdate: Ignoring update %d which has no message", update.UpdateID)
+		return nil
+	}
+
+	// The sender of a CallbackQuery is the user who pressed the button, not the
+	// sender of the message the button is attached to (which is the Bot itself)
+	from := ctx.message.From
+	if ctx.cbQuery != nil {
+		from = ctx.cbQuery.From
+	}
+
+	if u := from; u != nil {
 		ctx.User = &User{
 			ID:        u.ID,
 			UserName:  u.UserName,
@@ -591,11 +587,11 @@ func (bot *Bot) handleUpdate(update *tgbotapi.Update) error {
 
 	if update.CallbackQuery != nil {
 		log.Debugln("Bot.handleUpdate: handleCallbackQuery")
-		bot.SendGAEvent("BotMessageHandler", "CallbackQuery", "CallbackQuery Handler")
+		bot.RecordEvent("BotMessageHandler", "CallbackQuery", "CallbackQuery Handler")
 		err = bot.handleCallbackQuery(&ctx)
 	} else {
 		log.Debugln("Bot.handleUpdate: handleMessage")
-		bot.SendGAEvent("BotMessageHandler", "Message", "Message Handler")
+		bot.RecordEvent("BotMessageHandler", "Message", "Message Handler")
 		err = bot.handleMessage(&ctx)
 	}
 
@@ -608,6 +604,16 @@ func (bot *Bot) handleUpdate(update *tgbotapi.Update) error {
 	return err
 }
 
+// showMenuAfterUpdate determines if the main menu should be resent after handling the provided update.
+// Within group chats the menu is only resent after a button is pressed, otherwise
+// regular group chatter would cause the menu to be sent for every message.
+func showMenuAfterUpdate(update *tgbotapi.Update) bool {
+	if update.CallbackQuery != nil {
+		return update.CallbackQuery.Message != nil
+	}
+	return update.Message != nil && update.Message.Chat != nil && update.Message.Chat.IsPrivate()
+}
+
 // SendMainMenuMessage will send a main menu message
 func (bot *Bot) SendMainMenuMessage(ctx *BotContext) error {
 	var menuKB tgbotapi.InlineKeyboardMarkup
@@ -622,29 +628,59 @@ func (bot *Bot) SendMainMenuMessage(ctx *BotContext) error {
 	return bot.SendReplyInlineKeyboard(ctx, menuKB, "*Menu*")
 }
 
-// Start will start the Bot running - the main duty being to monitor for and handle messages
-func (bot *Bot) Start() {
+// Start will start the Bot running - the main duty being to monitor for and handle messages.
+// Updates are received by polling Telegram, or by serving a webhook (if webhook.enabled is set).
+// It returns once ctx is done (see Shutdown), or if the webhook can not be served. Telegram is
+// reconnected in the background if it can not be reached.
+func (bot *Bot) Start(ctx context.Context) error {
 	log.Infoln("BOT: Starting.")
 	defer log.Infoln("BOT: Stopped")
-	bot.SendGAEvent("AppInit", "BotStart", "Bot Starting")
+	bot.RecordEvent("AppInit", "BotStart", "Bot Starting")
 
-	update := tgbotapi.NewUpdate(0)
-	update.Timeout = 60
+	bot.m.Lock()
+	bot.runctx = ctx
+	bot.m.Unlock()
 
 	// Start the Bot Running (in the background)
 	log.Infoln("Skywire Wing Commander Telegram Bot - Ready for duty.")
 	defer log.Infoln("Skywire Wing Commander Telegram Bot - Signing off.")
 
-	updates, err := bot.telegram.GetUpdatesChan(update)
-	if err != nil {
-		log.Fatalf("Bot.Start: Failed to create Telegram updates channel: %v", err)
+	updates := make(chan tgbotapi.Update, bot.telegram.Buffer)
+	if bot.getConfig().Webhook.Enabled {
+		if err := bot.serveWebhook(ctx, updates); err != nil {
+			return err
+		}
+	} else {
+		go bot.pollUpdates(ctx, updates)
+	}
+
+	// Periodically check for new releases, the Skywire versions run by the Nodes,
+	// the resources of the host and the reachability of Nodes on the LAN in the background
+	for name := range backgroundLoops {
+		bot.startLoop(name)
 	}
+	// Retry messages which could not be delivered
+	bot.goBackground(func() { bot.outbox.run(ctx) })
+
+	for {
+		var update tgbotapi.Update
+		select {
+		case update = <-updates:
+		case <-ctx.Done():
+			return nil
+		}
 
-	for update := range updates {
-		//bot.SendGAEvent("BotMessages", "HandleUpdates", "Handle Updates Loop")
+		//bot.RecordEvent("BotMessages", "HandleUpdates", "Handle Updates Loop")
 		if err := bot.handleUpdate(&update); err != nil {
 			log.Errorf("Bot.Start: Error: %v", err)
 		}
+		if req := bot.takeRestartRequest(); req != nil {
+			bot.restart(update.UpdateID, req)
+			continue
+		}
+		if !showMenuAfterUpdate(&update) {
+			continue
+		}
 		if err := bot.SendMainMenuMessage(nil); err != nil {
 			log.Errorf("Bot.Start: Error: %v", err)
 		}
@@ -0,0 +1,46 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package telegrambot
+
+import (
+	"encoding/json"
+	"testing"
+
+	tgbotapi "gopkg.in/telegram-bot-api.v4"
+)
+
+func Test_HandleUpdate_NoMessage(t *testing.T) {
+	bot, ft := newTestBot(t, reloadTestConfig())
+	defer removeTestState(bot)
+
+	// Updates other than messages and button presses are ignored
+	testCases := []struct {
+		name   string
+		update string
+	}{
+		{"Edited message", `{"update_id":10002,"edited_message":{"message_id":7,"date":1546300800,"edit_date":1546300860,` +
+			`"from":{"id":123456789,"is_bot":false,"first_name":"Test","username":"TESTUSER"},` +
+			`"chat":{"id":123456789,"type":"private","username":"TESTUSER"},"text":"/status"}}`},
+		{"Channel post", `{"update_id":10003,"channel_post":{"message_id":8,"date":1546300800,` +
+			`"chat":{"id":-1001,"type":"channel","title":"Nodes"},"text":"/status"}}`},
+		{"Inline button", `{"update_id":10004,"callback_query":{"id":"1","from":{"id":123456789,"username":"TESTUSER"},` +
+			`"inline_message_id":"2","data":"status"}}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var update tgbotapi.Update
+			if err := json.Unmarshal([]byte(tc.update), &update); err != nil {
+				t.Fatal(err)
+			}
+			if err := bot.handleUpdate(&update); err != nil {
+				t.Errorf("Expected: the update to be ignored, got %v", err)
+			}
+			if sent := ft.sent("sendMessage"); len(sent) != 0 {
+				t.Errorf("Expected: no reply, got %v", sent)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package telegrambot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/totp"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcaudit"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	log "github.com/sirupsen/logrus"
+	tgbotapi "gopkg.in/telegram-bot-api.v4"
+)
+
+// defaultConfirmExpiry is used if no (valid) confirmation expiry has been configured
+const defaultConfirmExpiry = 60 * time.Second
+
+// pendingConfirmation models a protected command which is awaiting confirmation
+type pendingConfirmation struct {
+	command string
+	args    string
+	userID  int
+	expires time.Time
+	handler CommandHandler
+}
+
+// requiresConfirmation determines if the provided command must be confirmed before it is executed
+func (bot *Bot) requiresConfirmation(command string) bool {
+	if nodeControlCommands[command] {
+		return true
+	}
+	return bot.getConfig().WingCommander.TwoFactorEnabled && bot.protectedCommands[command]
+}
+
+// getTwoFactorSecret returns the TOTP secret. A secret provided in the config takes
+// precedence over a secret provisioned at runtime (using `/2fasetup`)
+func (bot *Bot) getTwoFactorSecret() string {
+	if secret := bot.getConfig().WingCommander.TwoFactorSecret; secret != "" {
+		return secret
+	}
+	if bot.state != nil {
+		return bot.state.GetTwoFactorSecret()
+	}
+	return ""
+}
+
+// dispatchCommand executes the command handler, first requesting confirmation
+// if the command is protected
+func (bot *Bot) dispatchCommand(ctx *BotContext, handler CommandHandler, command, args string) error {
+	if !bot.requiresConfirmation(command) {
+		return handler(bot, ctx, command, args)
+	}
+
+	args, err := bot.resolveNodeKeyArg(command, args)
+	if err != nil {
+		bot.logControlAction(ctx, command, args, err)
+		return bot.replyControlResult(ctx, "Bot.dispatchCommand", "", err)
+	}
+
+	log.Debugf("Bot.dispatchCommand: Command '/%s' requires confirmation (%s)", command, bot.getConfig().WingCommander.TwoFactorMode)
+	if bot.getConfig().WingCommander.TwoFactorEnabled && bot.getConfig().WingCommander.TwoFactorMode != wcconfig.TwoFactorModeConfirm {
+		return bot.verifyTOTPAndDispatch(ctx, handler, command, args)
+	}
+	// Inline confirmation is used when configured, or for node control
+	// commands when two factor confirmation is not enabled
+	return bot.requestInlineConfirmation(ctx, handler, command, args)
+}
+
+// verifyTOTPAndDispatch expects the last argument of the command to be a valid TOTP code.
+// The command handler is executed (without the code) only if the code is valid.
+func (bot *Bot) verifyTOTPAndDispatch(ctx *BotContext, handler CommandHandler, command, args string) error {
+	secret := bot.getTwoFactorSecret()
+	if secret == "" {
+		log.Warnf("Bot.verifyTOTPAndDispatch: Two factor is enabled but no secret has been provisioned. Refusing '/%s'.", command)
+		ctx.setAuditOutcome(wcaudit.OutcomeDenied, errors.New("two factor not provisioned"))
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", wcconst.MsgTwoFactorNotProvisioned)
+	}
+
+	fields := strings.Fields(args)
+	if len(fields) == 0 || !isTOTPCode(fields[len(fields)-1]) {
+		cmdline := strings.TrimSpace(command + " " + args)
+		ctx.setAuditOutcome(wcaudit.OutcomeDenied, errors.New("two factor code required"))
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgTwoFactorCodeRequired, cmdline))
+	}
+	code := fields[len(fields)-1]
+	args = strings.Join(fields[:len(fields)-1], " ")
+	// Never record the code in the audit log
+	ctx.setAuditArgs(args)
+
+	step, ok := totp.Validate(secret, code, time.Now(), 1)
+
+	bot.m.Lock()
+	if ok && step <= bot.lastTOTPStep {
+		// Do not allow a code to be used more than once
+		ok = false
+	} else if ok {
+		bot.lastTOTPStep = step
+	}
+	bot.m.Unlock()
+
+	if !ok {
+		log.Warnf("Bot.verifyTOTPAndDispatch: Invalid two factor code for '/%s' from %s", command, ctx.User.NameAndTags())
+		ctx.setAuditOutcome(wcaudit.OutcomeDenied, errors.New("invalid two factor code"))
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgTwoFactorCodeInvalid, command))
+	}
+
+	log.Infof("Bot.verifyTOTPAndDispatch: Two factor code accepted for '/%s' from %s", command, ctx.User.NameAndTags())
+	return handler(bot, ctx, command, args)
+}
+
+// isTOTPCode determines if the provided argument looks like a TOTP code
+func isTOTPCode(arg string) bool {
+	if len(arg) != totp.Digits {
+		return false
+	}
+	for _, c := range arg {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// newConfirmationToken generates a random token to identify a pending confirmation
+func newConfirmationToken() (string, error) {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// requestInlineConfirmation stores the command as pending and asks the user to confirm it
+// using inline buttons. The confirmation expires after `wingcommander.twofactorexpirysec`.
+func (bot *Bot) requestInlineConfirmation(ctx *BotContext, handler CommandHandler, command, args string) error {
+	token, err := newConfirmationToken()
+	if err != nil {
+		return err
+	}
+
+	expiry := bot.getConfig().WingCommander.TwoFactorExpirySec
+	if expiry <= 0 {
+		expiry = defaultConfirmExpiry
+	}
+	now := time.Now()
+
+	bot.m.Lock()
+	// Prune any expired confirmations
+	for k, v := range bot.pendingConfirmations {
+		if now.After(v.expires) {
+			delete(bot.pendingConfirmations, k)
+		}
+	}
+	bot.pendingConfirmations[token] = &pendingConfirmation{
+		command: command,
+		args:    args,
+		userID:  ctx.User.ID,
+		expires: now.Add(expiry),
+		handler: handler,
+	}
+	bot.m.Unlock()
+
+	kb := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✅ Confirm", "confirm "+token),
+		tgbotapi.NewInlineKeyboardButtonData("❌ Cancel", "cancel "+token),
+	))
+
+	text := strings.TrimSpace("/" + command + " " + args)
+	ctx.setAuditOutcome(wcaudit.OutcomePending, nil)
+	err = bot.SendReplyInlineKeyboard(ctx, kb, fmt.Sprintf(wcconst.MsgConfirmCommand, text, expiry))
+	if err != nil {
+		logSendError("Bot.requestInlineConfirmation", err)
+	}
+	return err
+}
+
+// takePendingConfirmation removes and returns the pending confirmation identified by the
+// provided token. Only the user who requested the command may confirm or cancel it.
+func (bot *Bot) takePendingConfirmation(ctx *BotContext, token string) (*pendingConfirmation, string) {
+	token = strings.TrimSpace(token)
+
+	bot.m.Lock()
+	defer bot.m.Unlock()
+
+	pending, found := bot.pendingConfirmations[token]
+	if !found {
+		return nil, wcconst.MsgConfirmNotFound
+	}
+	if pending.userID != ctx.User.ID {
+		return nil, wcconst.MsgConfirmWrongUser
+	}
+	delete(bot.pendingConfirmations, token)
+
+	if time.Now().After(pending.expires) {
+		return nil, wcconst.MsgConfirmNotFound
+	}
+	return pending, ""
+}
+
+// Handler for confirm command (sent by the Confirm inline button)
+func (bot *Bot) handleCommandConfirm(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	pending, errmsg := bot.takePendingConfirmation(ctx, args)
+	if pending == nil {
+		ctx.setAuditOutcome(wcaudit.OutcomeFailed, errors.New(errmsg))
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", errmsg)
+	}
+
+	// Record the confirmed command (rather than just the token) in the audit log
+	ctx.setAuditArgs(strings.TrimSpace(args + " /" + pending.command + " " + pending.args))
+	log.Infof("Bot.handleCommandConfirm: '/%s' confirmed by %s", pending.command, ctx.User.NameAndTags())
+	return pending.handler(bot, ctx, pending.command, pending.args)
+}
+
+// Handler for cancel command (sent by the Cancel inline button)
+func (bot *Bot) handleCommandCancel(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	pending, errmsg := bot.takePendingConfirmation(ctx, args)
+	if pending == nil {
+		ctx.setAuditOutcome(wcaudit.OutcomeFailed, errors.New(errmsg))
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", errmsg)
+	}
+
+	ctx.setAuditArgs(strings.TrimSpace(args + " /" + pending.command + " " + pending.args))
+	ctx.setAuditOutcome(wcaudit.OutcomeCancelled, nil)
+	log.Infof("Bot.handleCommandCancel: '/%s' cancelled by %s", pending.command, ctx.User.NameAndTags())
+	return bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgConfirmCancelled, pending.command))
+}
+
+// Handler for 2fasetup command. Generates and provisions a TOTP secret (once only).
+// The provisioning URI is only ever sent to the Admin privately.
+func (bot *Bot) handleCommandTwoFactorSetup(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	if bot.getConfig().WingCommander.TwoFactorSecret != "" {
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", wcconst.MsgTwoFactorConfigured)
+	}
+	if bot.state == nil || bot.state.GetTwoFactorSecret() != "" {
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", wcconst.MsgTwoFactorAlreadyProvisioned)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return err
+	}
+
+	account := bot.getConfig().Telegram.Admin
+	if bot.telegram.Self.UserName != "" {
+		account = "@" + bot.telegram.Self.UserName
+	}
+	uri := totp.ProvisioningURI(secret, account, "Wing Commander")
+
+	msg := tgbotapi.NewMessage(int64(ctx.User.ID), fmt.Sprintf(wcconst.MsgTwoFactorProvisioned, uri, secret))
+	if _, err := bot.telegram.Send(msg); err != nil {
+		// Errors include the API URL, which contains the API key
+		config := bot.getConfig()
+		err = errors.New(config.Redact(err.Error()))
+		logSendError("Bot.handleCommandTwoFactorSetup", err)
+		return err
+	}
+
+	if err := bot.state.SetTwoFactorSecret(secret); err != nil {
+		log.Errorf("Bot.handleCommandTwoFactorSetup: Failed to persist two factor secret: %v", err)
+		return err
+	}
+	log.Infof("Bot.handleCommandTwoFactorSetup: Two factor secret provisioned by %s", ctx.User.NameAndTags())
+	return nil
+}
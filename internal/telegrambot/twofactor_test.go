@@ -0,0 +1,155 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package telegrambot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/totp"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+)
+
+const testTOTPSecret = "JBSWY3DPEHPK3PXP"
+
+func newTestTwoFactorBot(t *testing.T, mode string) (*Bot, *fakeTelegram) {
+	var config wcconfig.Config
+	config.Telegram.AdminIDs = []int{1001}
+	config.Telegram.Members = []int{2002}
+	config.WingCommander.TwoFactorEnabled = true
+	config.WingCommander.TwoFactorMode = mode
+	config.WingCommander.TwoFactorSecret = testTOTPSecret
+	config.WingCommander.TwoFactorExpirySec = time.Minute
+	return newTestBot(t, config)
+}
+
+// lastSent returns the last sendMessage request recorded by the fakeTelegram
+func lastSent(ft *fakeTelegram) string {
+	sent := ft.sent("sendMessage")
+	if len(sent) == 0 {
+		return ""
+	}
+	return sent[len(sent)-1]
+}
+
+func Test_TwoFactor_TOTP(t *testing.T) {
+	bot, ft := newTestTwoFactorBot(t, wcconfig.TwoFactorModeTOTP)
+	defer removeTestState(bot)
+
+	// Unprotected commands are not affected
+	if err := bot.handleMessage(newTestCommandCtx(1001, "admin", "/help")); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(lastSent(ft), "Telegram+Usage") {
+		t.Errorf("Expected: /help to be executed, got %s", lastSent(ft))
+	}
+
+	// Protected commands without a code are refused
+	if err := bot.handleMessage(newTestCommandCtx(1001, "admin", "/stop")); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(lastSent(ft), "requires+two+factor") {
+		t.Errorf("Expected: /stop to require a code, got %s", lastSent(ft))
+	}
+
+	// Protected commands with an invalid code are refused
+	if err := bot.handleMessage(newTestCommandCtx(1001, "admin", "/stop 000000")); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(lastSent(ft), "Invalid") {
+		t.Errorf("Expected: /stop to be refused, got %s", lastSent(ft))
+	}
+
+	// Protected commands with a valid code are executed
+	code, err := totp.Code(testTOTPSecret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.handleMessage(newTestCommandCtx(1001, "admin", "/stop "+code)); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(lastSent(ft), "Monitoring+is+not+running") {
+		t.Errorf("Expected: /stop to be executed, got %s", lastSent(ft))
+	}
+
+	// Codes can not be reused
+	if err := bot.handleMessage(newTestCommandCtx(1001, "admin", "/stop "+code)); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(lastSent(ft), "Invalid") {
+		t.Errorf("Expected: reused code to be refused, got %s", lastSent(ft))
+	}
+}
+
+func Test_TwoFactor_InlineConfirm(t *testing.T) {
+	bot, ft := newTestTwoFactorBot(t, wcconfig.TwoFactorModeConfirm)
+	defer removeTestState(bot)
+
+	ctx := newTestCommandCtx(1001, "admin", "/stop")
+	ctx.User.Admin = true
+	if err := bot.handleCommand(ctx, "stop", ""); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(lastSent(ft), "Confirm+%2Fstop") {
+		t.Errorf("Expected: confirmation request, got %s", lastSent(ft))
+	}
+
+	var token string
+	for k := range bot.pendingConfirmations {
+		token = k
+	}
+	if token == "" {
+		t.Fatal("Expected: A pending confirmation")
+	}
+
+	// Another user can not confirm the command
+	other := newTestCommandCtx(2002, "member", "/confirm")
+	if err := bot.handleCommand(other, "confirm", token); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(lastSent(ft), "Only+the+user") {
+		t.Errorf("Expected: confirmation from another user to be refused, got %s", lastSent(ft))
+	}
+
+	// The requesting user can confirm the command
+	if err := bot.handleCommand(ctx, "confirm", token); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(lastSent(ft), "Monitoring+is+not+running") {
+		t.Errorf("Expected: /stop to be executed, got %s", lastSent(ft))
+	}
+
+	// The confirmation can only be used once
+	if err := bot.handleCommand(ctx, "confirm", token); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(lastSent(ft), "expired") {
+		t.Errorf("Expected: confirmation to be expired, got %s", lastSent(ft))
+	}
+}
+
+func Test_TwoFactor_InlineConfirm_Expired(t *testing.T) {
+	bot, ft := newTestTwoFactorBot(t, wcconfig.TwoFactorModeConfirm)
+	defer removeTestState(bot)
+
+	ctx := newTestCommandCtx(1001, "admin", "/stop")
+	ctx.User.Admin = true
+	if err := bot.handleCommand(ctx, "stop", ""); err != nil {
+		t.Error(err)
+	}
+
+	var token string
+	for k, v := range bot.pendingConfirmations {
+		token = k
+		v.expires = time.Now().Add(-time.Second)
+	}
+	if err := bot.handleCommand(ctx, "confirm", token); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(lastSent(ft), "expired") {
+		t.Errorf("Expected: confirmation to be expired, got %s", lastSent(ft))
+	}
+}
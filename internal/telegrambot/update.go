@@ -0,0 +1,368 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package telegrambot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/updater"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcaudit"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcstate"
+	"github.com/google/go-github/github"
+	log "github.com/sirupsen/logrus"
+	tgbotapi "gopkg.in/telegram-bot-api.v4"
+)
+
+// newUpdater creates the Updater used to install new releases. If a release signing key
+// has been configured it must be loaded, otherwise releases signed with it would be rejected.
+func newUpdater(config wcconfig.Config) (*updater.Updater, error) {
+	u := updater.New("BigOokie", "skywire-wing-commander")
+	u.AllowUnsigned = config.WingCommander.UpdateAllowUnsigned
+	if config.WingCommander.UpdatePublicKey != "" {
+		if err := u.LoadPublicKey(config.WingCommander.UpdatePublicKey); err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// defaultUpdateHealthTimeout is used if no (valid) health check timeout has been configured
+const defaultUpdateHealthTimeout = 120 * time.Second
+
+// maxHealthCheckOutput limits the health check output included in a Telegram message
+const maxHealthCheckOutput = 1000
+
+// maxReleaseNotes limits the release notes included in a Telegram message
+const maxReleaseNotes = 3000
+
+// restartRequest models a request to restart using a newly installed version
+type restartRequest struct {
+	exe     string
+	version string
+}
+
+// requestRestart flags that the Bot should restart using the binary at exe once
+// the current update has been handled
+func (bot *Bot) requestRestart(exe, version string) {
+	bot.m.Lock()
+	defer bot.m.Unlock()
+	bot.pendingRestart = &restartRequest{exe: exe, version: version}
+}
+
+// takeRestartRequest returns (and clears) the pending restart request, if any
+func (bot *Bot) takeRestartRequest() *restartRequest {
+	bot.m.Lock()
+	defer bot.m.Unlock()
+	req := bot.pendingRestart
+	bot.pendingRestart = nil
+	return req
+}
+
+// updateHealthTimeout returns the time a newly installed version has to confirm it is healthy
+func (bot *Bot) updateHealthTimeout() time.Duration {
+	timeout := bot.getConfig().WingCommander.UpdateHealthTimeoutSec
+	if timeout <= 0 {
+		return defaultUpdateHealthTimeout
+	}
+	return timeout
+}
+
+// CheckHealth confirms that both Telegram and the Skywire Manager are reachable
+func (bot *Bot) CheckHealth() error {
+	if _, err := bot.telegram.GetMe(); err != nil {
+		// Errors include the API URL, which contains the API key
+		config := bot.getConfig()
+		return fmt.Errorf("telegram is not reachable: %s", config.Redact(err.Error()))
+	}
+	if _, err := bot.skyMgrMonitor.GetAllNodes(); err != nil {
+		return fmt.Errorf("manager is not reachable: %v", err)
+	}
+	return nil
+}
+
+// restart checks the newly installed version is healthy and then replaces the running process
+// with it. If the new version is not healthy the previous binary is restored and the Bot keeps
+// running. The Telegram update which requested the restart is acknowledged first so that it
+// is not handled again after the restart.
+func (bot *Bot) restart(updateID int, req *restartRequest) {
+	log.Infof("Bot.restart: Checking health of %s (%s)", req.version, req.exe)
+
+	output, err := updater.HealthCheck(req.exe, bot.updateHealthTimeout())
+	if err != nil {
+		log.Errorf("Bot.restart: Health check of %s failed: %v\n%s", req.version, err, output)
+		bot.reportRollback(req, err, output, updater.Rollback(req.exe))
+		return
+	}
+
+	upgrade := &wcstate.UpgradeState{
+		From:     wcconst.BotVersion,
+		To:       req.version,
+		Previous: updater.PreviousPath(req.exe),
+		Started:  time.Now(),
+	}
+	if bot.state != nil {
+		if err := bot.state.SetUpgrade(upgrade); err != nil {
+			log.Errorf("Bot.restart: Failed to record upgrade: %v", err)
+		}
+	}
+
+	// Updates received by the webhook have already been acknowledged
+	if !bot.getConfig().Webhook.Enabled {
+		_, err = bot.telegram.GetUpdates(tgbotapi.UpdateConfig{Offset: updateID + 1, Limit: 1})
+		if err != nil {
+			log.Warnf("Bot.restart: Failed to acknowledge update %d: %v", updateID, err)
+		}
+	}
+
+	log.Infof("Bot.restart: Restarting using %s", req.exe)
+	err = updater.Restart(req.exe, updater.UpgradeCompletedArgs())
+	log.Errorf("Bot.restart: %v", err)
+	if bot.state != nil {
+		_ = bot.state.SetUpgrade(nil)
+	}
+	if serr := bot.SendNewMessage("text", fmt.Sprintf(wcconst.MsgRestartFailed, err)); serr != nil {
+		logSendError("Bot.restart", serr)
+	}
+}
+
+// reportRollback reports a failed health check of a newly installed version to the Admin
+func (bot *Bot) reportRollback(req *restartRequest, err error, output string, rollbackErr error) {
+	// The output of the new version may include secrets (i.e. in its log)
+	config := bot.getConfig()
+	output = config.Redact(output)
+	if len(output) > maxHealthCheckOutput {
+		output = "..." + output[len(output)-maxHealthCheckOutput:]
+	}
+
+	msg := fmt.Sprintf(wcconst.MsgUpdateHealthCheckFailed, req.version, err, strings.TrimSpace(output))
+	if rollbackErr != nil {
+		log.Errorf("Bot.reportRollback: %v", rollbackErr)
+		msg += "\n\n" + fmt.Sprintf(wcconst.MsgRollbackFailed, rollbackErr)
+	} else {
+		msg += "\n\n" + fmt.Sprintf(wcconst.MsgRolledBack, wcconst.BotVersion)
+	}
+
+	if serr := bot.SendNewMessage("text", msg); serr != nil {
+		logSendError("Bot.reportRollback", serr)
+	}
+}
+
+// Handler for update command. Downloads, verifies and installs the latest release binary
+// and then restarts Wing Commander using the new binary.
+func (bot *Bot) handleCommandDoUpdate(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	err := bot.Send(ctx, getSendModeforContext(ctx), "markdown", "*Initiating update...*")
+	if err != nil {
+		logSendError("Bot.handleCommandDoUpdate", err)
+		return err
+	}
+
+	release, newer, err := bot.latestUpdate()
+	if err != nil {
+		return bot.updateFailed(ctx, err)
+	}
+	if !newer {
+		return bot.Send(ctx, getSendModeforContext(ctx), "markdown", fmt.Sprintf(wcconst.MsgUpdateAlreadyLatest, wcconst.BotVersion))
+	}
+
+	err = bot.Send(ctx, getSendModeforContext(ctx), "markdown", fmt.Sprintf(wcconst.MsgUpdateAvailable, wcconst.BotVersion, release.GetTagName()))
+	if err != nil {
+		logSendError("Bot.handleCommandDoUpdate", err)
+		return err
+	}
+
+	exe, err := updater.Executable()
+	if err != nil {
+		return bot.updateFailed(ctx, err)
+	}
+
+	binary, err := bot.updater.Download(release)
+	if err != nil {
+		return bot.updateFailed(ctx, err)
+	}
+
+	if err := updater.Install(exe, binary); err != nil {
+		return bot.updateFailed(ctx, err)
+	}
+
+	log.Infof("Bot.handleCommandDoUpdate: Installed %s to %s", release.GetTagName(), exe)
+	bot.requestRestart(exe, release.GetTagName())
+
+	err = bot.Send(ctx, getSendModeforContext(ctx), "markdown", fmt.Sprintf(wcconst.MsgUpdateInstalled, release.GetTagName()))
+	if err != nil {
+		logSendError("Bot.handleCommandDoUpdate", err)
+	}
+	return nil
+}
+
+// latestUpdate returns the latest release on the configured update channel, and
+// if it is newer than the running version
+func (bot *Bot) latestUpdate() (*github.RepositoryRelease, bool, error) {
+	prerelease := bot.getConfig().WingCommander.UpdateChannel == wcconfig.UpdateChannelPrerelease
+	release, err := bot.updater.LatestRelease(prerelease)
+	if err != nil {
+		return nil, false, err
+	}
+
+	newer, err := updater.IsNewer(release, wcconst.BotVersion)
+	if err != nil {
+		return nil, false, err
+	}
+	return release, newer, nil
+}
+
+// releaseNotes returns the (truncated) release notes of the provided release
+func releaseNotes(release *github.RepositoryRelease) string {
+	notes := strings.TrimSpace(release.GetBody())
+	if notes == "" {
+		return wcconst.MsgNoReleaseNotes
+	}
+	if len(notes) > maxReleaseNotes {
+		notes = notes[:maxReleaseNotes] + "..."
+	}
+	return notes
+}
+
+// sendUpdateAvailable sends details of the provided release, along with buttons to
+// install it or to skip it. Release notes are sent as plain text as they are written
+// in GitHub markdown which is not compatible with Telegram.
+func (bot *Bot) sendUpdateAvailable(chatID int64, release *github.RepositoryRelease) error {
+	tag := release.GetTagName()
+	text := fmt.Sprintf(wcconst.MsgUpdateNotify, wcconst.BotVersion, tag, releaseNotes(release))
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬆️ Update now", "update"),
+		tgbotapi.NewInlineKeyboardButtonData("🔕 Skip this version", "skipversion "+tag),
+	))
+	return bot.outbox.deliver(chatID, msg, nil)
+}
+
+// checkForUpdate checks for a new release and notifies the Admin. Each release is only
+// notified once, and releases the Admin has chosen to skip are not notified.
+func (bot *Bot) checkForUpdate() error {
+	release, newer, err := bot.latestUpdate()
+	if err != nil {
+		return err
+	}
+
+	tag := release.GetTagName()
+	if !newer {
+		log.Debugf("Bot.checkForUpdate: %s is up to date (latest: %s)", wcconst.BotVersion, tag)
+		return nil
+	}
+	if bot.state != nil && (tag == bot.state.GetLastNotifiedVersion() || tag == bot.state.GetSkippedUpdateVersion()) {
+		log.Debugf("Bot.checkForUpdate: Update to %s has already been notified or skipped", tag)
+		return nil
+	}
+
+	log.Infof("Bot.checkForUpdate: Update available: %s", tag)
+	if err := bot.sendUpdateAvailable(bot.getConfig().Telegram.ChatID, release); err != nil {
+		return err
+	}
+
+	if bot.state != nil {
+		return bot.state.SetLastNotifiedVersion(tag)
+	}
+	return nil
+}
+
+// updateCheckLoop periodically checks for a new release until the provided context is cancelled.
+// The check interval is configured by `wingcommander.updatecheckintmin` (0 disables the checks).
+func (bot *Bot) updateCheckLoop(runctx context.Context) {
+	interval := bot.getConfig().WingCommander.UpdateCheckIntMin
+	if interval <= 0 {
+		log.Infoln("Bot.updateCheckLoop: Automatic update checks are disabled.")
+		return
+	}
+
+	log.Infof("Bot.updateCheckLoop: Checking for updates every %v (%s channel)", interval, bot.getConfig().WingCommander.UpdateChannel)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := bot.checkForUpdate(); err != nil {
+			log.Errorf("Bot.updateCheckLoop: Update check failed: %v", err)
+		}
+
+		select {
+		case <-runctx.Done():
+			log.Debugln("Bot.updateCheckLoop: Stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Handler for checkupdate command
+func (bot *Bot) handleCommandCheckUpdate(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	err := bot.Send(ctx, getSendModeforContext(ctx), "markdown", "Checking for updates...")
+	if err != nil {
+		logSendError("Bot.handleCommandCheckUpdate", err)
+		return err
+	}
+
+	release, newer, err := bot.latestUpdate()
+	if err != nil {
+		log.Errorf("Bot.handleCommandCheckUpdate: %v", err)
+		ctx.setAuditOutcome(wcaudit.OutcomeFailed, err)
+		err = bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgUpdateCheckFailed, err))
+	} else if newer {
+		bot.RecordEvent("BotCommand", command+"-updateavailable", "Handle"+command)
+		err = bot.sendUpdateAvailable(ctx.message.Chat.ID, release)
+	} else {
+		bot.RecordEvent("BotCommand", command+"-uptodate", "Handle"+command)
+		err = bot.Send(ctx, getSendModeforContext(ctx), "markdown", fmt.Sprintf(wcconst.MsgUpdateAlreadyLatest, wcconst.BotVersion))
+	}
+
+	if err != nil {
+		logSendError("Bot.handleCommandCheckUpdate", err)
+	}
+	return err
+}
+
+// Handler for skipversion command (sent by the Skip inline button). Automatic
+// update checks will no longer notify the provided version.
+func (bot *Bot) handleCommandSkipVersion(ctx *BotContext, command, args string) error {
+	log.Debugf("Handle command: %s args: %s", command, args)
+	bot.RecordEvent("BotCommand", command, "Handle"+command)
+
+	tag := strings.TrimSpace(args)
+	if tag == "" || bot.state == nil {
+		return bot.Send(ctx, getSendModeforContext(ctx), "text", wcconst.MsgSkipVersionUsage)
+	}
+
+	if err := bot.state.SetSkippedUpdateVersion(tag); err != nil {
+		log.Errorf("Bot.handleCommandSkipVersion: %v", err)
+		return err
+	}
+
+	log.Infof("Bot.handleCommandSkipVersion: Update to %s skipped by %s", tag, ctx.User.NameAndTags())
+	return bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgSkipVersion, tag))
+}
+
+// updateFailed reports an update failure to the user and the audit log
+func (bot *Bot) updateFailed(ctx *BotContext, err error) error {
+	log.Errorf("Bot.handleCommandDoUpdate: Update failed: %v", err)
+	ctx.setAuditOutcome(wcaudit.OutcomeFailed, err)
+
+	// Errors may contain characters which are not valid markdown
+	sendErr := bot.Send(ctx, getSendModeforContext(ctx), "text", fmt.Sprintf(wcconst.MsgUpdateFailed, err))
+	if sendErr != nil {
+		logSendError("Bot.handleCommandDoUpdate", sendErr)
+	}
+	return sendErr
+}
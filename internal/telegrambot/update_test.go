@@ -0,0 +1,138 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package telegrambot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/updater"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+)
+
+// newFakeGitHub serves a release list containing a stable and a pre-release
+// version, both newer than the running version
+func newFakeGitHub() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[
+			{"tag_name": "v9.0.0", "body": "Stable release notes"},
+			{"tag_name": "v9.1.0-rc1", "prerelease": true, "body": "Pre-release notes"}
+		]`)
+	}))
+}
+
+func newTestUpdateBot(t *testing.T, channel string) (*Bot, *fakeTelegram, *httptest.Server) {
+	var config wcconfig.Config
+	config.Telegram.AdminIDs = []int{1001}
+	config.WingCommander.UpdateChannel = channel
+	bot, ft := newTestBot(t, config)
+
+	srv := newFakeGitHub()
+	bot.updater = updater.New("BigOokie", "skywire-wing-commander")
+	if err := bot.updater.SetBaseURL(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	return bot, ft, srv
+}
+
+func Test_CheckForUpdate_NotifiesOnce(t *testing.T) {
+	bot, ft, srv := newTestUpdateBot(t, wcconfig.UpdateChannelStable)
+	defer removeTestState(bot)
+	defer srv.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := bot.checkForUpdate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sent := ft.sent("sendMessage")
+	if len(sent) != 1 {
+		t.Fatalf("Expected: 1 update notification, got %d", len(sent))
+	}
+	msg, _ := url.QueryUnescape(sent[0])
+	if !strings.Contains(msg, "v9.0.0") || !strings.Contains(msg, "Stable release notes") || !strings.Contains(msg, "skipversion v9.0.0") {
+		t.Errorf("Unexpected notification: %s", msg)
+	}
+	if v := bot.state.GetLastNotifiedVersion(); v != "v9.0.0" {
+		t.Errorf("Expected: last notified version v9.0.0, got %s", v)
+	}
+}
+
+func Test_CheckForUpdate_SkipVersion(t *testing.T) {
+	bot, ft, srv := newTestUpdateBot(t, wcconfig.UpdateChannelPrerelease)
+	defer removeTestState(bot)
+	defer srv.Close()
+
+	ctx := newTestCommandCtx(1001, "admin", "/skipversion v9.1.0-rc1")
+	if err := bot.handleMessage(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if v := bot.state.GetSkippedUpdateVersion(); v != "v9.1.0-rc1" {
+		t.Errorf("Expected: skipped version v9.1.0-rc1, got %s", v)
+	}
+
+	before := len(ft.sent("sendMessage"))
+	if err := bot.checkForUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if after := len(ft.sent("sendMessage")); after != before {
+		t.Errorf("Expected: skipped version not to be notified, %d messages sent", after-before)
+	}
+}
+
+func Test_CheckHealth_RedactsAPIKey(t *testing.T) {
+	config := reloadTestConfig()
+	bot, ft := newTestBot(t, config)
+	defer removeTestState(bot)
+	bot.telegram.Token = config.Telegram.APIKey
+
+	// Network errors include the API URL, which contains the API key
+	ft.setFail(func(string) (string, error) { return "", errors.New("network is unreachable") })
+	err := bot.CheckHealth()
+	if err == nil {
+		t.Fatal("Expected: Telegram should not be reachable")
+	}
+	if strings.Contains(err.Error(), config.Telegram.APIKey) {
+		t.Errorf("Expected: the API key to be redacted, got %v", err)
+	}
+
+	// The health check output of a new version is reported to the chat
+	ft.setFail(nil)
+	output := "Health check failed: Post https://api.telegram.org/bot" + config.Telegram.APIKey + "/getMe: timeout"
+	bot.reportRollback(&restartRequest{exe: "wcbot", version: "v9.0.0"}, errors.New("exit status 1"), output, nil)
+	// The request path also contains the API key, so only the message text is checked
+	text := lastReloadMsg(ft)
+	if i := strings.Index(text, "text="); i >= 0 {
+		text = text[i:]
+	}
+	if !strings.Contains(text, "Health check failed") || strings.Contains(text, config.Telegram.APIKey) {
+		t.Errorf("Expected: the health check output without the API key, got %s", text)
+	}
+}
+
+func Test_NewUpdater_AllowUnsigned(t *testing.T) {
+	var config wcconfig.Config
+	u, err := newUpdater(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.AllowUnsigned {
+		t.Error("Expected: unsigned releases to be rejected by default")
+	}
+
+	config.WingCommander.UpdateAllowUnsigned = true
+	if u, err = newUpdater(config); err != nil {
+		t.Fatal(err)
+	}
+	if !u.AllowUnsigned {
+		t.Error("Expected: unsigned releases to be allowed when wingcommander.updateallowunsigned is set")
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package telegrambot
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+	log "github.com/sirupsen/logrus"
+	tgbotapi "gopkg.in/telegram-bot-api.v4"
+)
+
+const (
+	// webhookTokenHeader is the header in which Telegram sends the secret token (webhook.secrettoken)
+	webhookTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+	// webhookMaxBody limits the size of an update received by the webhook
+	webhookMaxBody = 1 << 20
+	// webhookTimeout limits the time taken to read a request and write its response
+	webhookTimeout = 30 * time.Second
+	// webhookShutdownTimeout limits the time given to requests being handled when stopping
+	webhookShutdownTimeout = 5 * time.Second
+)
+
+// webhookPath returns the path on which updates are received, which is the path of
+// the webhook URL followed by the secret path (if any)
+func webhookPath(config wcconfig.WebhookParameters) string {
+	p := "/"
+	if u, err := url.Parse(config.URL); err == nil {
+		p = path.Join("/", u.Path, config.SecretPath)
+	}
+	return p
+}
+
+// webhookURL returns the URL Telegram sends updates to, including the secret path (if any)
+func webhookURL(config wcconfig.WebhookParameters) string {
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return config.URL
+	}
+	u.Path = webhookPath(config)
+	return u.String()
+}
+
+// webhookHandler returns the http.Handler which receives updates from Telegram and sends
+// them to updates. Requests to any other path, or without the secret token, are rejected.
+// An update is only acknowledged once it is accepted, so Telegram sends it again otherwise.
+func (bot *Bot) webhookHandler(ctx context.Context, updates chan<- tgbotapi.Update) http.Handler {
+	config := bot.getConfig().Webhook
+	endpoint := webhookPath(config)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != endpoint {
+			log.Debugf("Bot.webhookHandler: Ignored a request from %s for an unknown path", r.RemoteAddr)
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		token := r.Header.Get(webhookTokenHeader)
+		if config.SecretToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(config.SecretToken)) != 1 {
+			log.Warnf("Bot.webhookHandler: Rejected an update from %s without the secret token", r.RemoteAddr)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		var update tgbotapi.Update
+		if err := json.NewDecoder(io.LimitReader(r.Body, webhookMaxBody)).Decode(&update); err != nil {
+			log.Warnf("Bot.webhookHandler: Rejected an invalid update from %s: %v", r.RemoteAddr, err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case updates <- update:
+			w.WriteHeader(http.StatusOK)
+		case <-ctx.Done():
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		case <-r.Context().Done():
+		}
+	})
+}
+
+// setWebhook registers the webhook URL (and secret token) with Telegram. When the webhook is
+// served using TLS, the certificate is also provided so that it may be self-signed. Like
+// connect, it reports if a failure may be retried.
+func (bot *Bot) setWebhook() (bool, error) {
+	config := bot.getConfig()
+	params := map[string]string{"url": webhookURL(config.Webhook)}
+	if config.Webhook.SecretToken != "" {
+		params["secret_token"] = config.Webhook.SecretToken
+	}
+
+	var err error
+	if config.Webhook.CertFile != "" {
+		_, err = bot.telegram.UploadFile("setWebhook", params, "certificate", config.Webhook.CertFile)
+	} else {
+		values := url.Values{}
+		for k, v := range params {
+			values.Set(k, v)
+		}
+		_, err = bot.telegram.MakeRequest("setWebhook", values)
+	}
+	if err != nil {
+		retry, _ := retryDelay(err)
+		// Errors include the API URL (and the webhook URL), which contain secrets
+		return retry, fmt.Errorf("Failed to register the webhook with Telegram: %s", config.Redact(err.Error()))
+	}
+	return false, nil
+}
+
+// registerWebhook registers the webhook with Telegram (see setWebhook), retrying with backoff
+// until Telegram can be reached or ctx is done
+func (bot *Bot) registerWebhook(ctx context.Context) {
+	failures := 0
+	for ctx.Err() == nil {
+		var err error
+		retry := true
+		if !bot.isConnected() {
+			_, err = bot.connect()
+		}
+		if err == nil {
+			retry, err = bot.setWebhook()
+		}
+		if err == nil {
+			config := bot.getConfig()
+			log.Infof("Bot.registerWebhook: Telegram will send updates to %s", config.Redact(webhookURL(config.Webhook)))
+			if failures > 0 {
+				bot.outbox.retryNow()
+			}
+			return
+		}
+		if !retry {
+			log.Errorf("Bot.registerWebhook: %v. Updates will not be received", err)
+			return
+		}
+
+		failures++
+		wait := backoff(failures)
+		bot.setConnected(false)
+		log.Warnf("Bot.registerWebhook: Telegram can not be reached (retrying in %v): %v", wait, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// serveWebhook receives updates from Telegram using a webhook, rather than long polling, and
+// sends them to updates until ctx is done. It returns an error if the webhook can not be
+// served (i.e. the listen address is in use). The webhook is registered with Telegram in the
+// background, as Telegram may not be reachable yet.
+func (bot *Bot) serveWebhook(ctx context.Context, updates chan<- tgbotapi.Update) error {
+	config := bot.getConfig().Webhook
+	server := &http.Server{
+		Handler:      bot.webhookHandler(ctx, updates),
+		ReadTimeout:  webhookTimeout,
+		WriteTimeout: webhookTimeout,
+	}
+	if config.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return fmt.Errorf("Failed to load the webhook certificate: %v", err)
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	listener, err := net.Listen("tcp", config.ListenAddress)
+	if err != nil {
+		return fmt.Errorf("Failed to listen for Telegram updates: %v", err)
+	}
+	log.Infof("Bot.serveWebhook: Listening for Telegram updates on %s (TLS: %v)", listener.Addr(), server.TLSConfig != nil)
+
+	go func() {
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ServeTLS(listener, "", "")
+		} else {
+			err = server.Serve(listener)
+		}
+		if err != http.ErrServerClosed {
+			log.Errorf("Bot.serveWebhook: Stopped listening for Telegram updates: %v", err)
+		}
+	}()
+	bot.goBackground(func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(sctx); err != nil {
+			log.Warnf("Bot.serveWebhook: Failed to stop listening for Telegram updates: %v", err)
+		}
+	})
+
+	go bot.registerWebhook(ctx)
+	return nil
+}
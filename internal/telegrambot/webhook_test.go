@@ -0,0 +1,140 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package telegrambot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+	tgbotapi "gopkg.in/telegram-bot-api.v4"
+)
+
+// testWebhookUpdate is an update (a /status command) as sent to the webhook by Telegram
+const testWebhookUpdate = `{"update_id":10001,"message":{"message_id":7,"date":1546300800,` +
+	`"from":{"id":123456789,"is_bot":false,"first_name":"Test","username":"TESTUSER"},` +
+	`"chat":{"id":123456789,"type":"private","username":"TESTUSER"},` +
+	`"text":"/status","entities":[{"type":"bot_command","offset":0,"length":7}]}}`
+
+// webhookTestConfig returns a configuration which receives updates using a webhook
+func webhookTestConfig() wcconfig.Config {
+	c := reloadTestConfig()
+	c.Webhook.Enabled = true
+	c.Webhook.URL = "https://example.com/wcbot"
+	c.Webhook.ListenAddress = "127.0.0.1:8443"
+	c.Webhook.SecretPath = "Xk2pQ9"
+	c.Webhook.SecretToken = "d41d8cd98f00b204e9800998ecf8427e"
+	return c
+}
+
+func Test_WebhookHandler(t *testing.T) {
+	bot, _ := newTestBot(t, webhookTestConfig())
+	defer removeTestState(bot)
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+	}{
+		{"Update", http.MethodPost, "/wcbot/Xk2pQ9", "d41d8cd98f00b204e9800998ecf8427e", testWebhookUpdate, http.StatusOK},
+		{"No secret path", http.MethodPost, "/wcbot", "d41d8cd98f00b204e9800998ecf8427e", testWebhookUpdate, http.StatusNotFound},
+		{"No secret token", http.MethodPost, "/wcbot/Xk2pQ9", "", testWebhookUpdate, http.StatusForbidden},
+		{"Wrong secret token", http.MethodPost, "/wcbot/Xk2pQ9", "d41d8cd98f00b204e9800998ecf8427f", testWebhookUpdate, http.StatusForbidden},
+		{"Not POST", http.MethodGet, "/wcbot/Xk2pQ9", "d41d8cd98f00b204e9800998ecf8427e", "", http.StatusMethodNotAllowed},
+		{"Invalid update", http.MethodPost, "/wcbot/Xk2pQ9", "d41d8cd98f00b204e9800998ecf8427e", `{"update_id":`, http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			updates := make(chan tgbotapi.Update, 1)
+			handler := bot.webhookHandler(context.Background(), updates)
+
+			req := httptest.NewRequest(tc.method, "https://example.com"+tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.token != "" {
+				req.Header.Set(webhookTokenHeader, tc.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("Expected: status %d, got %d", tc.status, rec.Code)
+			}
+			if tc.status != http.StatusOK {
+				if len(updates) != 0 {
+					t.Errorf("Expected: the update to be rejected")
+				}
+				return
+			}
+			update := <-updates
+			if update.UpdateID != 10001 || update.Message == nil || update.Message.Command() != "status" {
+				t.Errorf("Unexpected update: %+v", update)
+			}
+			if update.Message.From.UserName != "TESTUSER" || update.Message.Chat.ID != 123456789 {
+				t.Errorf("Unexpected message: %+v", update.Message)
+			}
+		})
+	}
+}
+
+func Test_WebhookHandler_ShuttingDown(t *testing.T) {
+	bot, _ := newTestBot(t, webhookTestConfig())
+	defer removeTestState(bot)
+
+	// Updates which can not be accepted are not acknowledged, so Telegram sends them again
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	handler := bot.webhookHandler(ctx, make(chan tgbotapi.Update))
+
+	req := httptest.NewRequest(http.MethodPost, "/wcbot/Xk2pQ9", strings.NewReader(testWebhookUpdate))
+	req.Header.Set(webhookTokenHeader, "d41d8cd98f00b204e9800998ecf8427e")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected: status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+}
+
+func Test_SetWebhook(t *testing.T) {
+	bot, ft := newTestBot(t, webhookTestConfig())
+	defer removeTestState(bot)
+
+	if _, err := bot.setWebhook(); err != nil {
+		t.Fatal(err)
+	}
+	sent := ft.sent("setWebhook")
+	if len(sent) != 1 {
+		t.Fatalf("Expected: the webhook to be registered, got %v", sent)
+	}
+	params, err := url.ParseQuery(strings.SplitN(sent[0], "?", 2)[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Get("url") != "https://example.com/wcbot/Xk2pQ9" {
+		t.Errorf("Expected: the secret path to be included in the webhook URL, got %q", params.Get("url"))
+	}
+	if params.Get("secret_token") != "d41d8cd98f00b204e9800998ecf8427e" {
+		t.Errorf("Expected: the secret token to be registered, got %q", params.Get("secret_token"))
+	}
+
+	// Errors must not reveal the secret path
+	ft.setFail(func(string) (string, error) {
+		return `{"ok":false,"error_code":400,"description":"Bad Request: bad webhook: https://example.com/wcbot/Xk2pQ9"}`, nil
+	})
+	retry, err := bot.setWebhook()
+	if err == nil || retry {
+		t.Fatalf("Expected: a permanent error, got %v (retry %v)", err, retry)
+	}
+	if strings.Contains(err.Error(), "Xk2pQ9") {
+		t.Errorf("Expected: the secret path to be redacted, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+// Package totp implements Time-based One-Time Passwords (RFC 6238) compatible
+// with common authenticator apps (SHA1, 6 digits, 30 second period).
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" // nolint: gas
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Digits is the number of digits in a generated code
+	Digits = 6
+	// Period is the validity period of a generated code
+	Period = 30 * time.Second
+	// secretSize is the size (in bytes) of generated secrets
+	secretSize = 20
+)
+
+var b32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret generates a new random base32 encoded secret
+func GenerateSecret() (string, error) {
+	buf := make([]byte, secretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return b32NoPadding.EncodeToString(buf), nil
+}
+
+// decodeSecret decodes a base32 secret. Spaces, padding and case are ignored.
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Replace(secret, " ", "", -1))
+	secret = strings.TrimRight(secret, "=")
+	return b32NoPadding.DecodeString(secret)
+}
+
+// ValidateSecret checks that the provided secret is valid base32
+func ValidateSecret(secret string) error {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return err
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("secret is empty")
+	}
+	return nil
+}
+
+// counter returns the time step counter for the provided time
+func counter(t time.Time) uint64 {
+	return uint64(t.Unix()) / uint64(Period/time.Second)
+}
+
+// codeForCounter generates the code for the provided secret and time step counter
+func codeForCounter(key []byte, c uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, c)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg) // nolint: errcheck
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation (RFC 4226 section 5.3)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, value%mod)
+}
+
+// Code generates the code for the provided secret at time t
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return codeForCounter(key, counter(t)), nil
+}
+
+// Validate checks the provided code against the secret at time t, allowing
+// for `skew` periods of clock drift either side. On success the time step
+// counter which matched is returned so callers can prevent code reuse.
+func Validate(secret, code string, t time.Time, skew int) (uint64, bool) {
+	key, err := decodeSecret(secret)
+	if err != nil || len(code) != Digits {
+		return 0, false
+	}
+
+	now := counter(t)
+	for i := -skew; i <= skew; i++ {
+		c := uint64(int64(now) + int64(i))
+		if hmac.Equal([]byte(codeForCounter(key, c)), []byte(code)) {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// ProvisioningURI builds an `otpauth://` URI which can be used (directly or as a QR code)
+// to add the secret to an authenticator app
+func ProvisioningURI(secret, account, issuer string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprintf("%d", Digits))
+	v.Set("period", fmt.Sprintf("%d", int(Period/time.Second)))
+
+	label := url.PathEscape(issuer + ":" + account)
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, v.Encode())
+}
@@ -0,0 +1,93 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 test secret from RFC 6238 Appendix B ("12345678901234567890")
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func Test_Code_RFC6238Vectors(t *testing.T) {
+	// RFC 6238 provides 8 digit codes. We use the last 6 digits.
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, expect := range vectors {
+		code, err := Code(rfcSecret, time.Unix(unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != expect {
+			t.Errorf("Time %d: expected %s, got %s", unix, expect, code)
+		}
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	if _, ok := Validate(rfcSecret, "050471", now, 1); !ok {
+		t.Error("Expected: Current code should be valid")
+	}
+	if _, ok := Validate(rfcSecret, "050471", now.Add(Period), 1); !ok {
+		t.Error("Expected: Code from previous period should be valid with skew")
+	}
+	if _, ok := Validate(rfcSecret, "050471", now.Add(3*Period), 1); ok {
+		t.Error("Expected: Code from 3 periods ago should not be valid")
+	}
+	if _, ok := Validate(rfcSecret, "12345", now, 1); ok {
+		t.Error("Expected: Short code should not be valid")
+	}
+	if _, ok := Validate("not base32!", "050471", now, 1); ok {
+		t.Error("Expected: Invalid secret should not validate")
+	}
+}
+
+func Test_GenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := Code(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Validate(secret, code, time.Now(), 1); !ok {
+		t.Error("Expected: Code generated from a new secret should be valid")
+	}
+}
+
+func Test_ValidateSecret(t *testing.T) {
+	if err := ValidateSecret("jbsw y3dp ehpk 3pxp"); err != nil {
+		t.Error(err)
+	}
+	for _, secret := range []string{"", "not base32!"} {
+		if err := ValidateSecret(secret); err == nil {
+			t.Errorf("Expected: %q to be invalid", secret)
+		}
+	}
+}
+
+func Test_ProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("JBSWY3DPEHPK3PXP", "@USERNAME", "Wing Commander")
+	if !strings.HasPrefix(uri, "otpauth://totp/Wing%20Commander:@USERNAME?") {
+		t.Errorf("Unexpected URI: %s", uri)
+	}
+	if !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") {
+		t.Errorf("Expected secret in URI: %s", uri)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package updater
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// UpgradeCompletedFlag is the command line flag passed to the new binary once it has been installed
+	UpgradeCompletedFlag = "-upgradecompleted"
+	// HealthCheckFlag is the command line flag passed to the new binary to check it
+	// is healthy before the running binary is replaced by it
+	HealthCheckFlag = "-upgradehealthcheck"
+)
+
+// Executable returns the path of the running binary with any symlinks resolved
+func Executable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+// PreviousPath returns the path the previous binary is retained at following an Install
+func PreviousPath(exe string) string {
+	return exe + ".old"
+}
+
+// Install replaces the binary at exe with the provided binary. The replaced binary
+// is retained (see PreviousPath). The new binary is written alongside the existing
+// binary and renamed into place, so the existing binary is left intact if the
+// install fails.
+func Install(exe string, binary []byte) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+
+	newfile := exe + ".new"
+	if err := ioutil.WriteFile(newfile, binary, info.Mode().Perm()|0100); err != nil {
+		return fmt.Errorf("failed to write new binary: %v", err)
+	}
+
+	oldfile := PreviousPath(exe)
+	_ = os.Remove(oldfile)
+	if err := os.Rename(exe, oldfile); err != nil {
+		os.Remove(newfile)
+		return fmt.Errorf("failed to retain the current binary: %v", err)
+	}
+
+	if err := os.Rename(newfile, exe); err != nil {
+		// Put the original binary back
+		if rerr := os.Rename(oldfile, exe); rerr != nil {
+			log.Errorf("Install: Failed to restore %s from %s: %v", exe, oldfile, rerr)
+		}
+		os.Remove(newfile)
+		return fmt.Errorf("failed to install the new binary: %v", err)
+	}
+
+	log.Infof("Install: Installed new binary to %s (previous binary retained as %s)", exe, oldfile)
+	return nil
+}
+
+// Rollback restores the binary retained by Install. The binary being replaced is
+// kept alongside it (with a `.failed` suffix) for investigation.
+func Rollback(exe string) error {
+	oldfile := PreviousPath(exe)
+	if _, err := os.Stat(oldfile); err != nil {
+		return fmt.Errorf("no previous binary to roll back to: %v", err)
+	}
+
+	failedfile := exe + ".failed"
+	_ = os.Remove(failedfile)
+	if err := os.Rename(exe, failedfile); err != nil {
+		return fmt.Errorf("failed to remove the new binary: %v", err)
+	}
+
+	if err := os.Rename(oldfile, exe); err != nil {
+		return fmt.Errorf("failed to restore the previous binary: %v", err)
+	}
+
+	log.Warnf("Rollback: Restored %s from %s (failed binary retained as %s)", exe, oldfile, failedfile)
+	return nil
+}
+
+// HealthCheck runs the binary at exe with HealthCheckFlag (and the current command line
+// arguments). The binary must exit successfully within the provided timeout to be considered
+// healthy. The output of the binary is returned to assist diagnosis.
+func HealthCheck(exe string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, exe, append(OriginalArgs(), HealthCheckFlag)...)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("health check did not complete within %v", timeout)
+	}
+	return string(output), err
+}
+
+// filterArgs returns the provided command line arguments excluding the provided flags
+func filterArgs(args []string, flags ...string) []string {
+	result := []string{}
+	for _, a := range args {
+		exclude := false
+		for _, f := range flags {
+			if a == f || a == "-"+f {
+				exclude = true
+			}
+		}
+		if !exclude {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// OriginalArgs returns the current command line arguments excluding any
+// flags used to manage an upgrade
+func OriginalArgs() []string {
+	return filterArgs(os.Args[1:], UpgradeCompletedFlag, HealthCheckFlag)
+}
+
+// UpgradeCompletedArgs returns the current command line arguments with UpgradeCompletedFlag appended
+func UpgradeCompletedArgs() []string {
+	return append(OriginalArgs(), UpgradeCompletedFlag)
+}
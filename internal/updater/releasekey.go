@@ -0,0 +1,13 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package updater
+
+// releaseSigningKey is the armored PGP public key the maintainers sign the checksums of each
+// release with (see `sign` in .goreleaser.yml). It is loaded by New, so that releases with a
+// missing or invalid signature are rejected unless another key is configured. Until it has
+// been set, no release can be installed unless unsigned releases are explicitly allowed
+// (see Updater.AllowUnsigned).
+var releaseSigningKey = ``
@@ -0,0 +1,24 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+//go:build !windows
+// +build !windows
+
+package updater
+
+import (
+	"os"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Restart replaces the running process with the binary at exe, passing the provided
+// command line arguments. Restart only returns on failure.
+func Restart(exe string, args []string) error {
+	argv := append([]string{exe}, args...)
+	log.Infof("Restart: Executing %v", argv)
+	return syscall.Exec(exe, argv, os.Environ())
+}
@@ -0,0 +1,13 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package updater
+
+import "errors"
+
+// Restart is not supported on Windows
+func Restart(exe string, args []string) error {
+	return errors.New("restart is not supported on windows")
+}
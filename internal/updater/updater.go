@@ -0,0 +1,359 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+// Package updater downloads, verifies and installs Wing Commander release
+// binaries published on GitHub (by goreleaser, see .goreleaser.yml).
+package updater
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
+	"github.com/google/go-github/github"
+	version "github.com/hashicorp/go-version"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp"
+)
+
+const (
+	// projectName is the goreleaser project name used to name release artifacts
+	projectName = "wcbot"
+	// binaryName is the name of the binary within a release archive
+	binaryName = "wcbot"
+	// checksumsName is the name of the release asset listing the SHA256 checksum of each archive
+	checksumsName = projectName + "_checksums.txt"
+	// signatureSuffix is appended to the checksums asset name to identify its (detached) signature
+	signatureSuffix = ".sig"
+	// maxDownloadSize limits the size of a downloaded release asset
+	maxDownloadSize = 100 << 20
+)
+
+// osNames and archNames map GOOS and GOARCH to the names used within release
+// archive names (see `archive.replacements` in .goreleaser.yml)
+var (
+	osNames = map[string]string{
+		"darwin": "macos",
+		"linux":  "linux",
+	}
+	archNames = map[string]string{
+		"386":   "32bit",
+		"amd64": "64bit",
+		"arm":   "arm",
+		"arm64": "arm64",
+	}
+)
+
+// Updater checks for and installs release binaries for the current platform
+type Updater struct {
+	Owner  string
+	Repo   string
+	GOOS   string
+	GOARCH string
+	GOARM  string
+
+	// AllowUnsigned allows releases to be installed without verifying their signature
+	// if no release signing key is available. Only the checksum of the archive is verified.
+	AllowUnsigned bool
+
+	client     *github.Client
+	httpClient *http.Client
+	keyring    openpgp.EntityList
+}
+
+// New creates an Updater for the releases of the provided GitHub repository
+// targeting the platform the application is running on. The embedded release
+// signing key (see releaseSigningKey) is loaded if it has been set.
+func New(owner, repo string) *Updater {
+	httpClient := &http.Client{Timeout: 5 * time.Minute}
+	u := &Updater{
+		Owner:      owner,
+		Repo:       repo,
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		GOARM:      goarm(),
+		client:     github.NewClient(httpClient),
+		httpClient: httpClient,
+	}
+	if releaseSigningKey != "" {
+		keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(releaseSigningKey))
+		if err != nil {
+			log.Errorf("Updater.New: Failed to read the embedded release signing key: %v", err)
+		} else {
+			u.keyring = keyring
+		}
+	}
+	return u
+}
+
+// goarm returns the ARM version the application was built for. Binaries built for
+// ARMv6 also run on ARMv7, so ARMv6 is assumed if the build settings are not available.
+func goarm() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "GOARM" && s.Value != "" {
+				return strings.TrimSuffix(s.Value, ",softfloat")
+			}
+		}
+	}
+	return "6"
+}
+
+// SetBaseURL overrides the GitHub API URL (i.e. for testing)
+func (u *Updater) SetBaseURL(rawurl string) error {
+	if !strings.HasSuffix(rawurl, "/") {
+		rawurl += "/"
+	}
+	baseURL, err := url.Parse(rawurl)
+	if err != nil {
+		return err
+	}
+	u.client.BaseURL = baseURL
+	return nil
+}
+
+// LoadPublicKey loads the (armored) PGP public key used to sign releases from the provided file,
+// replacing the embedded release signing key. Releases with a missing or invalid signature are rejected.
+func (u *Updater) LoadPublicKey(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	keyring, err := openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		return fmt.Errorf("failed to read release signing key %s: %v", filename, err)
+	}
+	u.keyring = keyring
+	return nil
+}
+
+// ArchiveName returns the name of the release archive for the provided version
+// (see `archive.name_template` in .goreleaser.yml)
+func (u *Updater) ArchiveName(tag string) (string, error) {
+	osName, ok := osNames[u.GOOS]
+	if !ok {
+		return "", fmt.Errorf("no releases are published for %s", u.GOOS)
+	}
+	archName, ok := archNames[u.GOARCH]
+	if !ok {
+		return "", fmt.Errorf("no releases are published for %s", u.GOARCH)
+	}
+	if u.GOARCH == "arm" {
+		archName += "v" + u.GOARM
+	}
+	return fmt.Sprintf("%s_%s_%s_%s.tar.gz", projectName, strings.TrimPrefix(tag, "v"), osName, archName), nil
+}
+
+// LatestRelease returns the release with the highest version. Draft releases and releases
+// which are not tagged with a valid version are ignored. Pre-releases are only
+// considered if requested.
+func (u *Updater) LatestRelease(prerelease bool) (*github.RepositoryRelease, error) {
+	releases, _, err := u.client.Repositories.ListReleases(context.Background(), u.Owner, u.Repo, &github.ListOptions{PerPage: 50})
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *github.RepositoryRelease
+	var latestVersion *version.Version
+	for _, r := range releases {
+		if r.GetDraft() || (r.GetPrerelease() && !prerelease) {
+			continue
+		}
+		v, err := version.NewVersion(r.GetTagName())
+		if err != nil {
+			log.Debugf("Updater.LatestRelease: Ignoring release %s: %v", r.GetTagName(), err)
+			continue
+		}
+		if latestVersion == nil || v.GreaterThan(latestVersion) {
+			latest, latestVersion = r, v
+		}
+	}
+
+	if latest == nil {
+		return nil, fmt.Errorf("no releases found for %s/%s", u.Owner, u.Repo)
+	}
+	return latest, nil
+}
+
+// IsNewer determines if the provided release is newer than the provided (current) version
+func IsNewer(release *github.RepositoryRelease, current string) (bool, error) {
+	rv, err := version.NewVersion(release.GetTagName())
+	if err != nil {
+		return false, err
+	}
+	cv, err := version.NewVersion(current)
+	if err != nil {
+		return false, err
+	}
+	return rv.GreaterThan(cv), nil
+}
+
+// findAsset returns the release asset with the provided name
+func findAsset(release *github.RepositoryRelease, name string) (*github.ReleaseAsset, error) {
+	for i := range release.Assets {
+		if release.Assets[i].GetName() == name {
+			return &release.Assets[i], nil
+		}
+	}
+	return nil, fmt.Errorf("release %s has no asset %s", release.GetTagName(), name)
+}
+
+// download retrieves the content of the provided release asset
+func (u *Updater) download(asset *github.ReleaseAsset) ([]byte, error) {
+	log.Debugf("Updater.download: %s", asset.GetBrowserDownloadURL())
+
+	req, err := http.NewRequest(http.MethodGet, asset.GetBrowserDownloadURL(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Wing Commander Telegram Bot "+wcconst.BotVersion)
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download of %s failed: %s", asset.GetName(), resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDownloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDownloadSize {
+		return nil, fmt.Errorf("download of %s exceeds %d bytes", asset.GetName(), maxDownloadSize)
+	}
+	return data, nil
+}
+
+// verifySignature checks the detached (binary or armored) PGP signature of the provided data
+func (u *Updater) verifySignature(data, signature []byte) error {
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(signature), []byte("-----BEGIN")) {
+		_, err = openpgp.CheckArmoredDetachedSignature(u.keyring, bytes.NewReader(data), bytes.NewReader(signature))
+	} else {
+		_, err = openpgp.CheckDetachedSignature(u.keyring, bytes.NewReader(data), bytes.NewReader(signature))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid release signature: %v", err)
+	}
+	return nil
+}
+
+// lookupChecksum finds the SHA256 checksum for the named file within the provided
+// checksums file (formatted as output by `sha256sum`)
+func lookupChecksum(checksums []byte, name string) (string, error) {
+	for _, line := range strings.Split(string(checksums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum published for %s", name)
+}
+
+// extractBinary extracts the application binary from the provided release archive (.tar.gz)
+func extractBinary(archive []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if hdr.Typeflag == tar.TypeReg && path.Base(hdr.Name) == binaryName {
+			return ioutil.ReadAll(io.LimitReader(tr, maxDownloadSize))
+		}
+	}
+	return nil, fmt.Errorf("release archive does not contain %s", binaryName)
+}
+
+// Download retrieves the release archive for the current platform, verifies the signature
+// of the checksums and the checksum of the archive, and returns the application binary it
+// contains. Releases are rejected if no release signing key is available, unless AllowUnsigned is set.
+func (u *Updater) Download(release *github.RepositoryRelease) ([]byte, error) {
+	archiveName, err := u.ArchiveName(release.GetTagName())
+	if err != nil {
+		return nil, err
+	}
+	archiveAsset, err := findAsset(release, archiveName)
+	if err != nil {
+		return nil, err
+	}
+	checksumsAsset, err := findAsset(release, checksumsName)
+	if err != nil {
+		return nil, err
+	}
+
+	checksums, err := u.download(checksumsAsset)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case u.keyring != nil:
+		sigAsset, err := findAsset(release, checksumsName+signatureSuffix)
+		if err != nil {
+			return nil, err
+		}
+		signature, err := u.download(sigAsset)
+		if err != nil {
+			return nil, err
+		}
+		if err := u.verifySignature(checksums, signature); err != nil {
+			return nil, err
+		}
+		log.Infof("Updater.Download: Signature of %s verified", checksumsName)
+	case u.AllowUnsigned:
+		log.Warnf("Updater.Download: No release signing key loaded. Only the checksum of %s will be verified.", archiveName)
+	default:
+		return nil, fmt.Errorf("no release signing key is available to verify the signature of %s", archiveName)
+	}
+
+	expected, err := lookupChecksum(checksums, archiveName)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := u.download(archiveAsset)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(archive)
+	if actual := hex.EncodeToString(sum[:]); actual != expected {
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", archiveName, expected, actual)
+	}
+	log.Infof("Updater.Download: Checksum of %s verified", archiveName)
+
+	return extractBinary(archive)
+}
@@ -0,0 +1,404 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package updater
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+const testBinary = "#!/bin/sh\necho new wcbot\n"
+
+// fakeRelease models the release served by a fakeReleaseServer
+type fakeRelease struct {
+	tag        string
+	prerelease bool
+	draft      bool
+	assets     map[string][]byte
+}
+
+// newFakeReleaseServer serves the provided releases using (a subset of) the GitHub API
+func newFakeReleaseServer(t *testing.T, releases ...fakeRelease) *httptest.Server {
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/BigOokie/skywire-wing-commander/releases", func(w http.ResponseWriter, r *http.Request) {
+		var result []map[string]interface{}
+		for _, rel := range releases {
+			var assets []map[string]interface{}
+			for name := range rel.assets {
+				assets = append(assets, map[string]interface{}{
+					"name":                 name,
+					"browser_download_url": fmt.Sprintf("%s/download/%s/%s", srv.URL, rel.tag, name),
+				})
+			}
+			result = append(result, map[string]interface{}{
+				"tag_name":   rel.tag,
+				"draft":      rel.draft,
+				"prerelease": rel.prerelease,
+				"assets":     assets,
+			})
+		}
+		json.NewEncoder(w).Encode(result)
+	})
+	mux.HandleFunc("/download/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/download/"), "/")
+		for _, rel := range releases {
+			if data, ok := rel.assets[parts[1]]; ok && rel.tag == parts[0] {
+				w.Write(data)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+	srv = httptest.NewServer(mux)
+	return srv
+}
+
+// newTestArchive builds a release archive containing the provided binary
+func newTestArchive(t *testing.T, dir string, binary []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	files := map[string][]byte{
+		dir + "/README.md": []byte("readme"),
+		dir + "/wcbot":     binary,
+	}
+	for name, data := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// newTestRelease builds a release for linux/amd64 signed by the provided entity
+func newTestRelease(t *testing.T, tag string, signer *openpgp.Entity) fakeRelease {
+	archiveName := fmt.Sprintf("wcbot_%s_linux_64bit.tar.gz", strings.TrimPrefix(tag, "v"))
+	archive := newTestArchive(t, strings.TrimSuffix(archiveName, ".tar.gz"), []byte(testBinary))
+	sum := sha256.Sum256(archive)
+	checksums := []byte(fmt.Sprintf("%s  %s\n%s  %s\n", strings.Repeat("0", 64), "wcbot_other.tar.gz", hex.EncodeToString(sum[:]), archiveName))
+
+	var sig bytes.Buffer
+	if err := openpgp.DetachSign(&sig, signer, bytes.NewReader(checksums), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	return fakeRelease{
+		tag: tag,
+		assets: map[string][]byte{
+			archiveName:               archive,
+			"wcbot_checksums.txt":     checksums,
+			"wcbot_checksums.txt.sig": sig.Bytes(),
+		},
+	}
+}
+
+// armoredPublicKey returns the armored public key of the provided entity
+func armoredPublicKey(t *testing.T, e *openpgp.Entity) string {
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	return buf.String()
+}
+
+// writePublicKey writes the armored public key of the provided entity to a file
+func writePublicKey(t *testing.T, dir string, e *openpgp.Entity) string {
+	filename := filepath.Join(dir, "release.asc")
+	if err := ioutil.WriteFile(filename, []byte(armoredPublicKey(t, e)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func newTestUpdater(t *testing.T, srv *httptest.Server) *Updater {
+	u := New("BigOokie", "skywire-wing-commander")
+	u.GOOS, u.GOARCH = "linux", "amd64"
+	if err := u.SetBaseURL(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func Test_ArchiveName(t *testing.T) {
+	testCases := []struct {
+		goos, goarch, goarm string
+		expected            string
+	}{
+		{"linux", "amd64", "", "wcbot_1.2.0_linux_64bit.tar.gz"},
+		{"linux", "386", "", "wcbot_1.2.0_linux_32bit.tar.gz"},
+		{"linux", "arm", "7", "wcbot_1.2.0_linux_armv7.tar.gz"},
+		{"linux", "arm64", "", "wcbot_1.2.0_linux_arm64.tar.gz"},
+		{"darwin", "amd64", "", "wcbot_1.2.0_macos_64bit.tar.gz"},
+	}
+
+	for _, tc := range testCases {
+		u := &Updater{GOOS: tc.goos, GOARCH: tc.goarch, GOARM: tc.goarm}
+		name, err := u.ArchiveName("v1.2.0")
+		if err != nil {
+			t.Error(err)
+		}
+		if diff := deep.Equal(name, tc.expected); diff != nil {
+			t.Error(diff)
+		}
+	}
+
+	u := &Updater{GOOS: "windows", GOARCH: "amd64"}
+	if _, err := u.ArchiveName("v1.2.0"); err == nil {
+		t.Error("Expected: An error for an unsupported platform")
+	}
+}
+
+func Test_LatestRelease(t *testing.T) {
+	srv := newFakeReleaseServer(t,
+		fakeRelease{tag: "v1.1.1"},
+		fakeRelease{tag: "v1.3.0", draft: true},
+		fakeRelease{tag: "v1.2.0-rc1", prerelease: true},
+		fakeRelease{tag: "v1.1.10"},
+		fakeRelease{tag: "not-a-version"},
+	)
+	defer srv.Close()
+	u := newTestUpdater(t, srv)
+
+	rel, err := u.LatestRelease(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.GetTagName() != "v1.1.10" {
+		t.Errorf("Expected: v1.1.10, got %s", rel.GetTagName())
+	}
+
+	rel, err = u.LatestRelease(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.GetTagName() != "v1.2.0-rc1" {
+		t.Errorf("Expected: v1.2.0-rc1, got %s", rel.GetTagName())
+	}
+
+	newer, err := IsNewer(rel, "v1.1.1")
+	if err != nil || !newer {
+		t.Errorf("Expected: %s to be newer than v1.1.1 (%v)", rel.GetTagName(), err)
+	}
+}
+
+func Test_Download(t *testing.T) {
+	dir, err := ioutil.TempDir("", "updater")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	signer, err := openpgp.NewEntity("Wing Commander", "test", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := openpgp.NewEntity("Someone Else", "test", "other@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	good := newTestRelease(t, "v1.2.0", signer)
+	badsig := newTestRelease(t, "v1.2.1", other)
+	badsum := newTestRelease(t, "v1.2.2", signer)
+	badsum.assets["wcbot_1.2.2_linux_64bit.tar.gz"] = newTestArchive(t, "wcbot_1.2.2_linux_64bit", []byte("tampered"))
+
+	srv := newFakeReleaseServer(t, good, badsig, badsum)
+	defer srv.Close()
+	u := newTestUpdater(t, srv)
+	if err := u.LoadPublicKey(writePublicKey(t, dir, signer)); err != nil {
+		t.Fatal(err)
+	}
+
+	releases, _, err := u.client.Repositories.ListReleases(context.Background(), "BigOokie", "skywire-wing-commander", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	binary, err := u.Download(releases[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(binary) != testBinary {
+		t.Errorf("Unexpected binary: %q", binary)
+	}
+
+	if _, err := u.Download(releases[1]); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Errorf("Expected: A signature error, got %v", err)
+	}
+
+	if _, err := u.Download(releases[2]); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected: A checksum error, got %v", err)
+	}
+}
+
+func Test_Download_Unsigned(t *testing.T) {
+	signer, err := openpgp.NewEntity("Wing Commander", "test", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := newTestRelease(t, "v1.2.0", signer)
+	unsigned := newTestRelease(t, "v1.2.1", signer)
+	delete(unsigned.assets, "wcbot_checksums.txt.sig")
+
+	srv := newFakeReleaseServer(t, signed, unsigned)
+	defer srv.Close()
+
+	defer func(key string) { releaseSigningKey = key }(releaseSigningKey)
+
+	// By default, the embedded release signing key is used
+	releaseSigningKey = armoredPublicKey(t, signer)
+	u := newTestUpdater(t, srv)
+	releases, _, err := u.client.Repositories.ListReleases(context.Background(), "BigOokie", "skywire-wing-commander", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Download(releases[0]); err != nil {
+		t.Errorf("Expected: The signed release to be accepted, got %v", err)
+	}
+	if _, err := u.Download(releases[1]); err == nil || !strings.Contains(err.Error(), "wcbot_checksums.txt.sig") {
+		t.Errorf("Expected: The unsigned release to be rejected, got %v", err)
+	}
+
+	// Without a release signing key, releases are rejected unless unsigned releases are allowed
+	releaseSigningKey = ""
+	u = newTestUpdater(t, srv)
+	for _, rel := range releases {
+		if _, err := u.Download(rel); err == nil || !strings.Contains(err.Error(), "no release signing key") {
+			t.Errorf("Expected: %s to be rejected, got %v", rel.GetTagName(), err)
+		}
+	}
+	u.AllowUnsigned = true
+	if _, err := u.Download(releases[1]); err != nil {
+		t.Errorf("Expected: The unsigned release to be accepted, got %v", err)
+	}
+}
+
+func Test_Install(t *testing.T) {
+	dir, err := ioutil.TempDir("", "updater")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	exe := filepath.Join(dir, "wcbot")
+	if err := ioutil.WriteFile(exe, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Install(exe, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(exe)
+	if string(data) != "new" {
+		t.Errorf("Expected: new binary to be installed, got %q", data)
+	}
+	data, _ = ioutil.ReadFile(PreviousPath(exe))
+	if string(data) != "old" {
+		t.Errorf("Expected: previous binary to be retained, got %q", data)
+	}
+	if info, err := os.Stat(exe); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("Expected: new binary to be executable (%v)", err)
+	}
+}
+
+func Test_FilterArgs(t *testing.T) {
+	args := []string{"-config", UpgradeCompletedFlag, "-" + HealthCheckFlag, "-v"}
+	expected := []string{"-config", "-v"}
+	if diff := deep.Equal(filterArgs(args, UpgradeCompletedFlag, HealthCheckFlag), expected); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func Test_Rollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "updater")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	exe := filepath.Join(dir, "wcbot")
+	if err := Rollback(exe); err == nil {
+		t.Error("Expected: An error when there is no previous binary")
+	}
+
+	if err := ioutil.WriteFile(exe, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Install(exe, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Rollback(exe); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(exe)
+	if string(data) != "old" {
+		t.Errorf("Expected: previous binary to be restored, got %q", data)
+	}
+	data, _ = ioutil.ReadFile(exe + ".failed")
+	if string(data) != "new" {
+		t.Errorf("Expected: failed binary to be retained, got %q", data)
+	}
+}
+
+func Test_HealthCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "updater")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	scripts := map[string]string{
+		"healthy":   "#!/bin/sh\necho healthy\nexit 0\n",
+		"unhealthy": "#!/bin/sh\necho manager unreachable\nexit 1\n",
+		"hung":      "#!/bin/sh\nexec sleep 10\n",
+	}
+	for name, script := range scripts {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if output, err := HealthCheck(filepath.Join(dir, "healthy"), 5*time.Second); err != nil || !strings.Contains(output, "healthy") {
+		t.Errorf("Expected: healthy binary to pass (%v): %s", err, output)
+	}
+	if output, err := HealthCheck(filepath.Join(dir, "unhealthy"), 5*time.Second); err == nil || !strings.Contains(output, "manager unreachable") {
+		t.Errorf("Expected: unhealthy binary to fail (%v): %s", err, output)
+	}
+	if _, err := HealthCheck(filepath.Join(dir, "hung"), 100*time.Millisecond); err == nil || !strings.Contains(err.Error(), "did not complete") {
+		t.Errorf("Expected: hung binary to time out, got %v", err)
+	}
+}
@@ -8,8 +8,6 @@ package utils
 import (
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"runtime"
 
 	"github.com/BigOokie/skywire-wing-commander/internal/wcconst"
@@ -71,33 +69,6 @@ func UpdateAvailable(ownername, reponame, versiontag string) (result bool, updat
 	return
 }
 
-// nolint
-// DoUpgrade attempts to perform an upgrade by calling a local shell script
-func DoUpgrade() bool {
-	var cmd *exec.Cmd
-	var gopath = os.Getenv("GOPATH")
-	osName := runtime.GOOS
-	if osName == "windows" {
-		log.Error("Upgrade not supported on Windows at this time.")
-		return false
-	}
-
-	scriptPath := filepath.Join(gopath, fmt.Sprintf("%s%s", wcconst.ScriptPath, "wc-update.sh"))
-	log.Debugf("DoUpgrade - Script Path: %s", scriptPath)
-
-	cmd = exec.Command("/bin/bash", scriptPath)
-	log.Debug("DoUpgrade: Executing upgrade shell script.")
-	err := cmd.Start()
-	log.Debug("DoUpgrade: Exiting application...")
-	os.Exit(1)
-
-	if err != nil {
-		log.Error(err)
-		return false
-	}
-	return true
-}
-
 // InitAppInstance will attempt to initialise an instance of the application based on the provided value of appID.
 // A FATAL error will occur causing the application to exit if another instance
 // of the application is detected as already running.
@@ -0,0 +1,146 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package wcaudit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes recorded against an audit Entry
+const (
+	OutcomeSuccess      = "success"
+	OutcomeFailed       = "failed"
+	OutcomePending      = "pending"
+	OutcomeCancelled    = "cancelled"
+	OutcomeDenied       = "denied"
+	OutcomeUnauthorized = "unauthorized"
+	OutcomeUnknown      = "unknown"
+)
+
+// Sources of an audited command
+const (
+	SourceMessage  = "message"
+	SourceCallback = "callback"
+)
+
+// Entry models a single line of the audit log
+type Entry struct {
+	Time     time.Time `json:"time"`
+	UserID   int       `json:"userid"`
+	UserName string    `json:"username,omitempty"`
+	ChatID   int64     `json:"chatid"`
+	Source   string    `json:"source"`
+	Command  string    `json:"command"`
+	Args     string    `json:"args,omitempty"`
+	Outcome  string    `json:"outcome"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// String returns a single line, human readable representation of the Entry
+func (e Entry) String() string {
+	user := e.UserName
+	if user == "" {
+		user = fmt.Sprintf("%d", e.UserID)
+	} else {
+		user = fmt.Sprintf("@%s (%d)", user, e.UserID)
+	}
+
+	s := fmt.Sprintf("%s %s /%s", e.Time.Local().Format("2006-01-02 15:04:05"), user, strings.TrimSpace(e.Command+" "+e.Args))
+	s += " → " + e.Outcome
+	if e.Error != "" {
+		s += ": " + e.Error
+	}
+	return s
+}
+
+// Log is an append-only audit log. Each Entry is written as a single line of JSON.
+type Log struct {
+	path string
+	m    sync.Mutex
+}
+
+// NewLog creates a Log which will append to the provided path.
+// The file is created on the first call to Record.
+func NewLog(path string) *Log {
+	return &Log{path: path}
+}
+
+// Path returns the path of the audit log file
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Record appends the provided Entry to the audit log. If the Entry has no
+// time set, the current time is used.
+func (l *Log) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Recent returns (up to) the last n entries recorded in the audit log, oldest first.
+// A missing audit log is not an error. Lines which cannot be parsed are skipped.
+func (l *Log) Recent(n int) ([]Entry, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+		if len(entries) > n {
+			entries = entries[1:]
+		}
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,107 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package wcaudit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func Test_Log_RecordAndRecent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wcaudit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	auditlog := NewLog(filepath.Join(dir, "audit.log"))
+
+	// A missing audit log has no entries
+	entries, err := auditlog.Recent(10)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected: No entries, got %d", len(entries))
+	}
+
+	ts := time.Date(2018, 7, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		err := auditlog.Record(Entry{
+			Time:    ts.Add(time.Duration(i) * time.Minute),
+			UserID:  1001,
+			Source:  SourceMessage,
+			Command: "status",
+			Args:    fmt.Sprintf("%d", i),
+			Outcome: OutcomeSuccess,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err = auditlog.Recent(2)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := []Entry{
+		{Time: ts.Add(3 * time.Minute), UserID: 1001, Source: SourceMessage, Command: "status", Args: "3", Outcome: OutcomeSuccess},
+		{Time: ts.Add(4 * time.Minute), UserID: 1001, Source: SourceMessage, Command: "status", Args: "4", Outcome: OutcomeSuccess},
+	}
+	if diff := deep.Equal(entries, expected); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func Test_Log_Recent_SkipsCorruptLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wcaudit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	data := `{"time":"2018-07-01T10:00:00Z","userid":1,"chatid":1,"source":"message","command":"stop","outcome":"success"}
+this is not json
+{"time":"2018-07-01T10:01:00Z","userid":2,"chatid":1,"source":"callback","command":"update","outcome":"denied","error":"command is restricted to admins"}
+`
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := NewLog(path).Recent(10)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected: 2 entries, got %d", len(entries))
+	}
+	if entries[1].Command != "update" || entries[1].Outcome != OutcomeDenied {
+		t.Errorf("Unexpected entry: %+v", entries[1])
+	}
+}
+
+func Test_Entry_String(t *testing.T) {
+	e := Entry{
+		Time:     time.Now(),
+		UserID:   1001,
+		UserName: "testuser",
+		Command:  "restartnode",
+		Args:     "abcd",
+		Outcome:  OutcomeFailed,
+		Error:    "node abcd is not connected to the manager",
+	}
+	s := e.String()
+	if !strings.Contains(s, "@testuser (1001) /restartnode abcd → failed: node abcd") {
+		t.Errorf("Unexpected string: %s", s)
+	}
+}
@@ -7,12 +7,17 @@ package wcconfig
 
 import (
 	"fmt"
+	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"crypto/sha256"
 
+	"github.com/BigOokie/skywire-wing-commander/internal/wclog"
+	"github.com/fsnotify/fsnotify"
 	log "github.com/sirupsen/logrus"
 	viper "github.com/spf13/viper"
 )
@@ -24,16 +29,46 @@ type Config struct {
 	Telegram      TelegramParameters      `mapstructure:"telegram"`
 	Monitor       MonitorParameters       `mapstructure:"monitor"`
 	SkyManager    SkyManagerParameters    `mapstructure:"skymanager"`
+	Host          HostParameters          `mapstructure:"host"`
+	Probe         ProbeParameters         `mapstructure:"probe"`
+	Log           LogParameters           `mapstructure:"log"`
+	Webhook       WebhookParameters       `mapstructure:"webhook"`
 }
 
 // WingCommanderParameters struct defines the configuration parameters that
 // are used to manage runtime config for the Wing Commander application
 type WingCommanderParameters struct {
-	TwoFactorEnabled bool `mapstructure:"twofactorenabled"`
-	AnalyticsEnabled bool `mapstructure:"analyticsenabled"`
+	TwoFactorEnabled       bool          `mapstructure:"twofactorenabled"`
+	TwoFactorMode          string        `mapstructure:"twofactormode"`
+	TwoFactorSecret        string        `mapstructure:"twofactorsecret"`
+	TwoFactorExpirySec     time.Duration `mapstructure:"twofactorexpirysec"`
+	AnalyticsEnabled       bool          `mapstructure:"analyticsenabled"`
+	AnalyticsURL           string        `mapstructure:"analyticsurl"`
+	UpdatePublicKey        string        `mapstructure:"updatepublickey"`
+	UpdateAllowUnsigned    bool          `mapstructure:"updateallowunsigned"`
+	UpdateHealthTimeoutSec time.Duration `mapstructure:"updatehealthtimeoutsec"`
+	UpdateCheckIntMin      time.Duration `mapstructure:"updatecheckintmin"`
+	UpdateChannel          string        `mapstructure:"updatechannel"`
 }
 
+// Supported two factor confirmation modes (`wingcommander.twofactormode`)
+const (
+	// TwoFactorModeTOTP requires a TOTP code from an authenticator app
+	TwoFactorModeTOTP = "totp"
+	// TwoFactorModeConfirm requires the command to be confirmed using an inline button
+	TwoFactorModeConfirm = "confirm"
+)
+
+// Supported update channels (`wingcommander.updatechannel`)
+const (
+	// UpdateChannelStable only considers releases which are not marked as pre-releases
+	UpdateChannelStable = "stable"
+	// UpdateChannelPrerelease also considers releases marked as pre-releases
+	UpdateChannelPrerelease = "prerelease"
+)
+
 // WingCommanderAnalytics struct defines the parameters that are used if Analytics is enabled
+// (see `wingcommander.analyticsenabled`)
 type WingCommanderAnalytics struct {
 	ClientUUID string `mapstructure:"clientuuid"`
 	UserID     string `mapstructure:"userid"`
@@ -42,10 +77,13 @@ type WingCommanderAnalytics struct {
 // TelegramParameters struct defines the configuration parameters that
 // are used to manage Wing Commander application integrationw it Telegram
 type TelegramParameters struct {
-	APIKey string `mapstructure:"apikey"`
-	ChatID int64  `mapstructure:"chatid"`
-	Admin  string `mapstructure:"admin"`
-	Debug  bool   `mapstructure:"debug"`
+	APIKey     string `mapstructure:"apikey"`
+	APIKeyFile string `mapstructure:"apikeyfile"`
+	ChatID     int64  `mapstructure:"chatid"`
+	Admin      string `mapstructure:"admin"`
+	AdminIDs   []int  `mapstructure:"adminids"`
+	Members    []int  `mapstructure:"members"`
+	Debug      bool   `mapstructure:"debug"`
 }
 
 // SkyManagerParameters struct defines the configuration parameters that
@@ -53,6 +91,8 @@ type TelegramParameters struct {
 type SkyManagerParameters struct {
 	Address          string `mapstructure:"address"`
 	DiscoveryAddress string `mapstructure:"discoveryaddress"`
+	SkywireRepo      string `mapstructure:"skywirerepo"`
+	SkywireVersion   string `mapstructure:"skywireversion"`
 }
 
 // MonitorParameters struct defines the configuration parameters that
@@ -61,34 +101,143 @@ type MonitorParameters struct {
 	IntervalSec            time.Duration `mapstructure:"intervalsec"`
 	HeartbeatIntMin        time.Duration `mapstructure:"heartbeatintmin"`
 	DiscoveryMonitorIntMin time.Duration `mapstructure:"discoverymonitorintmin"`
+	VersionCheckIntMin     time.Duration `mapstructure:"versioncheckintmin"`
+}
+
+// HostParameters struct defines the configuration parameters that are used
+// to monitor the resources of the host Wing Commander is running on
+type HostParameters struct {
+	CheckIntMin    time.Duration `mapstructure:"checkintmin"`
+	DiskPath       string        `mapstructure:"diskpath"`
+	MaxLoad        float64       `mapstructure:"maxload"`
+	MaxMemPct      float64       `mapstructure:"maxmempct"`
+	MinDiskFreePct float64       `mapstructure:"mindiskfreepct"`
+	MaxTempC       float64       `mapstructure:"maxtempc"`
+}
+
+// ProbeParameters struct defines the configuration parameters that are used
+// to probe the reachability of Nodes on the LAN
+type ProbeParameters struct {
+	IntervalSec time.Duration `mapstructure:"intervalsec"`
+	TimeoutSec  time.Duration `mapstructure:"timeoutsec"`
+	Targets     []string      `mapstructure:"targets"`
+}
+
+// LogParameters struct defines the configuration parameters that are used
+// to configure the application log
+type LogParameters struct {
+	Level        string        `mapstructure:"level"`
+	Format       string        `mapstructure:"format"`
+	File         string        `mapstructure:"file"`
+	MaxSizeMB    int64         `mapstructure:"maxsizemb"`
+	RotateIntMin time.Duration `mapstructure:"rotateintmin"`
+	MaxBackups   int64         `mapstructure:"maxbackups"`
+	RetentionMin time.Duration `mapstructure:"retentionmin"`
+}
+
+// WebhookParameters struct defines the configuration parameters that are used
+// to receive updates from Telegram using a webhook, rather than long polling
+type WebhookParameters struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	URL           string `mapstructure:"url"`
+	ListenAddress string `mapstructure:"listenaddress"`
+	CertFile      string `mapstructure:"certfile"`
+	KeyFile       string `mapstructure:"keyfile"`
+	SecretPath    string `mapstructure:"secretpath"`
+	SecretToken   string `mapstructure:"secrettoken"`
+}
+
+// LogOptions returns the options used to configure the application log (see wclog.Configure)
+func (c *Config) LogOptions() wclog.Options {
+	return wclog.Options{
+		Level:          c.Log.Level,
+		Format:         c.Log.Format,
+		File:           c.Log.File,
+		MaxSize:        c.Log.MaxSizeMB * 1024 * 1024,
+		RotateInterval: c.Log.RotateIntMin,
+		MaxBackups:     int(c.Log.MaxBackups),
+		MaxAge:         c.Log.RetentionMin,
+	}
 }
 
 // String is the stringer function for the Config struct
 func (c *Config) String() string {
 	resultstr := "[WingCommander]\n" +
 		"  twofactorenabled = %v\n" +
+		"  twofactormode = %q\n" +
+		"  twofactorsecret = %q\n" +
+		"  twofactorexpirysec = %v\n" +
 		"  analyticsenabled = %v\n" +
+		"  analyticsurl = %q\n" +
+		"  updatepublickey = %q\n" +
+		"  updateallowunsigned = %v\n" +
+		"  updatehealthtimeoutsec = %v\n" +
+		"  updatecheckintmin = %v\n" +
+		"  updatechannel = %q\n" +
 		"[AppAnalytics]\n" +
 		"  clientuuid = %s\n" +
 		"  userid = %s\n" +
 		"[SkyManager]\n" +
 		"  address = %q\n" +
 		"  discoveryaddress = %q\n" +
+		"  skywirerepo = %q\n" +
+		"  skywireversion = %q\n" +
 		"[Telegram]\n" +
 		"  apikey = %q\n" +
+		"  apikeyfile = %q\n" +
 		"  chatid = %v\n" +
 		"  admin  = %q\n" +
+		"  adminids = %v\n" +
+		"  members = %v\n" +
 		"  debug  = %v\n" +
 		"[Monitor]\n" +
 		"  intervalsec = %v\n" +
 		"  heartbeatintmin = %v\n" +
-		"  discoverymonitorintmin = %v\n"
+		"  discoverymonitorintmin = %v\n" +
+		"  versioncheckintmin = %v\n" +
+		"[Host]\n" +
+		"  checkintmin = %v\n" +
+		"  diskpath = %q\n" +
+		"  maxload = %v\n" +
+		"  maxmempct = %v\n" +
+		"  mindiskfreepct = %v\n" +
+		"  maxtempc = %v\n" +
+		"[Probe]\n" +
+		"  intervalsec = %v\n" +
+		"  timeoutsec = %v\n" +
+		"  targets = %q\n" +
+		"[Log]\n" +
+		"  level = %q\n" +
+		"  format = %q\n" +
+		"  file = %q\n" +
+		"  maxsizemb = %v\n" +
+		"  rotateintmin = %v\n" +
+		"  maxbackups = %v\n" +
+		"  retentionmin = %v\n" +
+		"[Webhook]\n" +
+		"  enabled = %v\n" +
+		"  url = %q\n" +
+		"  listenaddress = %q\n" +
+		"  certfile = %q\n" +
+		"  keyfile = %q\n" +
+		"  secretpath = %q\n" +
+		"  secrettoken = %q\n"
 
-	return fmt.Sprintf(resultstr, c.WingCommander.TwoFactorEnabled, c.WingCommander.AnalyticsEnabled,
+	// Never render secrets (see IsSecret)
+	return fmt.Sprintf(resultstr, c.WingCommander.TwoFactorEnabled, c.WingCommander.TwoFactorMode,
+		redact(c.WingCommander.TwoFactorSecret), c.WingCommander.TwoFactorExpirySec, c.WingCommander.AnalyticsEnabled,
+		c.WingCommander.AnalyticsURL, c.WingCommander.UpdatePublicKey, c.WingCommander.UpdateAllowUnsigned,
+		c.WingCommander.UpdateHealthTimeoutSec, c.WingCommander.UpdateCheckIntMin, c.WingCommander.UpdateChannel,
 		c.AppAnalytics.ClientUUID, c.AppAnalytics.UserID,
-		c.SkyManager.Address, c.SkyManager.DiscoveryAddress,
-		c.Telegram.APIKey, c.Telegram.ChatID, c.Telegram.Admin, c.Telegram.Debug,
-		c.Monitor.IntervalSec, c.Monitor.HeartbeatIntMin, c.Monitor.DiscoveryMonitorIntMin)
+		c.SkyManager.Address, c.SkyManager.DiscoveryAddress, c.SkyManager.SkywireRepo, c.SkyManager.SkywireVersion,
+		redact(c.Telegram.APIKey), c.Telegram.APIKeyFile, c.Telegram.ChatID, c.Telegram.Admin, c.Telegram.AdminIDs, c.Telegram.Members, c.Telegram.Debug,
+		c.Monitor.IntervalSec, c.Monitor.HeartbeatIntMin, c.Monitor.DiscoveryMonitorIntMin,
+		c.Monitor.VersionCheckIntMin,
+		c.Host.CheckIntMin, c.Host.DiskPath, c.Host.MaxLoad, c.Host.MaxMemPct, c.Host.MinDiskFreePct, c.Host.MaxTempC,
+		c.Probe.IntervalSec, c.Probe.TimeoutSec, c.Probe.Targets,
+		c.Log.Level, c.Log.Format, c.Log.File, c.Log.MaxSizeMB, c.Log.RotateIntMin, c.Log.MaxBackups, c.Log.RetentionMin,
+		c.Webhook.Enabled, c.Webhook.URL, c.Webhook.ListenAddress, c.Webhook.CertFile, c.Webhook.KeyFile,
+		redact(c.Webhook.SecretPath), redact(c.Webhook.SecretToken))
 }
 
 // PrintConfig will log debug information for the passed Config structure
@@ -99,7 +248,7 @@ func (c *Config) PrintConfig() {
 // IsEmpty will compare the current instance of Config against an empty instance
 // and return the result of the comparison
 func IsEmpty(c Config) bool {
-	return (c == (Config{}))
+	return reflect.DeepEqual(c, Config{})
 }
 
 // readConfig attempts to read configuration parameters from the provided
@@ -112,7 +261,19 @@ func readConfig(filename, pathname string, defaults map[string]interface{}) (*vi
 	}
 	v.SetConfigName(filename)
 	v.AddConfigPath(pathname)
-	v.AutomaticEnv()
+	err := v.ReadInConfig()
+	return v, err
+}
+
+// readConfigFile creates a viper instance with the provided defaults, and reads
+// the TOML configuration file at path into it
+func readConfigFile(path string, defaults map[string]interface{}) (*viper.Viper, error) {
+	v := viper.New()
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+	v.SetConfigFile(path)
+	v.SetConfigType("toml")
 	err := v.ReadInConfig()
 	return v, err
 }
@@ -124,13 +285,91 @@ func readConfig(filename, pathname string, defaults map[string]interface{}) (*vi
 // An `error` will be returned if any errors occur.
 // A valid `Config` struct will be returned on success.
 func LoadConfigParameters(filename, pathname string, defaults map[string]interface{}) (config Config, err error) {
-	v1, err := readConfig(filename, pathname, defaults)
+	return loadConfig(func() (*viper.Viper, error) {
+		return readConfig(filename, pathname, defaults)
+	}, "")
+}
 
+// LoadConfigFile loads the applications configuration from the configuration file at path
+// (which may have any extension, but must be TOML), with the provided defaults. When profile
+// is not empty, the parameters of the named profile (see applyProfile) take precedence over
+// the rest of the file.
+func LoadConfigFile(path, profile string, defaults map[string]interface{}) (config Config, err error) {
+	return loadConfig(func() (*viper.Viper, error) {
+		return readConfigFile(path, defaults)
+	}, profile)
+}
+
+// loadConfig reads the configuration using read and resolves the selected profile,
+// secrets and overrides (in that order of precedence, lowest first)
+func loadConfig(read func() (*viper.Viper, error), profile string) (config Config, err error) {
+	v, err := read()
 	if err != nil {
 		return config, err
 	}
 
-	if err := v1.Unmarshal(&config); err != nil {
+	if err := applyProfile(v, profile); err != nil {
+		return config, err
+	}
+
+	if err := resolveSecrets(v, filepath.Dir(v.ConfigFileUsed()), profile); err != nil {
+		return config, err
+	}
+
+	return parseConfig(v)
+}
+
+// reloadDelay allows a configuration file which is being saved to be completely
+// written before it is reloaded (editors often write a file in several steps)
+var reloadDelay = time.Second
+
+// WatchConfigParameters watches the configuration file (see LoadConfigParameters) for changes.
+// When the file changes the configuration is reloaded and provided to onChange, along with any
+// error loading it.
+func WatchConfigParameters(filename, pathname string, defaults map[string]interface{}, onChange func(Config, error)) error {
+	return watchConfig(func() (*viper.Viper, error) {
+		return readConfig(filename, pathname, defaults)
+	}, "", onChange)
+}
+
+// WatchConfigFile watches the configuration file at path (see LoadConfigFile) for changes.
+// When the file changes the configuration is reloaded (using the same profile) and provided
+// to onChange, along with any error loading it.
+func WatchConfigFile(path, profile string, defaults map[string]interface{}, onChange func(Config, error)) error {
+	return watchConfig(func() (*viper.Viper, error) {
+		return readConfigFile(path, defaults)
+	}, profile, onChange)
+}
+
+// watchConfig watches the configuration file read by read for changes
+func watchConfig(read func() (*viper.Viper, error), profile string, onChange func(Config, error)) error {
+	v, err := read()
+	if err != nil {
+		return err
+	}
+
+	var m sync.Mutex
+	var timer *time.Timer
+	v.OnConfigChange(func(e fsnotify.Event) {
+		log.Debugf("WatchConfigParameters: %s", e)
+		m.Lock()
+		defer m.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+		// A new viper instance is used to reload the file, so any errors are reported
+		timer = time.AfterFunc(reloadDelay, func() {
+			onChange(loadConfig(read, profile))
+		})
+	})
+	v.WatchConfig()
+	log.Infof("WatchConfigParameters: Watching %s for changes", v.ConfigFileUsed())
+	return nil
+}
+
+// parseConfig unmarshals the configuration read by viper and adjusts its parameters
+func parseConfig(v *viper.Viper) (config Config, err error) {
+	if err := v.Unmarshal(&config); err != nil {
 		return config, err
 	}
 
@@ -138,6 +377,25 @@ func LoadConfigParameters(filename, pathname string, defaults map[string]interfa
 	config.Monitor.IntervalSec = config.Monitor.IntervalSec * time.Second
 	config.Monitor.HeartbeatIntMin = config.Monitor.HeartbeatIntMin * time.Minute
 	config.Monitor.DiscoveryMonitorIntMin = config.Monitor.DiscoveryMonitorIntMin * time.Minute
+	config.Monitor.VersionCheckIntMin = config.Monitor.VersionCheckIntMin * time.Minute
+	config.Host.CheckIntMin = config.Host.CheckIntMin * time.Minute
+	config.Probe.IntervalSec = config.Probe.IntervalSec * time.Second
+	config.Probe.TimeoutSec = config.Probe.TimeoutSec * time.Second
+	config.WingCommander.TwoFactorExpirySec = config.WingCommander.TwoFactorExpirySec * time.Second
+	config.WingCommander.UpdateHealthTimeoutSec = config.WingCommander.UpdateHealthTimeoutSec * time.Second
+	config.WingCommander.UpdateCheckIntMin = config.WingCommander.UpdateCheckIntMin * time.Minute
+	config.Log.RotateIntMin = config.Log.RotateIntMin * time.Minute
+	config.Log.RetentionMin = config.Log.RetentionMin * time.Minute
+
+	// Environment variable and command line overrides take precedence over the file
+	if err := config.applyOverrides(); err != nil {
+		return config, err
+	}
+
+	config.WingCommander.TwoFactorMode = strings.ToLower(config.WingCommander.TwoFactorMode)
+	config.WingCommander.UpdateChannel = strings.ToLower(config.WingCommander.UpdateChannel)
+	config.Log.Level = strings.ToLower(config.Log.Level)
+	config.Log.Format = strings.ToLower(config.Log.Format)
 
 	// Check if the Admin user is prefixed with `@`
 	if !strings.HasPrefix(config.Telegram.Admin, "@") {
@@ -146,9 +404,10 @@ func LoadConfigParameters(filename, pathname string, defaults map[string]interfa
 		log.Warnf("ReadConfig: admin username configuration is not prefixed `@`. Runtime config updated to prevent errors.")
 	}
 
-	// Setup a unique analytics user id and anonymise it by hashing it
+	// Setup a unique analytics user id and anonymise it by hashing it.
+	// Secrets (such as the API key) must not be used, as the id is sent to the analytics URL.
 	if config.AppAnalytics.UserID == "" {
-		sum := sha256.Sum256([]byte(config.Telegram.APIKey + "::" + config.Telegram.Admin + "::" + runtime.GOOS))
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%d::%s::%s", config.Telegram.ChatID, config.Telegram.Admin, runtime.GOOS)))
 		config.AppAnalytics.UserID = fmt.Sprintf("%x", sum)
 	}
 
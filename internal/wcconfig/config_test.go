@@ -15,34 +15,103 @@ func Test_ConfigString(t *testing.T) {
 
 	expectstr := "[WingCommander]\n" +
 		"  twofactorenabled = false\n" +
+		"  twofactormode = \"totp\"\n" +
+		"  twofactorsecret = \"********\"\n" +
+		"  twofactorexpirysec = 1m0s\n" +
 		"  analyticsenabled = false\n" +
+		"  analyticsurl = \"\"\n" +
+		"  updatepublickey = \"\"\n" +
+		"  updateallowunsigned = false\n" +
+		"  updatehealthtimeoutsec = 0s\n" +
+		"  updatecheckintmin = 0s\n" +
+		"  updatechannel = \"\"\n" +
 		"[AppAnalytics]\n" +
 		"  clientuuid = \n" +
 		"  userid = \n" +
 		"[SkyManager]\n" +
 		"  address = \"127.0.0.1:8000\"\n" +
 		"  discoveryaddress = \"testnet.skywire.skycoin.com:8001\"\n" +
+		"  skywirerepo = \"skycoin/skywire\"\n" +
+		"  skywireversion = \"\"\n" +
 		"[Telegram]\n" +
-		"  apikey = \"ABC123\"\n" +
+		"  apikey = \"********\"\n" +
+		"  apikeyfile = \"\"\n" +
 		"  chatid = 123456789\n" +
 		"  admin  = \"@TESTUSER\"\n" +
+		"  adminids = [123456789]\n" +
+		"  members = [987654321]\n" +
 		"  debug  = false\n" +
 		"[Monitor]\n" +
 		"  intervalsec = 10s\n" +
 		"  heartbeatintmin = 2h0m0s\n" +
-		"  discoverymonitorintmin = 2h0m0s\n"
+		"  discoverymonitorintmin = 2h0m0s\n" +
+		"  versioncheckintmin = 12h0m0s\n" +
+		"[Host]\n" +
+		"  checkintmin = 5m0s\n" +
+		"  diskpath = \"/\"\n" +
+		"  maxload = 2\n" +
+		"  maxmempct = 90\n" +
+		"  mindiskfreepct = 10\n" +
+		"  maxtempc = 75.5\n" +
+		"[Probe]\n" +
+		"  intervalsec = 1m0s\n" +
+		"  timeoutsec = 2s\n" +
+		"  targets = [\"192.168.0.2:8000\" \"192.168.0.3\"]\n" +
+		"[Log]\n" +
+		"  level = \"info\"\n" +
+		"  format = \"json\"\n" +
+		"  file = \"/var/log/wcbot.log\"\n" +
+		"  maxsizemb = 10\n" +
+		"  rotateintmin = 24h0m0s\n" +
+		"  maxbackups = 7\n" +
+		"  retentionmin = 168h0m0s\n" +
+		"[Webhook]\n" +
+		"  enabled = true\n" +
+		"  url = \"https://example.com/wcbot\"\n" +
+		"  listenaddress = \"127.0.0.1:8443\"\n" +
+		"  certfile = \"\"\n" +
+		"  keyfile = \"\"\n" +
+		"  secretpath = \"********\"\n" +
+		"  secrettoken = \"\"\n"
 
 	var config Config
 	config.WingCommander.TwoFactorEnabled = false
+	config.WingCommander.TwoFactorMode = TwoFactorModeTOTP
+	config.WingCommander.TwoFactorSecret = "JBSWY3DPEHPK3PXP"
+	config.WingCommander.TwoFactorExpirySec = 60 * time.Second
 	config.SkyManager.Address = "127.0.0.1:8000"
 	config.SkyManager.DiscoveryAddress = "testnet.skywire.skycoin.com:8001"
+	config.SkyManager.SkywireRepo = "skycoin/skywire"
 	config.Telegram.APIKey = "ABC123"
 	config.Telegram.ChatID = 123456789
 	config.Telegram.Admin = "@TESTUSER"
+	config.Telegram.AdminIDs = []int{123456789}
+	config.Telegram.Members = []int{987654321}
 	config.Telegram.Debug = false
 	config.Monitor.IntervalSec = 10 * time.Second
 	config.Monitor.HeartbeatIntMin = 120 * time.Minute
 	config.Monitor.DiscoveryMonitorIntMin = 120 * time.Minute
+	config.Monitor.VersionCheckIntMin = 720 * time.Minute
+	config.Host.CheckIntMin = 5 * time.Minute
+	config.Host.DiskPath = "/"
+	config.Host.MaxLoad = 2
+	config.Host.MaxMemPct = 90
+	config.Host.MinDiskFreePct = 10
+	config.Host.MaxTempC = 75.5
+	config.Probe.IntervalSec = 60 * time.Second
+	config.Probe.TimeoutSec = 2 * time.Second
+	config.Probe.Targets = []string{"192.168.0.2:8000", "192.168.0.3"}
+	config.Log.Level = "info"
+	config.Log.Format = "json"
+	config.Log.File = "/var/log/wcbot.log"
+	config.Log.MaxSizeMB = 10
+	config.Log.RotateIntMin = 1440 * time.Minute
+	config.Log.MaxBackups = 7
+	config.Log.RetentionMin = 10080 * time.Minute
+	config.Webhook.Enabled = true
+	config.Webhook.URL = "https://example.com/wcbot"
+	config.Webhook.ListenAddress = "127.0.0.1:8443"
+	config.Webhook.SecretPath = "d41d8cd98f00b204"
 
 	if diff := deep.Equal(config.String(), expectstr); diff != nil {
 		t.Error(diff)
@@ -155,3 +224,23 @@ func Test_LoadConfigParameters_BadParamData(t *testing.T) {
 		t.Error("Expected: Config should be empty")
 	}
 }
+
+func Test_LoadConfigParameters_AdminIDs(t *testing.T) {
+	// Load configuration
+	config, err := LoadConfigParameters("configtest-adminids", "./testdata", map[string]interface{}{
+		"telegram.debug":                 false,
+		"monitor.intervalsec":            10,
+		"monitor.heartbeatintmin":        120,
+		"monitor.discoverymonitorintmin": 120,
+		"skymanager.address":             "127.0.0.1:8000",
+		"skymanager.discoveryaddress":    "testnet.skywire.skycoin.com:8001",
+	})
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	if diff := deep.Equal(config.Telegram.AdminIDs, []int{123456789, 987654321}); diff != nil {
+		t.Error(diff)
+	}
+}
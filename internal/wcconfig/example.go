@@ -0,0 +1,203 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package wcconfig
+
+// exampleConfig is the content of the example configuration file (cmd/wcbot/config.example.toml),
+// which documents every configuration parameter. It is the template for NewConfigFile.
+const exampleConfig = `# Wing Commander example configuration file
+# Use this file as a tempate to build your own configuration file
+# and place it in ~/.wingcommander/config.toml
+# Default values are commented out
+# Changes to the [monitor], [host] and [probe] sections, the discovery address, Skywire
+# version settings, group members, two factor expiry and update settings are applied while
+# Wing Commander is running. Other changes require a restart.
+# Any parameter can be overridden by an environment variable (i.e. WINGCOMMANDER_MONITOR_INTERVALSEC)
+# or a command line flag (i.e. -monitor.intervalsec 30), which take precedence over this file.
+# A profile (selected using -profile NAME) overrides parameters within [profiles.NAME.SECTION]
+# sections, i.e. [profiles.staging.telegram]. Each profile runs as a separate instance.
+
+# Wing Commander application configuration
+[wingcommander]
+# Require two factor confirmation before protected commands (/stop, /update) are executed
+#twofactorenabled = false
+# Two factor mode. Either "totp" (a code from an authenticator app must be appended to the
+# command, i.e. /update 123456) or "confirm" (an inline Confirm/Cancel button is presented)
+#twofactormode = "totp"
+# TOTP secret (base32). Leave unset and send /2fasetup to the bot to generate one and
+# receive a one-time otpauth:// URI for your authenticator app.
+#twofactorsecret = ""
+# Number of seconds an inline confirmation remains valid
+#twofactorexpirysec = 60
+# /update only installs releases with a valid signature. By default they are verified using
+# the release signing key built into Wing Commander. Set this to the path of an (armored) PGP
+# public key to verify releases signed with another key (i.e. your own builds).
+#updatepublickey = "/home/USER/.wingcommander/release-key.asc"
+# Allow /update to install releases which can not be verified as no release signing key is
+# available. Only the SHA256 checksum of the release is then verified. Not recommended.
+#updateallowunsigned = false
+# Number of seconds a newly installed version has to confirm it is healthy (Telegram and the
+# Manager are reachable). If it does not, the previous version is restored and restarted.
+#updatehealthtimeoutsec = 120
+# Interval (in minutes) between automatic checks for a new release. You will be notified
+# (once) of each new release along with its release notes. Set to 0 to disable.
+#updatecheckintmin = 720
+# Release channel used by update checks and /update. Either "stable" or "prerelease"
+# (which also includes releases marked as pre-releases on GitHub)
+#updatechannel = "stable"
+# Share usage statistics (the commands used and how often they failed, see /stats) by posting
+# each event as JSON to analyticsurl, along with the version and an anonymous ID. Statistics are
+# always kept locally, and are only shared when this is enabled and analyticsurl is set.
+#analyticsenabled = false
+#analyticsurl = ""
+
+# Telegram configuration
+[telegram]
+# Telegram bot API key (token). This is provided by the @BotFather. The value must be enclosed in " "
+# Secrets (apikey and twofactorsecret) are never displayed. Rather than storing the API key here,
+# it can be provided (in order of precedence) by the WINGCOMMANDER_TELEGRAM_APIKEY environment
+# variable, a file referenced by apikeyfile, or a separate ~/.wingcommander/secrets.toml file
+# (containing a [telegram] section with the apikey). Files containing secrets should only be
+# readable by you (chmod 600); a warning is logged at startup if they are not.
+apikey = "BOT-APIKEY-HERE"
+# Path to a file containing only the Telegram bot API key
+#apikeyfile = "/home/USER/.wingcommander/apikey"
+# Telegram chatid. Go here to find this: https://api.telegram.org/bot<YourBOTToken>/getUpdates
+# This is an integer field - not a string - dont use " "
+# This can be your private chat with the bot, or a group chat (group IDs are negative)
+# in which case alerts will be posted into the group.
+chatid = 123456789
+# Your Telegram @USERNAME enclosed in " "
+# The username is only used to identify you the first time you send /start to the bot.
+# Your (immutable) Telegram user ID is then bound as the Admin and persisted to
+# ~/.wingcommander/state.json. Changing your username afterwards will not lock you out.
+admin = "@USERNAME"
+# Optional list of Telegram user IDs authorized as Admin. The bot will tell you your
+# user ID when it binds it. Adding it here makes the binding explicit.
+#adminids = [123456789]
+# Group chat only: list of Telegram user IDs of group members authorized to issue
+# (non-Admin) commands such as /status. Admin commands (/start, /stop, /update,
+# /showconfig) are restricted to the Admin.
+#members = [987654321]
+# Telegram API debugging (true or false)
+#debug = false
+
+# Skyminer monitor configuration
+# These configurations are used once monitoring is started 
+[monitor]
+# Skyminer polling interval (in seconds). Controls how often the bot will 
+# talk to the Skyminer.
+#intervalsec = 10
+
+# Bot heartbeat interval (in minutes). Default is 120min (2hrs).
+# Controls the interval that the bot will automatically provide any update
+# via Telegram. This is active once monitoring has started.
+#heartbeatintmin = 120
+
+#discoverymonitorintmin = 120
+
+# Interval (in minutes) between checks of the Skywire version run by each Node.
+# You will be alerted (once) if Nodes are running an outdated version, or different
+# versions. Use /versions to check at any time. Set to 0 to disable.
+#versioncheckintmin = 720
+
+# Skyminer Manager configuration
+[skymanager]
+# IP:PORT for where the Skyminer Manager node is located.
+# The bot can be run on a seperate machine to the Manager, but by default
+# it is assumed it will be running on the same machine and that the 
+# Skywire Manager app defaults are in use
+#address="127.0.0.1:8000"
+
+# Skycoin Skywire Discovery Node address
+#discoveryaddress="testnet.skywire.skycoin.com:8001"
+
+# GitHub repository (owner/repo) whose latest release is the latest Skywire version
+#skywirerepo="skycoin/skywire"
+# Alternatively, the Skywire version all Nodes are expected to run. When set, GitHub is not checked.
+#skywireversion="v0.1.0"
+
+# Host resource monitoring (Linux only)
+# Monitors the host Wing Commander is running on (typically the Skyminer Manager board)
+# You will be alerted (once) when a limit is exceeded, and again once it recovers.
+# Use /host to check at any time. Set a limit to 0 to disable its check.
+[host]
+# Interval (in minutes) between checks. Set to 0 to disable.
+#checkintmin = 5
+# Path on the filesystem whose free space is monitored
+#diskpath = "/"
+# Maximum 1 minute load average per CPU
+#maxload = 2.0
+# Maximum percentage of memory in use
+#maxmempct = 90
+# Minimum percentage of free disk space
+#mindiskfreepct = 10
+# Maximum temperature (°C) reported by any thermal zone
+#maxtempc = 75
+
+# Network reachability probes
+# Periodically checks that Nodes are reachable on the LAN. You will be alerted when a
+# target becomes unreachable (noting if the Manager still lists the Node as connected)
+# and when it recovers. Use /probes to see the latency and loss of each target.
+[probe]
+# Targets to probe. A target with a port ("IP:PORT") is probed by connecting to it (TCP).
+# A target without a port ("IP") is pinged (ICMP), which requires root or CAP_NET_RAW.
+# Probes are disabled unless targets are configured.
+#targets = ["192.168.0.2:8000", "192.168.0.3:8000"]
+# Interval (in seconds) between probes. Set to 0 to disable.
+#intervalsec = 60
+# Number of seconds after which a probe is considered to have failed
+#timeoutsec = 2
+
+# Application logging
+# The log level can also be changed while running using /loglevel
+[log]
+# Minimum level logged: "error", "warn", "info" or "debug". Telegram API requests and
+# responses are only logged when telegram.debug is also enabled.
+#level = "info"
+# Log format: "text" or "json" (one JSON object per line)
+#format = "text"
+# Path of the log file. The log is written to the console (stderr) if it is not set.
+#file = "/home/USER/.wingcommander/wcbot.log"
+# Size (in MB) at which the log file is rotated. Set to 0 to disable.
+#maxsizemb = 10
+# Interval (in minutes) after which the log file is rotated. Set to 0 to disable.
+#rotateintmin = 1440
+# Number of rotated log files to keep. Set to 0 to keep all.
+#maxbackups = 7
+# Number of minutes after which rotated log files are removed. Set to 0 to keep all.
+#retentionmin = 10080
+
+# Receive updates from Telegram using a webhook, rather than by polling Telegram for them.
+# Telegram sends each update to the public https url, so Wing Commander must be reachable
+# from the internet: usually behind a reverse proxy (i.e. nginx) which terminates TLS and
+# forwards requests to listenaddress, or directly by setting certfile and keyfile.
+# Polling is used unless enabled is set.
+[webhook]
+#enabled = false
+# Public https URL Telegram sends updates to. Telegram only supports ports 443, 80, 88 and 8443.
+#url = "https://example.com/wcbot"
+# IP:PORT address the webhook is served on. Use ":8443" to listen on all interfaces.
+#listenaddress = "127.0.0.1:8443"
+# TLS certificate and private key (PEM) used to serve the webhook using https. The certificate
+# is also sent to Telegram, so it may be self-signed. Leave empty behind a reverse proxy.
+#certfile = "/home/USER/.wingcommander/webhook.pem"
+#keyfile = "/home/USER/.wingcommander/webhook.key"
+# Secrets used to verify that requests were sent by Telegram (A-Z, a-z, 0-9, _ and -). At
+# least one of them must be set when the webhook is enabled.
+# secretpath is appended to the path of url (and must be used by a reverse proxy).
+# Telegram sends secrettoken with every request (in the X-Telegram-Bot-Api-Secret-Token header).
+# Like the API key, they can be provided by the WINGCOMMANDER_WEBHOOK_SECRETTOKEN (and
+# WINGCOMMANDER_WEBHOOK_SECRETPATH) environment variable or ~/.wingcommander/secrets.toml.
+#secretpath = "RANDOM-PATH-HERE"
+#secrettoken = "RANDOM-TOKEN-HERE"
+`
+
+// NewConfigFile returns the content of a complete, commented configuration file (based on the
+// example configuration file) in which the configuration parameters identified by keys are set
+// to their value in c. Other parameters are left commented out, so their defaults apply.
+func NewConfigFile(c Config, keys ...string) (string, error) {
+	return setKeys(exampleConfig, "", c, keys)
+}
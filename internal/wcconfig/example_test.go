@@ -0,0 +1,59 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package wcconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ExampleConfig_MatchesFile(t *testing.T) {
+	data, err := ioutil.ReadFile("../../cmd/wcbot/config.example.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != exampleConfig {
+		t.Error("Expected: exampleConfig to match cmd/wcbot/config.example.toml. Update exampleConfig when changing the example.")
+	}
+}
+
+func Test_NewConfigFile(t *testing.T) {
+	c := validConfig()
+	c.Telegram.AdminIDs = []int{123456789}
+	text, err := NewConfigFile(c, "telegram.apikey", "telegram.chatid", "telegram.admin", "telegram.adminids", "skymanager.address")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expect := range []string{
+		"\napikey = \"123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw\"\n",
+		"\n#adminids = [123456789]\nadminids = [123456789]\n",
+		"\n#address=\"127.0.0.1:8000\"\naddress = \"127.0.0.1:8000\"\n",
+		"# Telegram bot API key (token).",
+	} {
+		if !strings.Contains(text, expect) {
+			t.Errorf("Expected: %q in:\n%s", expect, text)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "wcconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.toml"), []byte(text), 0600); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConfigParameters("config", dir, map[string]interface{}{"monitor.intervalsec": 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Telegram.ChatID != c.Telegram.ChatID || loaded.Telegram.Admin != "@TESTUSER" || loaded.Monitor.IntervalSec != 10*time.Second {
+		t.Errorf("Unexpected loaded config: %s", loaded.String())
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package wcconfig
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// secretKeys are configuration keys whose values must never be displayed
+var secretKeys = map[string]bool{
+	"telegram.apikey":               true,
+	"wingcommander.twofactorsecret": true,
+	"webhook.secretpath":            true,
+	"webhook.secrettoken":           true,
+}
+
+// reloadableKeys are configuration keys which can be changed while Wing Commander is
+// running. A key ending in `.` covers every key of that section. Other keys (such as the
+// Telegram API key, the Admin, two factor settings and the Manager address) are only
+// applied on restart.
+var reloadableKeys = []string{
+	"monitor.",
+	"host.",
+	"probe.",
+	"log.",
+	"skymanager.discoveryaddress",
+	"skymanager.skywirerepo",
+	"skymanager.skywireversion",
+	"telegram.members",
+	"telegram.debug",
+	"wingcommander.twofactorexpirysec",
+	"wingcommander.updatehealthtimeoutsec",
+	"wingcommander.updatecheckintmin",
+	"wingcommander.updatechannel",
+}
+
+// IsSecret determines if the value of the provided configuration key must not be displayed
+func IsSecret(key string) bool {
+	return secretKeys[key]
+}
+
+// IsReloadable determines if the provided configuration key can be changed while running
+func IsReloadable(key string) bool {
+	for _, k := range reloadableKeys {
+		if key == k || strings.HasSuffix(k, ".") && strings.HasPrefix(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// configFields calls fn for each configuration parameter of the provided
+// Config value, along with its key (i.e. `monitor.intervalsec`)
+func configFields(v reflect.Value, fn func(key string, field reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		section := t.Field(i).Tag.Get("mapstructure")
+		sv := v.Field(i)
+		st := sv.Type()
+		for j := 0; j < st.NumField(); j++ {
+			fn(section+"."+st.Field(j).Tag.Get("mapstructure"), sv.Field(j))
+		}
+	}
+}
+
+// configField returns the configuration parameter of the provided Config value identified by key
+func configField(v reflect.Value, key string) (reflect.Value, error) {
+	var found reflect.Value
+	configFields(v, func(k string, field reflect.Value) {
+		if k == key {
+			found = field
+		}
+	})
+	if !found.IsValid() {
+		return found, fmt.Errorf("unknown configuration key %q", key)
+	}
+	return found, nil
+}
+
+// Keys returns the key of every configuration parameter (i.e. `monitor.intervalsec`)
+func Keys() []string {
+	var keys []string
+	configFields(reflect.ValueOf(Config{}), func(key string, field reflect.Value) {
+		keys = append(keys, key)
+	})
+	return keys
+}
+
+// Diff returns the keys of the configuration parameters which differ between a and b
+func Diff(a, b Config) []string {
+	bv := reflect.ValueOf(b)
+	var keys []string
+	configFields(reflect.ValueOf(a), func(key string, field reflect.Value) {
+		other, _ := configField(bv, key)
+		if !reflect.DeepEqual(field.Interface(), other.Interface()) {
+			keys = append(keys, key)
+		}
+	})
+	return keys
+}
+
+// CopyKey sets the configuration parameter identified by key in dst to its value in src
+func CopyKey(dst *Config, src Config, key string) error {
+	df, err := configField(reflect.ValueOf(dst).Elem(), key)
+	if err != nil {
+		return err
+	}
+	sf, err := configField(reflect.ValueOf(src), key)
+	if err != nil {
+		return err
+	}
+	df.Set(sf)
+	return nil
+}
+
+// Value returns the value of the configuration parameter identified by key, formatted
+// for display. The values of secret parameters (see IsSecret) are masked.
+func (c *Config) Value(key string) (string, error) {
+	f, err := configField(reflect.ValueOf(*c), key)
+	if err != nil {
+		return "", err
+	}
+	if IsSecret(key) && f.String() != "" {
+		return redact(f.String()), nil
+	}
+	if f.Kind() == reflect.String {
+		return fmt.Sprintf("%q", f.String()), nil
+	}
+	return fmt.Sprintf("%v", f.Interface()), nil
+}
+
+// durationUnit returns the unit a duration configuration parameter is specified in within
+// config.toml, based on its key (i.e. seconds for `monitor.intervalsec`)
+func durationUnit(key string) time.Duration {
+	if strings.HasSuffix(key, "min") {
+		return time.Minute
+	}
+	return time.Second
+}
+
+// Set parses value according to the type of the configuration parameter identified by key and sets it.
+// Durations are provided in the unit of the parameter (i.e. seconds for `monitor.intervalsec`) or as a
+// duration (i.e. `90s`), and lists are comma separated. The resulting Config should be validated.
+func (c *Config) Set(key, value string) error {
+	f, err := configField(reflect.ValueOf(c).Elem(), key)
+	if err != nil {
+		return err
+	}
+	value = strings.TrimSpace(value)
+
+	switch f.Interface().(type) {
+	case string:
+		f.SetString(strings.Trim(value, `"`))
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false", key)
+		}
+		f.SetBool(b)
+	case time.Duration:
+		unit := durationUnit(key)
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%s must be a number of %s or a duration (i.e. 90s)", key, unitName(unit))
+			}
+			d = time.Duration(n) * unit
+		}
+		if d%unit != 0 {
+			return fmt.Errorf("%s must be a whole number of %s", key, unitName(unit))
+		}
+		f.SetInt(int64(d))
+	case int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be a whole number", key)
+		}
+		f.SetInt(n)
+	case float64:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be a number", key)
+		}
+		f.SetFloat(n)
+	case []int:
+		var ids []int
+		for _, item := range splitList(value) {
+			n, err := strconv.Atoi(item)
+			if err != nil {
+				return fmt.Errorf("%s must be a comma separated list of whole numbers", key)
+			}
+			ids = append(ids, n)
+		}
+		f.Set(reflect.ValueOf(ids))
+	case []string:
+		f.Set(reflect.ValueOf(splitList(value)))
+	default:
+		return fmt.Errorf("%s can not be set", key)
+	}
+	return nil
+}
+
+// unitName returns the name of a duration unit
+func unitName(unit time.Duration) string {
+	if unit == time.Minute {
+		return "minutes"
+	}
+	return "seconds"
+}
+
+// splitList splits a comma separated (optionally bracketed and quoted) list
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(strings.Trim(value, "[]"), ",") {
+		if item = strings.Trim(strings.TrimSpace(item), `"`); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// tomlValue returns the value of the configuration parameter identified by key,
+// formatted as it is written within config.toml
+func (c *Config) tomlValue(key string) (string, error) {
+	f, err := configField(reflect.ValueOf(*c), key)
+	if err != nil {
+		return "", err
+	}
+
+	switch v := f.Interface().(type) {
+	case string:
+		return strconv.Quote(v), nil
+	case time.Duration:
+		return strconv.FormatInt(int64(v/durationUnit(key)), 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []int:
+		items := make([]string, len(v))
+		for i, n := range v {
+			items[i] = strconv.Itoa(n)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case []string:
+		items := make([]string, len(v))
+		for i, s := range v {
+			items[i] = strconv.Quote(s)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	default:
+		return fmt.Sprintf("%v", v), nil
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package wcconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func Test_Keys(t *testing.T) {
+	keys := Keys()
+	for _, expect := range []string{"wingcommander.twofactorenabled", "telegram.apikey", "monitor.intervalsec", "probe.targets"} {
+		found := false
+		for _, k := range keys {
+			found = found || k == expect
+		}
+		if !found {
+			t.Errorf("Expected: key %s in %v", expect, keys)
+		}
+	}
+}
+
+func Test_IsReloadable(t *testing.T) {
+	for key, expect := range map[string]bool{
+		"monitor.intervalsec":         true,
+		"host.maxtempc":               true,
+		"skymanager.discoveryaddress": true,
+		"skymanager.address":          false,
+		"telegram.apikey":             false,
+		"wingcommander.twofactormode": false,
+	} {
+		if IsReloadable(key) != expect {
+			t.Errorf("%s: Expected: IsReloadable %v", key, expect)
+		}
+	}
+}
+
+func Test_DiffAndCopyKey(t *testing.T) {
+	a := validConfig()
+	b := validConfig()
+	b.Monitor.IntervalSec = 30 * time.Second
+	b.Telegram.APIKey = "987654321:OTHER"
+	b.Probe.Targets = []string{"192.168.0.2:8000"}
+
+	keys := Diff(a, b)
+	if diff := deep.Equal(keys, []string{"telegram.apikey", "monitor.intervalsec", "probe.targets"}); diff != nil {
+		t.Fatal(diff)
+	}
+
+	if err := CopyKey(&a, b, "monitor.intervalsec"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(Diff(a, b), []string{"telegram.apikey", "probe.targets"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if err := CopyKey(&a, b, "monitor.unknown"); err == nil {
+		t.Error("Expected: copying an unknown key to fail")
+	}
+}
+
+func Test_Value(t *testing.T) {
+	c := validConfig()
+	for key, expect := range map[string]string{
+		"monitor.intervalsec": "10s",
+		"skymanager.address":  `"127.0.0.1:8000"`,
+		"telegram.chatid":     "123456789",
+		"telegram.apikey":     "********",
+		"host.maxmempct":      "90",
+	} {
+		v, err := c.Value(key)
+		if err != nil {
+			t.Errorf("%s: %v", key, err)
+			continue
+		}
+		if v != expect {
+			t.Errorf("%s: Expected: %s, got %s", key, expect, v)
+		}
+	}
+
+	if _, err := c.Value("telegram.unknown"); err == nil {
+		t.Error("Expected: error for an unknown key")
+	}
+}
+
+func Test_WatchConfigParameters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wcconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data, err := ioutil.ReadFile("testdata/configtest-allparams.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.toml")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloadDelay = 10 * time.Millisecond
+	changes := make(chan Config, 10)
+	err = WatchConfigParameters("config", dir, nil, func(c Config, err error) {
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		changes <- c
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data = append(data, []byte("\n[host]\nmaxtempc = 70\n")...)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case c := <-changes:
+		if c.Host.MaxTempC != 70 || c.Monitor.IntervalSec != 10*time.Second {
+			t.Errorf("Unexpected reloaded config: %s", c.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Expected: the changed configuration to be reloaded")
+	}
+}
+
+func Test_Set(t *testing.T) {
+	testCases := []struct {
+		key    string
+		value  string
+		expect string
+	}{
+		{"monitor.intervalsec", "30", "30s"},
+		{"monitor.heartbeatintmin", "90", "1h30m0s"},
+		{"monitor.heartbeatintmin", "2h", "2h0m0s"},
+		{"skymanager.discoveryaddress", "discovery.skycoin.com:8001", `"discovery.skycoin.com:8001"`},
+		{"telegram.debug", "true", "true"},
+		{"telegram.members", "1001, 1002", "[1001 1002]"},
+		{"host.maxload", "3.5", "3.5"},
+		{"probe.targets", "192.168.0.2:8000,192.168.0.3", "[192.168.0.2:8000 192.168.0.3]"},
+	}
+
+	for _, tc := range testCases {
+		c := validConfig()
+		if err := c.Set(tc.key, tc.value); err != nil {
+			t.Errorf("%s: %v", tc.key, err)
+			continue
+		}
+		if v, _ := c.Value(tc.key); v != tc.expect {
+			t.Errorf("%s: Expected: %s, got %s", tc.key, tc.expect, v)
+		}
+	}
+
+	c := validConfig()
+	for key, value := range map[string]string{
+		"monitor.intervalsec":     "often",
+		"monitor.heartbeatintmin": "90s",
+		"telegram.debug":          "maybe",
+		"telegram.members":        "@someone",
+		"host.maxload":            "high",
+		"monitor.unknown":         "1",
+	} {
+		if err := c.Set(key, value); err == nil {
+			t.Errorf("%s: Expected: %q to be rejected", key, value)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package wcconfig
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// envPrefix is the prefix of the environment variables which override configuration parameters
+const envPrefix = "WINGCOMMANDER_"
+
+var (
+	overridesM sync.RWMutex
+	// overrides holds the configuration parameters provided on the command line, by key
+	overrides = map[string]string{}
+)
+
+// EnvName returns the name of the environment variable which overrides the
+// configuration parameter identified by key (i.e. `WINGCOMMANDER_SKYMANAGER_ADDRESS`)
+func EnvName(key string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+// SetOverrides sets the configuration parameters provided on the command line (by key).
+// They take precedence over the environment and the configuration file whenever the
+// configuration is loaded (including when it is reloaded).
+func SetOverrides(values map[string]string) {
+	overridesM.Lock()
+	defer overridesM.Unlock()
+	overrides = make(map[string]string, len(values))
+	for k, v := range values {
+		overrides[k] = v
+	}
+}
+
+// applyOverrides sets each configuration parameter provided by its environment variable
+// (see EnvName) or on the command line (see SetOverrides), in that order of precedence
+// (lowest first). Values use the same format as the /set command.
+// Secret parameters are provided by resolveSecrets instead.
+func (c *Config) applyOverrides() error {
+	overridesM.RLock()
+	defer overridesM.RUnlock()
+
+	for _, key := range Keys() {
+		if IsSecret(key) {
+			continue
+		}
+		if value, ok := os.LookupEnv(EnvName(key)); ok {
+			if err := c.Set(key, value); err != nil {
+				return fmt.Errorf("invalid %s: %v", EnvName(key), err)
+			}
+		}
+		if value, ok := overrides[key]; ok {
+			if err := c.Set(key, value); err != nil {
+				return fmt.Errorf("invalid -%s: %v", key, err)
+			}
+		}
+	}
+	return nil
+}
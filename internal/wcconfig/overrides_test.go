@@ -0,0 +1,76 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package wcconfig
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_EnvName(t *testing.T) {
+	if name := EnvName("skymanager.address"); name != "WINGCOMMANDER_SKYMANAGER_ADDRESS" {
+		t.Errorf("Unexpected environment variable name: %s", name)
+	}
+}
+
+func Test_ApplyOverrides(t *testing.T) {
+	dir := writeSecretsTestFiles(t, map[string]string{
+		"config.toml": "[skymanager]\naddress = \"127.0.0.1:8000\"\n[monitor]\nintervalsec = 10\nheartbeatintmin = 120\n" +
+			"[wingcommander]\nupdatechannel = \"stable\"\n",
+	})
+	defer os.RemoveAll(dir)
+
+	os.Setenv("WINGCOMMANDER_SKYMANAGER_ADDRESS", "10.0.0.1:8000")
+	os.Setenv("WINGCOMMANDER_MONITOR_INTERVALSEC", "30")
+	os.Setenv("WINGCOMMANDER_WINGCOMMANDER_UPDATECHANNEL", "PreRelease")
+	defer os.Unsetenv("WINGCOMMANDER_SKYMANAGER_ADDRESS")
+	defer os.Unsetenv("WINGCOMMANDER_MONITOR_INTERVALSEC")
+	defer os.Unsetenv("WINGCOMMANDER_WINGCOMMANDER_UPDATECHANNEL")
+
+	SetOverrides(map[string]string{"skymanager.address": "10.0.0.2:8000", "monitor.heartbeatintmin": "2h"})
+	defer SetOverrides(nil)
+
+	c, err := LoadConfigParameters("config", dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Command line flags take precedence over the environment
+	if c.SkyManager.Address != "10.0.0.2:8000" {
+		t.Errorf("Expected: skymanager.address from the command line, got %q", c.SkyManager.Address)
+	}
+	if c.Monitor.IntervalSec != 30*time.Second {
+		t.Errorf("Expected: monitor.intervalsec from the environment, got %v", c.Monitor.IntervalSec)
+	}
+	if c.Monitor.HeartbeatIntMin != 2*time.Hour {
+		t.Errorf("Expected: monitor.heartbeatintmin from the command line, got %v", c.Monitor.HeartbeatIntMin)
+	}
+	// Overrides are normalised as if provided by the file
+	if c.WingCommander.UpdateChannel != "prerelease" {
+		t.Errorf("Expected: wingcommander.updatechannel from the environment, got %q", c.WingCommander.UpdateChannel)
+	}
+}
+
+func Test_ApplyOverrides_Invalid(t *testing.T) {
+	dir := writeSecretsTestFiles(t, map[string]string{
+		"config.toml": "[monitor]\nintervalsec = 10\n",
+	})
+	defer os.RemoveAll(dir)
+
+	os.Setenv("WINGCOMMANDER_MONITOR_INTERVALSEC", "often")
+	_, err := LoadConfigParameters("config", dir, nil)
+	os.Unsetenv("WINGCOMMANDER_MONITOR_INTERVALSEC")
+	if err == nil || !strings.Contains(err.Error(), "invalid WINGCOMMANDER_MONITOR_INTERVALSEC") {
+		t.Errorf("Expected: an invalid environment variable error, got %v", err)
+	}
+
+	SetOverrides(map[string]string{"monitor.intervalsec": "1.5s"})
+	defer SetOverrides(nil)
+	if _, err := LoadConfigParameters("config", dir, nil); err == nil || !strings.Contains(err.Error(), "invalid -monitor.intervalsec") {
+		t.Errorf("Expected: an invalid command line flag error, got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package wcconfig
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/spf13/viper"
+)
+
+// profileNameRegexp matches valid profile names. Profile names are used within
+// TOML section names and file names, so are limited to lower case letters, digits, `-` and `_`.
+var profileNameRegexp = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// CheckProfileName returns an error if name is not a valid profile name
+func CheckProfileName(name string) error {
+	if !profileNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid profile %q: only lower case letters, digits, - and _ are allowed", name)
+	}
+	return nil
+}
+
+// profileSection returns the name of the section holding the parameters of the named
+// profile (i.e. `profiles.staging`). A profile parameter is set within a section named
+// after the profile section and its own section, for example:
+//
+//	[profiles.staging.telegram]
+//	chatid = 123456789
+func profileSection(profile string) string {
+	return "profiles." + profile
+}
+
+// applyProfile sets each configuration parameter set by the named profile,
+// so it takes precedence over the rest of the configuration file
+func applyProfile(v *viper.Viper, profile string) error {
+	if profile == "" {
+		return nil
+	}
+	if err := CheckProfileName(profile); err != nil {
+		return err
+	}
+
+	section := profileSection(profile)
+	if !v.IsSet(section) {
+		return fmt.Errorf("profile %q is not defined within %s (expected sections such as [%s.telegram])",
+			profile, v.ConfigFileUsed(), section)
+	}
+	for _, key := range Keys() {
+		if v.IsSet(section + "." + key) {
+			v.Set(key, v.Get(section+"."+key))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package wcconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_LoadConfigFile_Profiles(t *testing.T) {
+	testCases := []struct {
+		profile  string
+		apikey   string
+		chatid   int64
+		debug    bool
+		interval time.Duration
+		address  string
+	}{
+		{"", "BOT-APIKEY-HERE", 123456789, false, 10 * time.Second, "127.0.0.1:8000"},
+		{"staging", "STAGING-APIKEY-HERE", 987654321, true, 30 * time.Second, "127.0.0.1:8000"},
+		{"production", "BOT-APIKEY-HERE", 123456789, false, 10 * time.Second, "192.168.0.2:8000"},
+	}
+
+	for _, tc := range testCases {
+		c, err := LoadConfigFile("testdata/configtest-profiles.toml", tc.profile, nil)
+		if err != nil {
+			t.Errorf("%q: %v", tc.profile, err)
+			continue
+		}
+		if c.Telegram.APIKey != tc.apikey || c.Telegram.ChatID != tc.chatid || c.Telegram.Debug != tc.debug ||
+			c.Monitor.IntervalSec != tc.interval || c.SkyManager.Address != tc.address {
+			t.Errorf("%q: Unexpected config: %s", tc.profile, c.String())
+		}
+		// Parameters not set by the profile are taken from the rest of the file
+		if c.Telegram.Admin != "@USERNAME" || c.Monitor.HeartbeatIntMin != 120*time.Minute {
+			t.Errorf("%q: Expected: the rest of the file to apply, got: %s", tc.profile, c.String())
+		}
+	}
+}
+
+func Test_LoadConfigFile_UnknownProfile(t *testing.T) {
+	for _, profile := range []string{"missing", "Bad.Name"} {
+		if _, err := LoadConfigFile("testdata/configtest-profiles.toml", profile, nil); err == nil {
+			t.Errorf("%q: Expected: an error", profile)
+		}
+	}
+}
+
+func Test_LoadConfigFile_AnyExtension(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/configtest-profiles.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "wcconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "staging.conf")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := LoadConfigFile(path, "staging", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Telegram.ChatID != 987654321 {
+		t.Errorf("Unexpected config: %s", c.String())
+	}
+}
+
+func Test_WriteKey_Profile(t *testing.T) {
+	dir := writeSecretsTestFiles(t, map[string]string{
+		"config.toml": "[monitor]\nintervalsec = 10\n\n[profiles.staging.monitor]\nintervalsec = 30\n",
+		"secrets.toml": "[telegram]\napikey = \"SECRETS\"\n\n" +
+			"[profiles.staging.telegram]\napikey = \"STAGING\"\n",
+	})
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.toml")
+
+	c, err := LoadConfigFile(path, "staging", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Secrets are also selected by profile
+	if c.Telegram.APIKey != "STAGING" {
+		t.Errorf("Expected: the staging apikey from secrets.toml, got %q", c.Telegram.APIKey)
+	}
+
+	c.Monitor.IntervalSec = 60 * time.Second
+	c.Monitor.HeartbeatIntMin = 30 * time.Minute
+	for _, key := range []string{"monitor.intervalsec", "monitor.heartbeatintmin"} {
+		if err := WriteKey(path, "staging", c, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "[monitor]\nintervalsec = 10\n\n[profiles.staging.monitor]\nheartbeatintmin = 30\nintervalsec = 60\n"
+	if string(data) != expect {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expect, data)
+	}
+
+	base, err := LoadConfigFile(path, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.Monitor.IntervalSec != 10*time.Second || base.Telegram.APIKey != "SECRETS" {
+		t.Errorf("Expected: the rest of the file to be unchanged, got: %s", base.String())
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package wcconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// redacted replaces the value of secret configuration parameters when rendered
+const redacted = "********"
+
+// SecretsFilename is the name of the optional secrets file, which resides alongside the
+// configuration file. It has the same format as the configuration file, but should only
+// contain secret parameters (such as `telegram.apikey`) and only be readable by its owner.
+const SecretsFilename = "secrets.toml"
+
+// redact returns the value of a secret configuration parameter as it is rendered
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redacted
+}
+
+// Redact replaces the value of every secret configuration parameter found within s.
+// Used for text which may contain a secret, such as errors including a Telegram API URL.
+func (c *Config) Redact(s string) string {
+	v := reflect.ValueOf(*c)
+	for key := range secretKeys {
+		if f, err := configField(v, key); err == nil && f.String() != "" {
+			s = strings.Replace(s, f.String(), redacted, -1)
+		}
+	}
+	return s
+}
+
+// warnPermissions logs a warning if the file at path, which contains a secret,
+// is readable or writable by users other than its owner
+func warnPermissions(path, key string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		log.Warnf("ReadConfig: %s contains %s but is accessible by other users (mode %v). Restrict it using: chmod 600 %s",
+			path, key, info.Mode().Perm(), path)
+	}
+}
+
+// resolveSecrets sets each secret configuration parameter (see IsSecret) from the first of the
+// following sources which provides it:
+//   - the environment variable named by EnvName (i.e. `WINGCOMMANDER_TELEGRAM_APIKEY`)
+//   - the file referenced by the `<key>file` parameter (i.e. `telegram.apikeyfile`)
+//   - the secrets file (SecretsFilename) within pathname, within the section of the profile
+//     (see applyProfile) if one is selected, and otherwise the section of the parameter
+//   - the configuration file itself
+//
+// A warning is logged for each file providing a secret which is accessible by other users.
+func resolveSecrets(v *viper.Viper, pathname, profile string) error {
+	secrets := viper.New()
+	secretsPath := filepath.Join(pathname, SecretsFilename)
+	secrets.SetConfigFile(secretsPath)
+	if _, err := os.Stat(secretsPath); err == nil {
+		if err := secrets.ReadInConfig(); err != nil {
+			return fmt.Errorf("failed to read %s: %v", secretsPath, err)
+		}
+	}
+
+	for key := range secretKeys {
+		if value := os.Getenv(EnvName(key)); value != "" {
+			v.Set(key, value)
+			continue
+		}
+
+		if path := v.GetString(key + "file"); path != "" {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read %sfile: %v", key, err)
+			}
+			warnPermissions(path, key)
+			v.Set(key, strings.TrimSpace(string(data)))
+			continue
+		}
+
+		if profile != "" && secrets.IsSet(profileSection(profile)+"."+key) {
+			warnPermissions(secretsPath, key)
+			v.Set(key, secrets.GetString(profileSection(profile)+"."+key))
+			continue
+		}
+
+		if secrets.IsSet(key) {
+			warnPermissions(secretsPath, key)
+			v.Set(key, secrets.GetString(key))
+			continue
+		}
+
+		if v.GetString(key) != "" && v.ConfigFileUsed() != "" {
+			warnPermissions(v.ConfigFileUsed(), key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package wcconfig
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// writeSecretsTestFiles creates a config folder containing config.toml and the provided files
+func writeSecretsTestFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "wcconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		content = strings.Replace(content, "$DIR", dir, -1)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func Test_ResolveSecrets(t *testing.T) {
+	testCases := []struct {
+		name   string
+		env    string
+		files  map[string]string
+		expect string
+	}{
+		{"config file", "", map[string]string{
+			"config.toml": "[telegram]\napikey = \"CONFIG\"\n",
+		}, "CONFIG"},
+		{"secrets file", "", map[string]string{
+			"config.toml":  "[telegram]\napikey = \"CONFIG\"\n",
+			"secrets.toml": "[telegram]\napikey = \"SECRETS\"\n",
+		}, "SECRETS"},
+		{"apikeyfile", "", map[string]string{
+			"config.toml":  "[telegram]\napikeyfile = \"$DIR/apikey\"\n",
+			"secrets.toml": "[telegram]\napikey = \"SECRETS\"\n",
+			"apikey":       "APIKEYFILE\n",
+		}, "APIKEYFILE"},
+		{"environment", "ENV", map[string]string{
+			"config.toml":  "[telegram]\napikeyfile = \"$DIR/apikey\"\n",
+			"secrets.toml": "[telegram]\napikey = \"SECRETS\"\n",
+			"apikey":       "APIKEYFILE\n",
+		}, "ENV"},
+	}
+
+	defer os.Unsetenv("WINGCOMMANDER_TELEGRAM_APIKEY")
+	for _, tc := range testCases {
+		dir := writeSecretsTestFiles(t, tc.files)
+		os.Setenv("WINGCOMMANDER_TELEGRAM_APIKEY", tc.env)
+
+		c, err := LoadConfigParameters("config", dir, nil)
+		os.RemoveAll(dir)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if c.Telegram.APIKey != tc.expect {
+			t.Errorf("%s: Expected: apikey %q, got %q", tc.name, tc.expect, c.Telegram.APIKey)
+		}
+	}
+}
+
+func Test_ResolveSecrets_MissingFile(t *testing.T) {
+	dir := writeSecretsTestFiles(t, map[string]string{
+		"config.toml": "[telegram]\napikeyfile = \"$DIR/missing\"\n",
+	})
+	defer os.RemoveAll(dir)
+
+	if _, err := LoadConfigParameters("config", dir, nil); err == nil || !strings.Contains(err.Error(), "telegram.apikeyfile") {
+		t.Errorf("Expected: an error reading telegram.apikeyfile, got %v", err)
+	}
+}
+
+func Test_ResolveSecrets_WarnPermissions(t *testing.T) {
+	dir := writeSecretsTestFiles(t, map[string]string{
+		"config.toml": "[telegram]\napikey = \"CONFIG\"\n",
+	})
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	if _, err := LoadConfigParameters("config", dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "accessible by other users") {
+		t.Errorf("Unexpected warning for a private file: %s", buf.String())
+	}
+
+	if err := os.Chmod(filepath.Join(dir, "config.toml"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfigParameters("config", dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "contains telegram.apikey but is accessible by other users") {
+		t.Errorf("Expected: a permissions warning, got: %s", buf.String())
+	}
+}
+
+func Test_Redact(t *testing.T) {
+	c := validConfig()
+	c.WingCommander.TwoFactorSecret = "JBSWY3DPEHPK3PXP"
+
+	s := c.Redact("Post https://api.telegram.org/bot" + c.Telegram.APIKey + "/getMe: timeout (JBSWY3DPEHPK3PXP)")
+	if s != "Post https://api.telegram.org/bot********/getMe: timeout (********)" {
+		t.Errorf("Unexpected redacted text: %s", s)
+	}
+}
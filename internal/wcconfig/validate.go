@@ -0,0 +1,293 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package wcconfig
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/netprobe"
+	"github.com/BigOokie/skywire-wing-commander/internal/totp"
+	"github.com/BigOokie/skywire-wing-commander/internal/wclog"
+)
+
+// Severities of a ValidationIssue
+const (
+	// SeverityError identifies an issue which prevents Wing Commander from running correctly
+	SeverityError = "error"
+	// SeverityWarning identifies an issue which is likely to be a mistake
+	SeverityWarning = "warning"
+)
+
+// exampleAPIKey and exampleAdmin are the placeholders used in config.example.toml
+const (
+	exampleAPIKey = "BOT-APIKEY-HERE"
+	exampleAdmin  = "@USERNAME"
+)
+
+// webhookSecretPattern matches the characters Telegram permits within a webhook secret token,
+// which are also safe to use within the path of the webhook URL
+var webhookSecretPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// webhookPorts are the ports Telegram is able to send webhook requests to
+var webhookPorts = map[string]bool{"": true, "443": true, "80": true, "88": true, "8443": true}
+
+// apiKeyPattern matches the format of a Telegram bot API key (token), i.e. `123456789:AAH...`
+var apiKeyPattern = regexp.MustCompile(`^[0-9]+:[A-Za-z0-9_-]+$`)
+
+// ValidationIssue models a problem with a configuration parameter (Field)
+type ValidationIssue struct {
+	Field    string
+	Severity string
+	Message  string
+}
+
+// String is the stringer function for the ValidationIssue struct
+func (vi ValidationIssue) String() string {
+	return fmt.Sprintf("%s: %s: %s", vi.Severity, vi.Field, vi.Message)
+}
+
+// ValidationIssues is the list of issues returned by Validate
+type ValidationIssues []ValidationIssue
+
+// HasErrors determines if any of the issues is an error (rather than a warning)
+func (vis ValidationIssues) HasErrors() bool {
+	for _, vi := range vis {
+		if vi.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the issues (one per line)
+func (vis ValidationIssues) String() string {
+	var lines []string
+	for _, vi := range vis {
+		lines = append(lines, vi.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validator accumulates the issues found by Validate
+type validator struct {
+	issues ValidationIssues
+}
+
+func (v *validator) errorf(field, format string, a ...interface{}) {
+	v.issues = append(v.issues, ValidationIssue{field, SeverityError, fmt.Sprintf(format, a...)})
+}
+
+func (v *validator) warnf(field, format string, a ...interface{}) {
+	v.issues = append(v.issues, ValidationIssue{field, SeverityWarning, fmt.Sprintf(format, a...)})
+}
+
+// address checks that an IP:PORT (or host:port) address is valid
+func (v *validator) address(field, addr string) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		v.errorf(field, "%q is not a valid IP:PORT address (%v)", addr, err)
+		return
+	}
+	if host == "" {
+		v.errorf(field, "%q does not include an IP address or host name", addr)
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		v.errorf(field, "%q does not include a valid port (1-65535)", addr)
+	}
+}
+
+// notNegative checks that an interval, which may be set to 0 to disable a feature, is not negative
+func (v *validator) notNegative(field string, value float64) {
+	if value < 0 {
+		v.errorf(field, "must not be negative (set to 0 to disable)")
+	}
+}
+
+// percentage checks that a threshold is a valid percentage (0 disables it)
+func (v *validator) percentage(field string, value float64) {
+	if value < 0 || value > 100 {
+		v.errorf(field, "must be a percentage between 0 and 100 (0 disables the check)")
+	}
+}
+
+// Validate checks the Config (as returned by LoadConfigParameters) and returns
+// a list of errors, which must be corrected, and warnings
+func (c *Config) Validate() ValidationIssues {
+	var v validator
+
+	// Telegram
+	switch {
+	case c.Telegram.APIKey == "" || c.Telegram.APIKey == exampleAPIKey:
+		v.errorf("telegram.apikey", "is not set. Create a bot using @BotFather on Telegram and set its API key (token), "+
+			"or provide it using telegram.apikeyfile, %s or the %s environment variable", SecretsFilename, EnvName("telegram.apikey"))
+	case !apiKeyPattern.MatchString(c.Telegram.APIKey):
+		v.errorf("telegram.apikey", "does not look like a bot API key (token) from @BotFather (expected 123456789:ABC...)")
+	}
+	if c.Telegram.ChatID == 0 {
+		v.errorf("telegram.chatid", "is not set. Send a message to your bot and find the chat id at https://api.telegram.org/bot<YourBOTToken>/getUpdates")
+	}
+	if (c.Telegram.Admin == "@" || c.Telegram.Admin == exampleAdmin) && len(c.Telegram.AdminIDs) == 0 {
+		v.errorf("telegram.admin", "is not set. Set it to your Telegram @username (or set telegram.adminids)")
+	}
+	if len(c.Telegram.Members) > 0 && c.Telegram.ChatID > 0 {
+		v.warnf("telegram.members", "only apply to group chats, but telegram.chatid is a private chat")
+	}
+
+	// Skywire Manager
+	v.address("skymanager.address", c.SkyManager.Address)
+	if c.SkyManager.DiscoveryAddress != "" {
+		if _, _, err := net.SplitHostPort(c.SkyManager.DiscoveryAddress); err != nil {
+			v.warnf("skymanager.discoveryaddress", "%q is not a valid HOST:PORT address (%v)", c.SkyManager.DiscoveryAddress, err)
+		}
+	}
+	if c.SkyManager.SkywireVersion == "" && c.SkyManager.SkywireRepo != "" {
+		if parts := strings.Split(c.SkyManager.SkywireRepo, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			v.errorf("skymanager.skywirerepo", "%q is not a GitHub repository (expected owner/repo)", c.SkyManager.SkywireRepo)
+		}
+	}
+
+	// Monitor
+	if c.Monitor.IntervalSec <= 0 {
+		v.errorf("monitor.intervalsec", "must be greater than 0")
+	}
+	if c.Monitor.HeartbeatIntMin <= 0 {
+		v.errorf("monitor.heartbeatintmin", "must be greater than 0")
+	}
+	v.notNegative("monitor.versioncheckintmin", c.Monitor.VersionCheckIntMin.Minutes())
+
+	// Wing Commander
+	if c.WingCommander.TwoFactorEnabled {
+		switch c.WingCommander.TwoFactorMode {
+		case TwoFactorModeTOTP, TwoFactorModeConfirm:
+		default:
+			v.errorf("wingcommander.twofactormode", "%q is not supported (expected %q or %q)",
+				c.WingCommander.TwoFactorMode, TwoFactorModeTOTP, TwoFactorModeConfirm)
+		}
+		if c.WingCommander.TwoFactorMode == TwoFactorModeConfirm && c.WingCommander.TwoFactorExpirySec <= 0 {
+			v.warnf("wingcommander.twofactorexpirysec", "is not set. Confirmations will expire after 60 seconds")
+		}
+	}
+	if c.WingCommander.TwoFactorSecret != "" {
+		if err := totp.ValidateSecret(c.WingCommander.TwoFactorSecret); err != nil {
+			v.errorf("wingcommander.twofactorsecret", "is not a valid base32 secret (%v)", err)
+		}
+	}
+	if c.WingCommander.UpdatePublicKey != "" {
+		if _, err := os.Stat(c.WingCommander.UpdatePublicKey); err != nil {
+			v.errorf("wingcommander.updatepublickey", "can not be read (%v)", err)
+		}
+	}
+	if c.WingCommander.UpdateAllowUnsigned {
+		v.warnf("wingcommander.updateallowunsigned", "is set, so releases may be installed without verifying their signature")
+	}
+	switch c.WingCommander.UpdateChannel {
+	case "", UpdateChannelStable, UpdateChannelPrerelease:
+	default:
+		v.errorf("wingcommander.updatechannel", "%q is not supported (expected %q or %q)",
+			c.WingCommander.UpdateChannel, UpdateChannelStable, UpdateChannelPrerelease)
+	}
+	v.notNegative("wingcommander.updatecheckintmin", c.WingCommander.UpdateCheckIntMin.Minutes())
+	if c.WingCommander.UpdateHealthTimeoutSec < 0 {
+		v.errorf("wingcommander.updatehealthtimeoutsec", "must not be negative")
+	}
+	if c.WingCommander.AnalyticsURL != "" {
+		if u, err := url.Parse(c.WingCommander.AnalyticsURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.errorf("wingcommander.analyticsurl", "%q is not an http(s) URL", c.WingCommander.AnalyticsURL)
+		}
+	} else if c.WingCommander.AnalyticsEnabled {
+		v.warnf("wingcommander.analyticsurl", "is not set, so usage statistics are only kept locally (see /stats)")
+	}
+
+	// Host
+	v.notNegative("host.checkintmin", c.Host.CheckIntMin.Minutes())
+	v.notNegative("host.maxload", c.Host.MaxLoad)
+	v.notNegative("host.maxtempc", c.Host.MaxTempC)
+	v.percentage("host.maxmempct", c.Host.MaxMemPct)
+	v.percentage("host.mindiskfreepct", c.Host.MinDiskFreePct)
+
+	// Probe
+	v.notNegative("probe.intervalsec", c.Probe.IntervalSec.Seconds())
+	for _, t := range c.Probe.Targets {
+		if _, _, err := netprobe.ParseTarget(t); err != nil {
+			v.errorf("probe.targets", "%v", err)
+		}
+	}
+	if len(c.Probe.Targets) > 0 && c.Probe.IntervalSec == 0 {
+		v.warnf("probe.intervalsec", "is 0, so the configured probe.targets will not be probed")
+	}
+
+	// Log
+	if _, err := wclog.ParseLevel(c.Log.Level); err != nil {
+		v.errorf("log.level", "%q is not supported (expected panic, fatal, error, warn, info or debug)", c.Log.Level)
+	}
+	switch c.Log.Format {
+	case "", wclog.FormatText, wclog.FormatJSON:
+	default:
+		v.errorf("log.format", "%q is not supported (expected %q or %q)", c.Log.Format, wclog.FormatText, wclog.FormatJSON)
+	}
+	if c.Log.File != "" {
+		if info, err := os.Stat(c.Log.File); err == nil && info.IsDir() {
+			v.errorf("log.file", "%q is a folder (expected the path of the log file)", c.Log.File)
+		}
+	}
+	v.notNegative("log.maxsizemb", float64(c.Log.MaxSizeMB))
+	v.notNegative("log.rotateintmin", c.Log.RotateIntMin.Minutes())
+	if c.Log.MaxBackups < 0 {
+		v.errorf("log.maxbackups", "must not be negative (set to 0 to retain all)")
+	}
+	if c.Log.RetentionMin < 0 {
+		v.errorf("log.retentionmin", "must not be negative (set to 0 to retain all)")
+	}
+
+	// Webhook
+	if c.Webhook.Enabled {
+		v.webhook(c.Webhook)
+	}
+
+	return v.issues
+}
+
+// webhook checks the parameters used to receive updates using a webhook (when it is enabled)
+func (v *validator) webhook(w WebhookParameters) {
+	if u, err := url.Parse(w.URL); err != nil || u.Scheme != "https" || u.Host == "" {
+		v.errorf("webhook.url", "%q is not an https URL. Telegram only sends updates to a public https URL", w.URL)
+	} else if !webhookPorts[u.Port()] {
+		v.warnf("webhook.url", "uses port %s, but Telegram only sends updates to ports 443, 80, 88 and 8443", u.Port())
+	}
+
+	if _, port, err := net.SplitHostPort(w.ListenAddress); err != nil {
+		v.errorf("webhook.listenaddress", "%q is not a valid IP:PORT address (%v)", w.ListenAddress, err)
+	} else if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		v.errorf("webhook.listenaddress", "%q does not include a valid port (1-65535)", w.ListenAddress)
+	}
+
+	if (w.CertFile == "") != (w.KeyFile == "") {
+		v.errorf("webhook.certfile", "and webhook.keyfile must both be set to serve the webhook using TLS, or both be empty (i.e. behind a reverse proxy)")
+	}
+	for _, f := range [][2]string{{"webhook.certfile", w.CertFile}, {"webhook.keyfile", w.KeyFile}} {
+		if f[1] == "" {
+			continue
+		}
+		if _, err := os.Stat(f[1]); err != nil {
+			v.errorf(f[0], "can not be read (%v)", err)
+		}
+	}
+
+	for _, f := range [][2]string{{"webhook.secretpath", w.SecretPath}, {"webhook.secrettoken", w.SecretToken}} {
+		if f[1] != "" && !webhookSecretPattern.MatchString(f[1]) {
+			v.errorf(f[0], "must be 1-256 characters long and only contain A-Z, a-z, 0-9, _ and -")
+		}
+	}
+	if w.SecretPath == "" && w.SecretToken == "" {
+		v.errorf("webhook.secrettoken", "(or webhook.secretpath) must be set, otherwise anyone able to reach webhook.url can send updates to the bot")
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package wcconfig
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig returns a Config which passes validation
+func validConfig() Config {
+	var c Config
+	c.Telegram.APIKey = "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw"
+	c.Telegram.ChatID = 123456789
+	c.Telegram.Admin = "@TESTUSER"
+	c.SkyManager.Address = "127.0.0.1:8000"
+	c.SkyManager.DiscoveryAddress = "testnet.skywire.skycoin.com:8001"
+	c.SkyManager.SkywireRepo = "skycoin/skywire"
+	c.Monitor.IntervalSec = 10 * time.Second
+	c.Monitor.HeartbeatIntMin = 120 * time.Minute
+	c.WingCommander.UpdateChannel = UpdateChannelStable
+	c.Host.MaxMemPct = 90
+	c.Host.MinDiskFreePct = 10
+	return c
+}
+
+// enableWebhook enables receiving updates using a valid webhook configuration
+func enableWebhook(c *Config) {
+	c.Webhook.Enabled = true
+	c.Webhook.URL = "https://example.com/wcbot"
+	c.Webhook.ListenAddress = "127.0.0.1:8443"
+	c.Webhook.SecretToken = "d41d8cd98f00b204e9800998ecf8427e"
+}
+
+func Test_Validate_Valid(t *testing.T) {
+	c := validConfig()
+	if issues := c.Validate(); len(issues) != 0 {
+		t.Errorf("Expected: no issues, got:\n%s", issues)
+	}
+}
+
+func Test_Validate_Webhook(t *testing.T) {
+	c := validConfig()
+	enableWebhook(&c)
+	c.Webhook.ListenAddress = ":8443"
+	if issues := c.Validate(); len(issues) != 0 {
+		t.Errorf("Expected: no issues, got:\n%s", issues)
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		modify   func(c *Config)
+		field    string
+		severity string
+	}{
+		{"empty apikey", func(c *Config) { c.Telegram.APIKey = "" }, "telegram.apikey", SeverityError},
+		{"example apikey", func(c *Config) { c.Telegram.APIKey = "BOT-APIKEY-HERE" }, "telegram.apikey", SeverityError},
+		{"malformed apikey", func(c *Config) { c.Telegram.APIKey = "ABC123" }, "telegram.apikey", SeverityError},
+		{"zero chatid", func(c *Config) { c.Telegram.ChatID = 0 }, "telegram.chatid", SeverityError},
+		{"no admin", func(c *Config) { c.Telegram.Admin = "@" }, "telegram.admin", SeverityError},
+		{"private chat members", func(c *Config) { c.Telegram.Members = []int{1} }, "telegram.members", SeverityWarning},
+		{"address without port", func(c *Config) { c.SkyManager.Address = "127.0.0.1" }, "skymanager.address", SeverityError},
+		{"address with bad port", func(c *Config) { c.SkyManager.Address = "127.0.0.1:http" }, "skymanager.address", SeverityError},
+		{"address out of range", func(c *Config) { c.SkyManager.Address = "127.0.0.1:70000" }, "skymanager.address", SeverityError},
+		{"address without host", func(c *Config) { c.SkyManager.Address = ":8000" }, "skymanager.address", SeverityError},
+		{"bad discovery address", func(c *Config) { c.SkyManager.DiscoveryAddress = "testnet" }, "skymanager.discoveryaddress", SeverityWarning},
+		{"bad skywire repo", func(c *Config) { c.SkyManager.SkywireRepo = "skywire" }, "skymanager.skywirerepo", SeverityError},
+		{"zero intervalsec", func(c *Config) { c.Monitor.IntervalSec = 0 }, "monitor.intervalsec", SeverityError},
+		{"zero heartbeatintmin", func(c *Config) { c.Monitor.HeartbeatIntMin = 0 }, "monitor.heartbeatintmin", SeverityError},
+		{"negative versioncheckintmin", func(c *Config) { c.Monitor.VersionCheckIntMin = -time.Minute }, "monitor.versioncheckintmin", SeverityError},
+		{"bad twofactormode", func(c *Config) {
+			c.WingCommander.TwoFactorEnabled = true
+			c.WingCommander.TwoFactorMode = "sms"
+		}, "wingcommander.twofactormode", SeverityError},
+		{"confirm without expiry", func(c *Config) {
+			c.WingCommander.TwoFactorEnabled = true
+			c.WingCommander.TwoFactorMode = TwoFactorModeConfirm
+		}, "wingcommander.twofactorexpirysec", SeverityWarning},
+		{"bad twofactorsecret", func(c *Config) { c.WingCommander.TwoFactorSecret = "not base32!" }, "wingcommander.twofactorsecret", SeverityError},
+		{"missing updatepublickey", func(c *Config) { c.WingCommander.UpdatePublicKey = "testdata/does-not-exist.asc" }, "wingcommander.updatepublickey", SeverityError},
+		{"bad updatechannel", func(c *Config) { c.WingCommander.UpdateChannel = "nightly" }, "wingcommander.updatechannel", SeverityError},
+		{"bad analyticsurl", func(c *Config) { c.WingCommander.AnalyticsURL = "stats.example.com" }, "wingcommander.analyticsurl", SeverityError},
+		{"analytics without url", func(c *Config) { c.WingCommander.AnalyticsEnabled = true }, "wingcommander.analyticsurl", SeverityWarning},
+		{"bad maxmempct", func(c *Config) { c.Host.MaxMemPct = 150 }, "host.maxmempct", SeverityError},
+		{"negative maxload", func(c *Config) { c.Host.MaxLoad = -1 }, "host.maxload", SeverityError},
+		{"bad probe target", func(c *Config) {
+			c.Probe.IntervalSec = time.Minute
+			c.Probe.Targets = []string{":8000"}
+		}, "probe.targets", SeverityError},
+		{"probe disabled", func(c *Config) { c.Probe.Targets = []string{"192.168.0.2:8000"} }, "probe.intervalsec", SeverityWarning},
+		{"http webhook url", func(c *Config) {
+			enableWebhook(c)
+			c.Webhook.URL = "http://example.com/wcbot"
+		}, "webhook.url", SeverityError},
+		{"webhook port", func(c *Config) {
+			enableWebhook(c)
+			c.Webhook.URL = "https://example.com:8080/wcbot"
+		}, "webhook.url", SeverityWarning},
+		{"bad webhook listenaddress", func(c *Config) {
+			enableWebhook(c)
+			c.Webhook.ListenAddress = "8443"
+		}, "webhook.listenaddress", SeverityError},
+		{"webhook cert without key", func(c *Config) {
+			enableWebhook(c)
+			c.Webhook.CertFile = "testdata/configtest-allparams.toml"
+		}, "webhook.certfile", SeverityError},
+		{"missing webhook keyfile", func(c *Config) {
+			enableWebhook(c)
+			c.Webhook.CertFile = "testdata/configtest-allparams.toml"
+			c.Webhook.KeyFile = "testdata/does-not-exist.key"
+		}, "webhook.keyfile", SeverityError},
+		{"bad webhook secrettoken", func(c *Config) {
+			enableWebhook(c)
+			c.Webhook.SecretToken = "not a token!"
+		}, "webhook.secrettoken", SeverityError},
+		{"webhook without secrets", func(c *Config) {
+			enableWebhook(c)
+			c.Webhook.SecretToken = ""
+		}, "webhook.secrettoken", SeverityError},
+	}
+
+	for _, tc := range testCases {
+		c := validConfig()
+		tc.modify(&c)
+		issues := c.Validate()
+		if len(issues) != 1 {
+			t.Errorf("%s: Expected: 1 issue, got:\n%s", tc.name, issues)
+			continue
+		}
+		if issues[0].Field != tc.field || issues[0].Severity != tc.severity {
+			t.Errorf("%s: Unexpected issue: %s", tc.name, issues[0])
+		}
+		if issues.HasErrors() != (tc.severity == SeverityError) {
+			t.Errorf("%s: Unexpected HasErrors: %v", tc.name, issues.HasErrors())
+		}
+	}
+}
+
+func Test_ValidationIssues_String(t *testing.T) {
+	c := validConfig()
+	c.Telegram.ChatID = 0
+	c.Monitor.IntervalSec = 0
+
+	s := c.Validate().String()
+	if !strings.HasPrefix(s, "error: telegram.chatid: is not set.") || !strings.Contains(s, "\nerror: monitor.intervalsec: must be greater than 0") {
+		t.Errorf("Unexpected issues:\n%s", s)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package wcconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteKey writes the value of the configuration parameter identified by key in c to the
+// configuration file at path. Only the line holding the parameter is changed, so the rest of
+// the file (including comments) is preserved. If the parameter is not set within the file it
+// is added to its section, following its commented out default if there is one.
+// When profile is not empty the parameter is written to the section of the profile
+// (see applyProfile) instead.
+func WriteKey(path, profile string, c Config, key string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	text, err := setKeys(string(data), profile, c, []string{key})
+	if err != nil {
+		return err
+	}
+
+	// Write the file atomically so a partially written file is never reloaded
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".config.toml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(text); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// setKeys sets the configuration parameters identified by keys to their value in c
+// within the provided TOML text (within the section of profile, if provided)
+func setKeys(text, profile string, c Config, keys []string) (string, error) {
+	lines := strings.Split(text, "\n")
+	for _, key := range keys {
+		value, err := c.tomlValue(key)
+		if err != nil {
+			return "", err
+		}
+		section, name := splitKey(key)
+		if profile != "" {
+			section = profileSection(profile) + "." + section
+		}
+		lines = setTOMLKey(lines, section, name, value)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// splitKey splits a configuration key into its section and name
+func splitKey(key string) (section, name string) {
+	if i := strings.Index(key, "."); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
+
+// tomlSection returns the name of the section declared by the provided line, if any
+func tomlSection(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[") || strings.HasPrefix(line, "[[") {
+		return "", false
+	}
+	end := strings.Index(line, "]")
+	if end < 0 {
+		return "", false
+	}
+	return strings.ToLower(strings.TrimSpace(line[1:end])), true
+}
+
+// tomlKeyName returns the name of the key assigned by the provided line (after removing the
+// provided comment prefix), if any
+func tomlKeyName(line, prefix string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, prefix) {
+		return "", false
+	}
+	line = strings.TrimSpace(strings.TrimPrefix(line, prefix))
+	if strings.HasPrefix(line, "#") {
+		return "", false
+	}
+	eq := strings.Index(line, "=")
+	if eq <= 0 {
+		return "", false
+	}
+	return strings.ToLower(strings.TrimSpace(line[:eq])), true
+}
+
+// inlineComment returns the trailing comment (including leading whitespace) of a key/value line
+func inlineComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == '#':
+			j := i
+			for j > 0 && (line[j-1] == ' ' || line[j-1] == '\t') {
+				j--
+			}
+			return line[j:]
+		}
+	}
+	return ""
+}
+
+// setTOMLKey sets name to value within section of the provided TOML lines
+func setTOMLKey(lines []string, section, name, value string) []string {
+	current := ""
+	start, end, commented := -1, len(lines), -1
+	for i, line := range lines {
+		if s, ok := tomlSection(line); ok {
+			if current == section {
+				end = i
+				break
+			}
+			current = s
+			if s == section {
+				start = i
+			}
+			continue
+		}
+		if current != section {
+			continue
+		}
+		if k, ok := tomlKeyName(line, ""); ok && k == name {
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			lines[i] = fmt.Sprintf("%s%s = %s%s", indent, name, value, inlineComment(line))
+			return lines
+		}
+		if k, ok := tomlKeyName(line, "#"); ok && k == name && commented < 0 {
+			commented = i
+		}
+	}
+
+	entry := fmt.Sprintf("%s = %s", name, value)
+	if start < 0 {
+		// Add the section to the end of the file, retaining any trailing newline
+		if n := len(lines); n > 0 && lines[n-1] == "" {
+			return append(lines[:n-1], "", "["+section+"]", entry, "")
+		}
+		return append(lines, "", "["+section+"]", entry)
+	}
+
+	at := start + 1
+	if commented >= 0 && commented < end {
+		at = commented + 1
+	}
+	lines = append(lines, "")
+	copy(lines[at+1:], lines[at:])
+	lines[at] = entry
+	return lines
+}
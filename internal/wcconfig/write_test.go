@@ -0,0 +1,84 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package wcconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const writeTestConfig = `# Wing Commander configuration
+[telegram]
+apikey = "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw" # from @BotFather
+
+[monitor]
+# Poll interval (seconds)
+intervalsec = 10 # seconds
+# Heartbeat interval (minutes)
+#heartbeatintmin = 120
+`
+
+func Test_WriteKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wcconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.toml")
+	if err := ioutil.WriteFile(path, []byte(writeTestConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := validConfig()
+	c.Monitor.IntervalSec = 30 * time.Second
+	c.Monitor.HeartbeatIntMin = 60 * time.Minute
+	c.Probe.Targets = []string{"192.168.0.2:8000"}
+	for _, key := range []string{"monitor.intervalsec", "monitor.heartbeatintmin", "probe.targets"} {
+		if err := WriteKey(path, "", c, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `# Wing Commander configuration
+[telegram]
+apikey = "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw" # from @BotFather
+
+[monitor]
+# Poll interval (seconds)
+intervalsec = 30 # seconds
+# Heartbeat interval (minutes)
+#heartbeatintmin = 120
+heartbeatintmin = 60
+
+[probe]
+targets = ["192.168.0.2:8000"]
+`
+	if string(data) != expect {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expect, data)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected: the file mode to be preserved, got %v", info.Mode())
+	}
+
+	// The written file must load
+	loaded, err := LoadConfigParameters("config", dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Monitor.IntervalSec != 30*time.Second || loaded.Monitor.HeartbeatIntMin != time.Hour || len(loaded.Probe.Targets) != 1 {
+		t.Errorf("Unexpected loaded config: %s", loaded.String())
+	}
+}
@@ -10,8 +10,6 @@ const (
 	BotVersion    = "v1.1.1"
 	BotAppVersion = "Wing Commander " + BotVersion
 
-	AnalyticsID = "UA-125471033-1"
-
 	AppInstanceID = "wing-commander-84F95320-8C2D-4236-9252-A322F01B91A7"
 
 	MsgAppInstErr = "Another instance of Wing Commander has been detected running on this system.\n\n" +
@@ -19,15 +17,19 @@ const (
 		"   pgrep wcbot | xargs kill\n\n" +
 		"Exiting\n"
 
-	// ScriptPath defines the path to the Scripts folder
-	ScriptPath = "/src/github.com/BigOokie/skywire-wing-commander/scripts/"
-
 	MsgCmdLineHelp = "Wing Commander Help\n" +
 		"Command line flags:\n" +
-		"  -v       display application version information.\n" +
-		"  -config  display application configuration information.\n" +
-		"  -help    display this message.\n" +
-		"  -about   display information about the application and its author.\n\n\n" +
+		"  -v               display application version information.\n" +
+		"  -config          display application configuration information.\n" +
+		"  -validateconfig  check the configuration for errors and exit.\n" +
+		"  -setup           interactively create the configuration file (~/.wingcommander/config.toml) and exit.\n" +
+		"  -configfile      path of the configuration file (default ~/.wingcommander/config.toml).\n" +
+		"  -profile         name of the profile to use within the configuration file ([profiles.<name>.<section>] sections).\n" +
+		"  -help            display this message.\n" +
+		"  -about           display information about the application and its author.\n" +
+		"  -<key> <value>   override a configuration parameter (i.e. -skymanager.address 127.0.0.1:8000).\n" +
+		"                   Parameters can also be set by environment variable (i.e. WINGCOMMANDER_SKYMANAGER_ADDRESS).\n" +
+		"                   Precedence: command line, environment, config.toml, defaults.\n\n\n" +
 		MsgHelpShort
 
 	// Bot command messages:
@@ -37,10 +39,21 @@ const (
 		"- /about - show information and credits about my creator and any contributors.\n" +
 		"- /status - request a status update. This provides the same information as the Heartbeat.\n" +
 		"- /showconfig - display runtime configuration (from config.toml).\n" +
+		"- /get [key] - show a setting (i.e. /get monitor.intervalsec), or all settings.\n" +
+		"- /set <key> <value> - change a setting (such as the poll or heartbeat interval) while running, and optionally save it to config.toml.\n" +
+		"- /loglevel [level] - show or change the log level (debug, info, warn or error) while running.\n" +
+		"- /logs [n] [level] - show the last n (default 20) log entries, or those at or above a level (i.e. /logs warn). Long logs are sent as a file.\n" +
 		"- /start - start activly monitoring your Skyminer. Once started, notifications will be sent to you for events that occur. A Heartbeat will also be initiated to let you know if the bot and the Miner are still running.\n" +
 		"- /stop - stop monitoring your Skyminer. Once stopped, I won't send any more notifications.\n" +
-		"- /checkupdate - check GitHub for new updates.\n" +
-		"- /update - attempt to update *Wing Commander* to the latest version from GitHub source.\n" +
+		"- /checkupdate - check GitHub for new updates and show the release notes. New releases are also checked for automatically.\n" +
+		"- /update - update *Wing Commander* to the latest release from GitHub. The release is verified before it is installed, and Wing Commander is then restarted.\n" +
+		"- /nodes - select a Node to restart it, or to start/stop/restart its apps. All Nodes can also be rebooted. Actions must be confirmed.\n" +
+		"- /host - show the CPU load, memory, disk, temperature and uptime of the host I am running on. You will be alerted if any exceed their configured limits.\n" +
+		"- /probes - show the reachability, latency and loss of the Nodes configured to be probed on the LAN. You will be alerted when they become unreachable.\n" +
+		"- /versions - show the Skywire version run by each Node, and the latest Skywire release. You will be alerted if Nodes are outdated or running different versions.\n" +
+		"- /audit [n] - show the last n (default 10) entries of the audit log of commands issued to the bot.\n" +
+		"- /stats - show how often each command has been used and has failed since I started, and how many messages were delivered late. Statistics are kept locally unless you opt in to sharing them.\n" +
+		"- /2fasetup - provision two factor confirmation (TOTP) for protected commands such as /stop and /update.\n" +
 		"- /uptime - dynamically generate a link to the Skywirenc.com site to check uptime for locally connected Nodes.\n" +
 		"- /whitelist - provides a link to the official Skycoin Whitelist site. Users must login." +
 		"- /menu - request the menu keyboard to be displayed."
@@ -49,7 +62,8 @@ const (
 		MsgHelpShort +
 		"\n" +
 		"\n" +
-		"Note: I am bound to this chat. I will only respond to commands from my configured Admin (%s)."
+		"Note: I am bound to this chat. I will only respond to commands from my configured Admin (%s) once bound to their Telegram user ID, " +
+		"and (within a group chat) from configured members. Within a group, address commands to me directly or mention me (i.e. `@mybot status`)."
 
 	// About cmd message
 	MsgAbout = "*Wing Commander (" + BotVersion + ")*\n" +
@@ -66,6 +80,109 @@ const (
 		"*Donations most welcome* 👍\n" +
 		"*Skycoin:* ES5LccJDhBCK275APmW9tmQNEgiYwTFKQF"
 
+	// Admin binding messages
+	MsgAdminBindHint = "Wing Commander is not yet bound to your Telegram user ID. Send /start to complete Admin registration."
+	MsgAdminBound    = "Admin %s is now bound to Telegram user ID %d.\n\n" +
+		"To make this permanent, add the following to the [telegram] section of your config.toml:\n\n" +
+		"adminids = [%d]"
+	MsgAdminOnly   = "Sorry, '/%s' is restricted to Admins."
+	MsgUnknownUser = "⚠️ Ignored command from unknown Telegram user %s (ID: %d): %q"
+
+	// Two factor confirmation messages
+	MsgTwoFactorCodeRequired       = "'/%[1]s' requires two factor confirmation. Send: /%[1]s <code> using the code from your authenticator app."
+	MsgTwoFactorCodeInvalid        = "Invalid (or already used) two factor code. '/%s' was not executed."
+	MsgTwoFactorNotProvisioned     = "Two factor confirmation is enabled but has not been provisioned. Use /2fasetup or set twofactorsecret in config.toml."
+	MsgTwoFactorConfigured         = "Two factor confirmation is provisioned using the twofactorsecret from config.toml."
+	MsgTwoFactorAlreadyProvisioned = "Two factor confirmation has already been provisioned. " +
+		"To provision again, remove twofactorsecret from ~/.wingcommander/state.json and restart Wing Commander."
+	MsgTwoFactorProvisioned = "Two factor confirmation provisioned. Add it to your authenticator app by opening (or creating a QR code from) this URI:\n\n%s\n\n" +
+		"Secret (for manual entry): %s\n\nThis will only be shown once. Delete this message once done."
+	MsgConfirmCommand   = "Confirm %s?\n\nThis request expires in %v."
+	MsgConfirmNotFound  = "This confirmation request has expired or is unknown."
+	MsgConfirmWrongUser = "Only the user who requested the command may confirm or cancel it."
+	MsgConfirmCancelled = "'/%s' cancelled."
+
+	// Node control messages
+	MsgNoConnectedNodes  = "No Nodes are connected to the Manager."
+	MsgSelectNode        = "*Select a Node:*"
+	MsgSelectNodeAction  = "*Node:* %s\n\nSelect an action:"
+	MsgNodeRestarted     = "*Node restart requested:* %s"
+	MsgNodesRebooted     = "*Reboot requested for %d Nodes.*"
+	MsgNodeAppControlled = "*App %s %s on Node:* %s"
+	MsgNodeControlFailed = "⚠️ Node control failed: %v"
+
+	// Skywire version messages
+	MsgSkywireVersions         = "Skywire versions (latest release: %s):\n\n%s"
+	MsgSkywireVersionsAlert    = "⚠️ Skywire version check:\n\n%s\n\nUse /versions for details."
+	MsgSkywireVersionsMixed    = "Nodes are running different versions: %s"
+	MsgSkywireVersionsOutdated = "%d Node(s) are running a version older than %s"
+	MsgSkywireLatestUnknown    = "unknown"
+
+	// Host monitoring messages
+	MsgHost          = "Host %s:\n\n%s"
+	MsgHostAlert     = "⚠️ Host %s resource alert:\n%s"
+	MsgHostRecovered = "✅ Host %s recovered: %s"
+	MsgHostFailed    = "⚠️ Failed to collect host metrics: %v"
+
+	// Network probe messages
+	MsgProbeUnreachable         = "⚠️ %s is unreachable, but Node %s is connected to the Manager: %v"
+	MsgProbeUnreachableNotFound = "⚠️ %s is unreachable (and not connected to the Manager): %v"
+	MsgProbeReachable           = "✅ %s is reachable again (%v)"
+	MsgProbes                   = "Network probes:\n\n%s"
+	MsgProbesDisabled           = "No network probe targets are configured (probe.targets in config.toml)."
+
+	// Configuration reload messages
+	MsgConfigReloaded        = "⚙️ config.toml reloaded.%s"
+	MsgConfigApplied         = "\n\nApplied:\n%s"
+	MsgConfigRestartRequired = "\n\nRequires a restart to apply (unchanged until then):\n%s"
+	MsgConfigRejected        = "⚠️ Changes to config.toml were rejected (the current configuration remains in use):\n%s"
+
+	// Runtime settings messages
+	MsgGetUsage           = "Usage: /get <key> (i.e. /get monitor.intervalsec), or /get for all settings."
+	MsgGet                = "Settings (* can be changed using /set):\n\n%s"
+	MsgSetUsage           = "Usage: /set <key> <value> (i.e. /set monitor.heartbeatintmin 60). Durations are in the unit of the setting (seconds or minutes) or a duration (i.e. 90s), and lists are comma separated."
+	MsgSetNotReloadable   = "%s can not be changed while running. Edit config.toml and restart Wing Commander."
+	MsgSetInvalid         = "⚠️ %s was not changed: %v"
+	MsgSetUnchanged       = "%s is already %s."
+	MsgSetApplied         = "✅ %s: %s → %s\n\nApplied until Wing Commander restarts or its configuration is reloaded."
+	MsgSetAppliedSaveHint = "✅ %s: %s → %s\n\nApplied until Wing Commander restarts or config.toml is reloaded (when it is edited, or on SIGHUP). Save it to config.toml to keep it."
+	MsgLogLevel           = "The log level is %s. Change it using /loglevel <level> (debug, info, warn or error)."
+	MsgLogsUsage          = "Usage: /logs [n] [level] (i.e. /logs 50, /logs error or /logs 10 warn)."
+	MsgLogsEmpty          = "No matching log entries. Only entries at or above the log level (see /loglevel) are retained."
+	MsgLogs               = "Last %d log entries:\n\n%s"
+	MsgLogsDocument       = "Last %d log entries"
+	MsgSaveSetting        = "💾 %s saved to %s."
+	MsgSaveSettingFailed  = "⚠️ Failed to save %s to config.toml: %v"
+
+	// Audit log messages
+	MsgAudit           = "Audit log (last %d entries):\n\n%s"
+	MsgAuditEmpty      = "The audit log is empty."
+	MsgAuditUsage      = "Usage: /audit [n] - where n is the number of entries to show."
+	MsgAuditReadFailed = "⚠️ Failed to read the audit log: %v"
+
+	// Usage statistics messages
+	MsgStats          = "Usage statistics since %s (up %v):\n\nCommands: %s\nFailed: %s\n\n%s\n\n%s"
+	MsgStatsLocalOnly = "Statistics are only kept locally. Set wingcommander.analyticsenabled and wingcommander.analyticsurl to share them."
+	MsgStatsRemote    = "Statistics are shared with wingcommander.analyticsurl (sent %d, failed %d, dropped %d)."
+	MsgStatsMessages  = "Messages which could not be delivered when sent: %d delivered late, %d waiting, %d dropped."
+	MsgSendDelayed    = "⚠️ %d messages could not be delivered when sent (i.e. as Telegram could not be reached) and were delivered up to %v late."
+
+	// Update messages
+	MsgUpdateAlreadyLatest     = "*Already up to date:* %s is the latest version."
+	MsgUpdateAvailable         = "*Update available:* %s → %s\n\nDownloading and verifying..."
+	MsgUpdateInstalled         = "*Update to %s installed.* Restarting..."
+	MsgUpdateFailed            = "⚠️ Update failed: %v"
+	MsgUpdateCheckFailed       = "⚠️ Update check failed: %v"
+	MsgUpdateNotify            = "⬆️ Wing Commander update available: %s → %s\n\nRelease notes:\n%s"
+	MsgNoReleaseNotes          = "(none provided)"
+	MsgSkipVersion             = "You will not be notified about %s again. Use /update to install it anyway."
+	MsgSkipVersionUsage        = "Usage: /skipversion <version>"
+	MsgUpdateHealthCheckFailed = "⚠️ Update to %s failed its health check: %v\n\n%s"
+	MsgRolledBack              = "The previous version (%s) has been restored and is still running."
+	MsgRollbackFailed          = "‼ Failed to restore the previous version: %v"
+	MsgUpgradeRolledBack       = "⚠️ Update from %s to %s was rolled back: %s\n\nThe previous version has been restored."
+	MsgRestartFailed           = "⚠️ Restart after update failed: %v\n\nThe new version is installed. Please restart Wing Commander manually."
+
 	MsgShowConfig = "Wing Commander Configuration\n" +
 		"```\n%s\n```\n"
 
@@ -96,4 +213,5 @@ const (
 
 	// OS Interrupt Signals
 	MsgOSInteruptSig = "*Wing Commander* OS Interupt Signal Received. Exiting."
+	MsgShuttingDown  = "*Wing Commander is shutting down* (%s)"
 )
@@ -0,0 +1,110 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package wclog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RecentSize is the number of recent log entries retained in memory (see Recent)
+const RecentSize = 1000
+
+// Entry is a log entry retained in memory
+type Entry struct {
+	Time    time.Time
+	Level   log.Level
+	Message string
+	Fields  log.Fields
+}
+
+// String formats the entry as a line of text
+func (e Entry) String() string {
+	s := fmt.Sprintf("%s %-5s %s", e.Time.Format("2006-01-02 15:04:05"), strings.ToUpper(e.Level.String()), e.Message)
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s += fmt.Sprintf(" %s=%v", k, e.Fields[k])
+	}
+	return s
+}
+
+// ring is a logrus hook which retains the most recent log entries
+type ring struct {
+	m       sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// recent retains the most recent entries written to the application log
+var recent = newRing(RecentSize)
+
+func init() {
+	log.AddHook(recent)
+}
+
+// newRing creates a ring which retains up to size entries
+func newRing(size int) *ring {
+	return &ring{entries: make([]Entry, size)}
+}
+
+// Levels returns the levels retained, which are all levels (that pass the log level)
+func (r *ring) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire retains the entry
+func (r *ring) Fire(e *log.Entry) error {
+	fields := make(log.Fields, len(e.Data))
+	for k, v := range e.Data {
+		fields[k] = v
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.entries[r.next] = Entry{Time: e.Time, Level: e.Level, Message: e.Message, Fields: fields}
+	r.next = (r.next + 1) % len(r.entries)
+	r.full = r.full || r.next == 0
+	return nil
+}
+
+// recent returns up to n (all if n <= 0) of the most recent entries at or above level, oldest first
+func (r *ring) recent(n int, level log.Level) []Entry {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	ordered := r.entries[:r.next]
+	if r.full {
+		ordered = append(append([]Entry{}, r.entries[r.next:]...), r.entries[:r.next]...)
+	}
+
+	var result []Entry
+	for i := len(ordered) - 1; i >= 0 && (n <= 0 || len(result) < n); i-- {
+		if ordered[i].Level <= level {
+			result = append(result, ordered[i])
+		}
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+// Recent returns up to n (all if n <= 0) of the most recent log entries at or above level
+// (i.e. log.WarnLevel returns warnings and errors), oldest first. Only entries which passed
+// the log level when they were logged are retained.
+func Recent(n int, level log.Level) []Entry {
+	return recent.recent(n, level)
+}
@@ -0,0 +1,53 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package wclog
+
+import (
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func Test_Ring(t *testing.T) {
+	r := newRing(3)
+	at := time.Date(2018, 10, 19, 12, 0, 0, 0, time.UTC)
+	for i, level := range []log.Level{log.InfoLevel, log.ErrorLevel, log.DebugLevel, log.WarnLevel, log.InfoLevel} {
+		r.Fire(&log.Entry{Time: at.Add(time.Duration(i) * time.Second), Level: level, Message: level.String(),
+			Data: log.Fields{"n": i}})
+	}
+
+	testCases := []struct {
+		n      int
+		level  log.Level
+		expect []string
+	}{
+		{0, log.DebugLevel, []string{"debug", "warning", "info"}},
+		{2, log.DebugLevel, []string{"warning", "info"}},
+		{0, log.WarnLevel, []string{"warning"}},
+		{0, log.ErrorLevel, nil},
+	}
+	for _, tc := range testCases {
+		entries := r.recent(tc.n, tc.level)
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Message)
+		}
+		if len(got) != len(tc.expect) {
+			t.Errorf("%d %v: Expected: %v, got %v", tc.n, tc.level, tc.expect, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.expect[i] {
+				t.Errorf("%d %v: Expected: %v, got %v", tc.n, tc.level, tc.expect, got)
+				break
+			}
+		}
+	}
+
+	if s := r.recent(1, log.DebugLevel)[0].String(); s != "2018-10-19 12:00:04 INFO  info n=4" {
+		t.Errorf("Unexpected entry: %q", s)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package wclog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the format of the timestamp appended to the name of a rotated log file
+const backupTimeFormat = "20060102-150405.000"
+
+// rotatingWriter is an io.WriteCloser which appends to a log file, and rotates it once it
+// exceeds its maximum size or age. Rotated files are renamed with a timestamp suffix
+// (i.e. `wcbot.log.20181019-150405.000`) and removed once they exceed the retention limits.
+type rotatingWriter struct {
+	path       string
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+	maxAge     time.Duration
+	now        func() time.Time
+	// errOut is used to report a failure to rotate the log file
+	errOut io.Writer
+
+	m       sync.Mutex
+	file    *os.File
+	size    int64
+	started time.Time
+	// failed is set once a rotation has failed (until one succeeds), so the failure is only reported once
+	failed bool
+}
+
+// newRotatingWriter opens the log file configured by opts, creating it (and its folder) if needed
+func newRotatingWriter(opts Options) (*rotatingWriter, error) {
+	w := &rotatingWriter{
+		path:       opts.File,
+		maxSize:    opts.MaxSize,
+		interval:   opts.RotateInterval,
+		maxBackups: opts.MaxBackups,
+		maxAge:     opts.MaxAge,
+		now:        time.Now,
+		errOut:     os.Stderr,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	w.removeBackups()
+	return w, nil
+}
+
+// open opens (or creates) the log file for appending
+func (w *rotatingWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	w.file = f
+	w.size = info.Size()
+	w.started = w.now()
+	if w.size > 0 {
+		// The time the existing file was started is unknown, so its last change is used
+		w.started = info.ModTime()
+	}
+	return nil
+}
+
+// Write writes p to the log file, rotating it first if required
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.shouldRotate(int64(len(p))) {
+		w.rotate()
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// shouldRotate determines if the log file must be rotated before n bytes are written
+func (w *rotatingWriter) shouldRotate(n int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.maxSize > 0 && w.size+n > w.maxSize {
+		return true
+	}
+	return w.interval > 0 && w.now().Sub(w.started) >= w.interval
+}
+
+// rotate renames the current log file with a timestamp suffix, opens a new one
+// and removes backups which exceed the retention limits. If the log file can not be
+// rotated, logging continues to the current log file (see reopen).
+func (w *rotatingWriter) rotate() {
+	if w.file != os.Stderr {
+		if err := w.file.Close(); err != nil {
+			w.reopen(err)
+			return
+		}
+	}
+	if err := os.Rename(w.path, w.path+"."+w.now().Format(backupTimeFormat)); err != nil {
+		w.reopen(err)
+		return
+	}
+	if err := w.open(); err != nil {
+		w.reopen(err)
+		return
+	}
+	w.failed = false
+	w.removeBackups()
+}
+
+// reopen reports the error which caused a rotation to fail (unless it has already been
+// reported) and reopens the log file, falling back to stderr if it can not be opened.
+// Rotation is attempted again when the log file is next due to be rotated.
+func (w *rotatingWriter) reopen(err error) {
+	if !w.failed {
+		w.failed = true
+		fmt.Fprintf(w.errOut, "Failed to rotate the log file %s: %v\n", w.path, err)
+	}
+	if err := w.open(); err != nil {
+		w.file = os.Stderr
+		w.size = 0
+		w.started = w.now()
+	}
+}
+
+// backups returns the paths of the rotated log files, oldest first
+func (w *rotatingWriter) backups() []string {
+	paths, _ := filepath.Glob(w.path + ".*")
+	var backups []string
+	for _, p := range paths {
+		suffix := p[len(w.path)+1:]
+		if _, err := time.Parse(backupTimeFormat, suffix); err == nil {
+			backups = append(backups, p)
+		}
+	}
+	sort.Strings(backups)
+	return backups
+}
+
+// removeBackups removes rotated log files beyond the maximum number of backups,
+// or which are older than the maximum age
+func (w *rotatingWriter) removeBackups() {
+	backups := w.backups()
+	for i, p := range backups {
+		remove := w.maxBackups > 0 && i < len(backups)-w.maxBackups
+		if !remove && w.maxAge > 0 {
+			if info, err := os.Stat(p); err == nil && w.now().Sub(info.ModTime()) > w.maxAge {
+				remove = true
+			}
+		}
+		if remove {
+			os.Remove(p)
+		}
+	}
+}
+
+// Close closes the log file
+func (w *rotatingWriter) Close() error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.file == os.Stderr {
+		return nil
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,153 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package wclog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestWriter creates a rotatingWriter within a new temporary folder, whose clock is controlled by the test
+func newTestWriter(t *testing.T, opts Options) (*rotatingWriter, *time.Time, string) {
+	dir, err := ioutil.TempDir("", "wclog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.File = filepath.Join(dir, "wcbot.log")
+	now := time.Date(2018, 10, 19, 12, 0, 0, 0, time.UTC)
+	w, err := newRotatingWriter(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.now = func() time.Time { return now }
+	w.started = now
+	return w, &now, dir
+}
+
+func Test_RotatingWriter_Size(t *testing.T) {
+	w, now, dir := newTestWriter(t, Options{MaxSize: 10, MaxBackups: 2})
+	defer os.RemoveAll(dir)
+	defer w.Close()
+
+	for i, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		*now = now.Add(time.Second)
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "fourth\n" {
+		t.Errorf("Expected: the log file to contain the last line, got %q", data)
+	}
+	// The oldest backup ("first") is removed, as only 2 backups are retained
+	backups := w.backups()
+	if len(backups) != 2 || !strings.HasSuffix(backups[0], ".20181019-120003.000") {
+		t.Fatalf("Unexpected backups: %v", backups)
+	}
+	if data, _ := ioutil.ReadFile(backups[0]); string(data) != "second\n" {
+		t.Errorf("Expected: the oldest retained backup to contain the second line, got %q", data)
+	}
+}
+
+func Test_RotatingWriter_Age(t *testing.T) {
+	w, now, dir := newTestWriter(t, Options{RotateInterval: time.Hour, MaxAge: 90 * time.Minute})
+	defer os.RemoveAll(dir)
+	defer w.Close()
+
+	w.Write([]byte("first\n"))
+	*now = now.Add(30 * time.Minute)
+	w.Write([]byte("second\n"))
+	if len(w.backups()) != 0 {
+		t.Errorf("Expected: no rotation within the interval, got %v", w.backups())
+	}
+
+	*now = now.Add(time.Hour)
+	w.Write([]byte("third\n"))
+	backups := w.backups()
+	if len(backups) != 1 {
+		t.Fatalf("Expected: the log file to be rotated once it is an hour old, got %v", backups)
+	}
+	if data, _ := ioutil.ReadFile(backups[0]); string(data) != "first\nsecond\n" {
+		t.Errorf("Unexpected backup: %q", data)
+	}
+
+	// Backups older than the maximum age are removed when the log file is next rotated
+	old := now.Add(-2 * time.Hour)
+	if err := os.Chtimes(backups[0], old, old); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(time.Hour)
+	w.Write([]byte("fourth\n"))
+	backups = w.backups()
+	if len(backups) != 1 {
+		t.Fatalf("Expected: only the latest backup to be retained, got %v", backups)
+	}
+	if data, _ := ioutil.ReadFile(backups[0]); string(data) != "third\n" {
+		t.Errorf("Unexpected backup: %q", data)
+	}
+}
+
+func Test_RotatingWriter_RenameFails(t *testing.T) {
+	w, now, dir := newTestWriter(t, Options{MaxSize: 10})
+	defer os.RemoveAll(dir)
+	defer w.Close()
+	var errOut bytes.Buffer
+	w.errOut = &errOut
+
+	w.Write([]byte("first\n"))
+
+	// The log file can not be renamed over a (non-empty) folder
+	*now = now.Add(time.Second)
+	backup := w.path + "." + now.Format(backupTimeFormat)
+	if err := os.MkdirAll(filepath.Join(backup, "blocked"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for i, line := range []string{"second\n", "third\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "first\nsecond\nthird\n" {
+		t.Errorf("Expected: logging to continue to the log file, got %q", data)
+	}
+	if n := strings.Count(errOut.String(), "Failed to rotate the log file"); n != 1 {
+		t.Errorf("Expected: the failure to be reported once, got %q", errOut.String())
+	}
+
+	// The log file is rotated once the backup can be created
+	*now = now.Add(time.Second)
+	if _, err := w.Write([]byte("fourth\n")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(w.path); string(data) != "fourth\n" {
+		t.Errorf("Expected: the log file to be rotated, got %q", data)
+	}
+	if data, _ := ioutil.ReadFile(w.path + "." + now.Format(backupTimeFormat)); string(data) != "first\nsecond\nthird\n" {
+		t.Errorf("Unexpected backup: %q", data)
+	}
+}
+
+func Test_Configure(t *testing.T) {
+	if err := Configure(Options{Level: "verbose"}); err == nil {
+		t.Error("Expected: an invalid level to be rejected")
+	}
+	if err := Configure(Options{Format: "xml"}); err == nil {
+		t.Error("Expected: an invalid format to be rejected")
+	}
+}
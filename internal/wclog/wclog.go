@@ -0,0 +1,105 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+// Package wclog configures the application log: its level, format and
+// destination (stderr or a log file which is rotated by size and age).
+package wclog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Supported log formats
+const (
+	// FormatText logs human readable lines of text
+	FormatText = "text"
+	// FormatJSON logs a JSON object per line
+	FormatJSON = "json"
+)
+
+// Options configure the application log
+type Options struct {
+	// Level is the minimum level logged (i.e. "info" or "debug"). Defaults to "info".
+	Level string
+	// Format is either FormatText (default) or FormatJSON
+	Format string
+	// File is the path of the log file. The log is written to stderr if it is not set.
+	File string
+	// MaxSize is the size (in bytes) the log file is rotated at (0 disables)
+	MaxSize int64
+	// RotateInterval is the age the log file is rotated at (0 disables)
+	RotateInterval time.Duration
+	// MaxBackups is the number of rotated log files which are retained (0 retains all)
+	MaxBackups int
+	// MaxAge is the age rotated log files are removed at (0 retains all)
+	MaxAge time.Duration
+}
+
+var (
+	m sync.Mutex
+	// output is the log file currently written to, if any
+	output io.Closer
+)
+
+// ParseLevel parses a log level, which defaults to info if not set
+func ParseLevel(level string) (log.Level, error) {
+	if level == "" {
+		return log.InfoLevel, nil
+	}
+	return log.ParseLevel(level)
+}
+
+// formatter returns the log formatter for the provided format
+func formatter(format string) (log.Formatter, error) {
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		return &log.TextFormatter{}, nil
+	case FormatJSON:
+		return &log.JSONFormatter{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported log format %q (expected %q or %q)", format, FormatText, FormatJSON)
+	}
+}
+
+// Configure applies opts to the application log. It may be called again to reconfigure
+// the log, in which case any previous log file is closed.
+func Configure(opts Options) error {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+	f, err := formatter(opts.Format)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stderr
+	var file io.Closer
+	if opts.File != "" {
+		w, err := newRotatingWriter(opts)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %v", err)
+		}
+		out, file = w, w
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	log.SetLevel(level)
+	log.SetFormatter(f)
+	log.SetOutput(out)
+	if output != nil {
+		output.Close()
+	}
+	output = file
+	return nil
+}
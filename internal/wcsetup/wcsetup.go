@@ -0,0 +1,286 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+// Package wcsetup provides the interactive setup wizard (`wcbot -setup`) which
+// creates the Wing Commander configuration file.
+package wcsetup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/netprobe"
+	"github.com/BigOokie/skywire-wing-commander/internal/skymgrmon"
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+	tgbotapi "gopkg.in/telegram-bot-api.v4"
+)
+
+const (
+	defaultManagerAddress   = "127.0.0.1:8000"
+	defaultDiscoveryAddress = "testnet.skywire.skycoin.com:8001"
+	defaultWaitTimeout      = 5 * time.Minute
+	defaultProbeTimeout     = 5 * time.Second
+	// Long polling timeout (in seconds) used while waiting for the Admin's message
+	pollTimeout = 30
+)
+
+// errCancelled is returned when the user chooses not to continue
+var errCancelled = errors.New("setup cancelled")
+
+// Wizard prompts for the details required to configure Wing Commander
+type Wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	// Client is used to talk to the Telegram Bot API
+	Client *http.Client
+	// WaitTimeout is how long to wait for the Admin to message the bot
+	WaitTimeout time.Duration
+	// PollTimeout is the long polling timeout (in seconds) used while waiting
+	PollTimeout int
+	// ProbeTimeout is how long to wait when checking the Discovery address is reachable
+	ProbeTimeout time.Duration
+}
+
+// New creates a Wizard which reads answers from in and writes prompts to out
+func New(in io.Reader, out io.Writer) *Wizard {
+	return &Wizard{
+		in:           bufio.NewReader(in),
+		out:          out,
+		Client:       &http.Client{Timeout: (pollTimeout + 10) * time.Second},
+		WaitTimeout:  defaultWaitTimeout,
+		PollTimeout:  pollTimeout,
+		ProbeTimeout: defaultProbeTimeout,
+	}
+}
+
+// printf writes a message to the user
+func (w *Wizard) printf(format string, args ...interface{}) {
+	fmt.Fprintf(w.out, format, args...)
+}
+
+// prompt asks a question and returns the answer, or def if no answer is given
+func (w *Wizard) prompt(question, def string) (string, error) {
+	if def != "" {
+		w.printf("%s [%s]: ", question, def)
+	} else {
+		w.printf("%s: ", question)
+	}
+
+	answer, err := w.in.ReadString('\n')
+	if err != nil && (err != io.EOF || answer == "") {
+		return "", err
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+// confirm asks a yes/no question, returning def if no answer is given
+func (w *Wizard) confirm(question string, def bool) (bool, error) {
+	choices := "y/N"
+	if def {
+		choices = "Y/n"
+	}
+	answer, err := w.prompt(question+" ("+choices+")", "")
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(answer) {
+	case "":
+		return def, nil
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// Run runs the wizard and writes the resulting configuration file to path.
+// An existing file is only replaced if the user agrees, and is kept as a backup (`.bak`).
+func (w *Wizard) Run(path string) error {
+	w.printf("Wing Commander setup\n\nThis will create %s\n\n", path)
+
+	if _, err := os.Stat(path); err == nil {
+		replace, err := w.confirm(path+" already exists. Replace it", false)
+		if err != nil {
+			return err
+		}
+		if !replace {
+			return errCancelled
+		}
+	}
+
+	var c wcconfig.Config
+	telegram, err := w.setupBot(&c)
+	if err != nil {
+		return err
+	}
+	if err := w.setupAdmin(telegram, &c); err != nil {
+		return err
+	}
+
+	w.printf("\n")
+	if c.SkyManager.Address, err = w.setupAddress("Skywire Manager address (IP:PORT)", defaultManagerAddress, w.checkManager); err != nil {
+		return err
+	}
+	if c.SkyManager.DiscoveryAddress, err = w.setupAddress("Skywire Discovery address (HOST:PORT)", defaultDiscoveryAddress, w.checkDiscovery); err != nil {
+		return err
+	}
+
+	text, err := wcconfig.NewConfigFile(c, "telegram.apikey", "telegram.chatid", "telegram.admin", "telegram.adminids",
+		"skymanager.address", "skymanager.discoveryaddress")
+	if err != nil {
+		return err
+	}
+	if err := writeConfigFile(path, text); err != nil {
+		return err
+	}
+
+	w.printf("\nConfiguration written to %s\n", path)
+	return nil
+}
+
+// setupBot prompts for the bot API key (token) until it is accepted by Telegram
+func (w *Wizard) setupBot(c *wcconfig.Config) (*tgbotapi.BotAPI, error) {
+	w.printf("Create a bot by sending /newbot to @BotFather in Telegram. It will provide an API key (token)\n" +
+		"which looks like 123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw\n\n")
+
+	for {
+		token, err := w.prompt("Bot API key", "")
+		if err != nil {
+			return nil, err
+		}
+		if token == "" {
+			continue
+		}
+
+		telegram, err := tgbotapi.NewBotAPIWithClient(token, w.Client)
+		if err != nil {
+			w.printf("Telegram did not accept the API key: %v\n", err)
+			continue
+		}
+
+		w.printf("API key accepted for bot @%s\n", telegram.Self.UserName)
+		c.Telegram.APIKey = token
+		return telegram, nil
+	}
+}
+
+// setupAdmin waits for the Admin to send a private message to the bot, and
+// configures their private chat with the bot, username and user ID
+func (w *Wizard) setupAdmin(telegram *tgbotapi.BotAPI, c *wcconfig.Config) error {
+	w.printf("\nFrom the Telegram account which will administer Wing Commander, open a chat with\n"+
+		"@%s and send it any message. Waiting up to %v...\n", telegram.Self.UserName, w.WaitTimeout)
+
+	deadline := time.Now().Add(w.WaitTimeout)
+	offset := 0
+	for time.Now().Before(deadline) {
+		updates, err := telegram.GetUpdates(tgbotapi.UpdateConfig{Offset: offset, Timeout: w.PollTimeout})
+		if err != nil {
+			return fmt.Errorf("failed to get messages from Telegram: %v", err)
+		}
+
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			msg := update.Message
+			if msg == nil || msg.From == nil || msg.Chat == nil || !msg.Chat.IsPrivate() {
+				continue
+			}
+			if msg.From.UserName == "" {
+				w.printf("Received a message from %s, who does not have a Telegram username. "+
+					"Set a username (Telegram Settings) and send another message.\n", msg.From.FirstName)
+				continue
+			}
+
+			c.Telegram.ChatID = msg.Chat.ID
+			c.Telegram.Admin = "@" + msg.From.UserName
+			c.Telegram.AdminIDs = []int{msg.From.ID}
+			w.printf("Received a message from @%s (user ID %d, chat ID %d)\n", msg.From.UserName, msg.From.ID, msg.Chat.ID)
+
+			// Acknowledge the messages received, so they are not handled once Wing Commander is started
+			if _, err := telegram.GetUpdates(tgbotapi.UpdateConfig{Offset: offset, Limit: 1}); err != nil {
+				w.printf("Failed to acknowledge messages: %v\n", err)
+			}
+			reply := tgbotapi.NewMessage(msg.Chat.ID, "Wing Commander setup: this chat and your Telegram user ID are now configured.")
+			if _, err := telegram.Send(reply); err != nil {
+				w.printf("Failed to reply: %v\n", err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("no message was received by @%s within %v", telegram.Self.UserName, w.WaitTimeout)
+}
+
+// setupAddress prompts for an address until check succeeds, or the user chooses to use it anyway
+func (w *Wizard) setupAddress(question, def string, check func(string) (string, error)) (string, error) {
+	for {
+		addr, err := w.prompt(question, def)
+		if err != nil {
+			return "", err
+		}
+
+		result, err := check(addr)
+		if err == nil {
+			w.printf("%s\n", result)
+			return addr, nil
+		}
+
+		w.printf("%s is not reachable: %v\n", addr, err)
+		use, err := w.confirm("Use it anyway", false)
+		if err != nil {
+			return "", err
+		}
+		if use {
+			return addr, nil
+		}
+	}
+}
+
+// checkManager checks the Skywire Manager is reachable at addr
+func (w *Wizard) checkManager(addr string) (string, error) {
+	nodes, err := skymgrmon.NewMonitor(addr, "").GetAllNodes()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Skywire Manager is reachable (%d Nodes connected)", len(nodes)), nil
+}
+
+// checkDiscovery checks the Skywire Discovery Node is reachable at addr
+func (w *Wizard) checkDiscovery(addr string) (string, error) {
+	latency, err := netprobe.TCPProber{}.Probe(addr, w.ProbeTimeout)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Skywire Discovery is reachable (%v)", latency), nil
+}
+
+// writeConfigFile writes the configuration file, which is only readable by the user as it
+// contains the bot API key. An existing file is kept as a backup.
+func writeConfigFile(path, text string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		if err := os.Rename(path, path+".bak"); err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(path, []byte(text), 0600)
+}
+
+// IsCancelled determines if err was returned because the user chose not to continue
+func IsCancelled(err error) bool {
+	return err == errCancelled
+}
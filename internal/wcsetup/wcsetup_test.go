@@ -0,0 +1,183 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package wcsetup
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BigOokie/skywire-wing-commander/internal/wcconfig"
+)
+
+const testToken = "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw"
+
+// fakeTelegram is a http.RoundTripper which responds as the Telegram Bot API would
+type fakeTelegram struct {
+	m        sync.Mutex
+	requests []string
+	polled   bool
+}
+
+func (ft *fakeTelegram) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, _ := ioutil.ReadAll(req.Body)
+	ft.m.Lock()
+	defer ft.m.Unlock()
+	ft.requests = append(ft.requests, req.URL.Path+"?"+string(body))
+
+	result := `{"ok":true,"result":[]}`
+	switch {
+	case !strings.Contains(req.URL.Path, testToken):
+		result = `{"ok":false,"error_code":401,"description":"Unauthorized"}`
+	case strings.HasSuffix(req.URL.Path, "/getMe"):
+		result = `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"WC","username":"wctestbot"}}`
+	case strings.HasSuffix(req.URL.Path, "/getUpdates") && !ft.polled:
+		ft.polled = true
+		result = `{"ok":true,"result":[` +
+			`{"update_id":10,"message":{"message_id":1,"date":0,"text":"hi","from":{"id":500,"first_name":"Group"},"chat":{"id":-100,"type":"group"}}},` +
+			`{"update_id":11,"message":{"message_id":2,"date":0,"text":"hi","from":{"id":501,"first_name":"Anon"},"chat":{"id":501,"type":"private"}}},` +
+			`{"update_id":12,"message":{"message_id":3,"date":0,"text":"hi","from":{"id":502,"first_name":"Test","username":"TESTUSER"},"chat":{"id":502,"type":"private"}}}]}`
+	case strings.HasSuffix(req.URL.Path, "/sendMessage"):
+		result = `{"ok":true,"result":{"message_id":4,"date":0,"chat":{"id":502,"type":"private"}}}`
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(result)),
+		Request:    req,
+	}, nil
+}
+
+func newTestWizard(input string) (*Wizard, *fakeTelegram, *bytes.Buffer) {
+	ft := &fakeTelegram{}
+	out := &bytes.Buffer{}
+	w := New(strings.NewReader(input), out)
+	w.Client = &http.Client{Transport: ft}
+	w.WaitTimeout = time.Second
+	w.PollTimeout = 0
+	w.ProbeTimeout = time.Second
+	return w, ft, out
+}
+
+func Test_Run(t *testing.T) {
+	manager := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}))
+	defer manager.Close()
+	addr := manager.Listener.Addr().String()
+
+	dir, err := ioutil.TempDir("", "wcsetup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.toml")
+	if err := ioutil.WriteFile(path, []byte("# old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	input := strings.Join([]string{
+		"y",           // Replace the existing file
+		"BAD-TOKEN",   // Rejected by Telegram
+		testToken,     // Accepted
+		"127.0.0.1:1", // Manager is unreachable
+		"n",           // Don't use it anyway
+		addr,          // Manager is reachable
+		addr,          // Discovery is reachable
+	}, "\n") + "\n"
+	w, ft, out := newTestWizard(input)
+
+	if err := w.Run(path); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+
+	for _, expect := range []string{
+		"Telegram did not accept the API key",
+		"API key accepted for bot @wctestbot",
+		"does not have a Telegram username",
+		"Received a message from @TESTUSER (user ID 502, chat ID 502)",
+		"127.0.0.1:1 is not reachable",
+		"Skywire Manager is reachable (0 Nodes connected)",
+		"Skywire Discovery is reachable",
+	} {
+		if !strings.Contains(out.String(), expect) {
+			t.Errorf("Expected: %q in:\n%s", expect, out)
+		}
+	}
+
+	// The received messages are acknowledged and the Admin is told the chat is configured
+	var acked, replied bool
+	for _, r := range ft.requests {
+		acked = acked || strings.Contains(r, "/getUpdates?") && strings.Contains(r, "offset=13")
+		replied = replied || strings.Contains(r, "/sendMessage?") && strings.Contains(r, "chat_id=502")
+	}
+	if !acked || !replied {
+		t.Errorf("Expected: messages to be acknowledged (%v) and a reply (%v): %v", acked, replied, ft.requests)
+	}
+
+	if data, err := ioutil.ReadFile(path + ".bak"); err != nil || string(data) != "# old\n" {
+		t.Errorf("Expected: the existing file to be backed up: %q %v", data, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected: the config file to be private, got %v", info.Mode())
+	}
+
+	c, err := wcconfig.LoadConfigParameters("config", dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Telegram.APIKey != testToken || c.Telegram.ChatID != 502 || c.Telegram.Admin != "@TESTUSER" ||
+		len(c.Telegram.AdminIDs) != 1 || c.Telegram.AdminIDs[0] != 502 ||
+		c.SkyManager.Address != addr || c.SkyManager.DiscoveryAddress != addr {
+		t.Errorf("Unexpected config: %s", c.String())
+	}
+}
+
+func Test_Run_KeepExisting(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wcsetup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.toml")
+	if err := ioutil.WriteFile(path, []byte("# old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	w, _, _ := newTestWizard("\n")
+	if err := w.Run(path); !IsCancelled(err) {
+		t.Errorf("Expected: setup to be cancelled, got %v", err)
+	}
+	if data, _ := ioutil.ReadFile(path); string(data) != "# old\n" {
+		t.Errorf("Expected: the existing file to be unchanged, got %q", data)
+	}
+}
+
+func Test_Run_NoMessage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wcsetup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, ft, _ := newTestWizard(testToken + "\n")
+	ft.polled = true
+	w.WaitTimeout = 50 * time.Millisecond
+	if err := w.Run(filepath.Join(dir, "config.toml")); err == nil || !strings.Contains(err.Error(), "no message was received") {
+		t.Errorf("Expected: no message error, got %v", err)
+	}
+}
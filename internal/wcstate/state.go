@@ -0,0 +1,227 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package wcstate
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// State models the runtime state that Wing Commander needs to retain between restarts.
+// Unlike the Config, State is written by the application itself and should not be
+// edited by hand.
+type State struct {
+	AdminIDs             []int          `json:"adminids"`
+	TwoFactorSecret      string         `json:"twofactorsecret,omitempty"`
+	Upgrade              *UpgradeState  `json:"upgrade,omitempty"`
+	LastNotifiedVersion  string         `json:"lastnotifiedversion,omitempty"`
+	SkippedUpdateVersion string         `json:"skippedupdateversion,omitempty"`
+	PendingAlerts        []PendingAlert `json:"pendingalerts,omitempty"`
+
+	path string
+	m    sync.Mutex
+}
+
+// UpgradeState records an upgrade which is in progress, or which has been rolled back
+type UpgradeState struct {
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Previous   string    `json:"previous"`
+	Started    time.Time `json:"started"`
+	RolledBack bool      `json:"rolledback,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// PendingAlert records an alert which could not yet be delivered to Telegram, so that it
+// can be delivered after a restart
+type PendingAlert struct {
+	ChatID int64     `json:"chatid"`
+	Format string    `json:"format"`
+	Text   string    `json:"text"`
+	Queued time.Time `json:"queued"`
+}
+
+// NewState creates an empty State which will be persisted to the provided path
+func NewState(path string) *State {
+	return &State{path: path}
+}
+
+// LoadState attempts to read the State from the provided path.
+// A missing file is not an error - an empty State will be returned which
+// will be created on the first call to Save.
+func LoadState(path string) (*State, error) {
+	s := NewState(path)
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Debugf("LoadState: State file (%s) does not exist. Starting with empty state.", path)
+		return s, nil
+	} else if err != nil {
+		return s, err
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// Path returns the path the State is persisted to
+func (s *State) Path() string {
+	return s.path
+}
+
+// Save will persist the State to disk. The file is written to a temporary
+// location first and then renamed so that a partial write cannot corrupt the
+// existing State.
+func (s *State) Save() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.save()
+}
+
+// save persists the State. The caller must hold the lock.
+func (s *State) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+
+	tmpfile := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmpfile, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpfile, s.path)
+}
+
+// GetAdminIDs is a thread-safe function which returns a copy of the
+// Telegram user IDs bound as Admin
+func (s *State) GetAdminIDs() []int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]int(nil), s.AdminIDs...)
+}
+
+// AddAdminID is a thread-safe function which binds the provided Telegram
+// user ID as an Admin and persists the State
+func (s *State) AddAdminID(id int) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	for _, v := range s.AdminIDs {
+		if v == id {
+			return nil
+		}
+	}
+	s.AdminIDs = append(s.AdminIDs, id)
+	return s.save()
+}
+
+// GetTwoFactorSecret is a thread-safe function which returns the two factor
+// (TOTP) secret provisioned at runtime
+func (s *State) GetTwoFactorSecret() string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.TwoFactorSecret
+}
+
+// SetTwoFactorSecret is a thread-safe function which sets the two factor
+// (TOTP) secret and persists the State
+func (s *State) SetTwoFactorSecret(secret string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.TwoFactorSecret = secret
+	return s.save()
+}
+
+// GetUpgrade is a thread-safe function which returns a copy of the
+// recorded upgrade (or nil if there is none)
+func (s *State) GetUpgrade() *UpgradeState {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.Upgrade == nil {
+		return nil
+	}
+	u := *s.Upgrade
+	return &u
+}
+
+// SetUpgrade is a thread-safe function which records the provided upgrade
+// and persists the State. Passing nil clears the recorded upgrade.
+func (s *State) SetUpgrade(u *UpgradeState) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if u != nil {
+		c := *u
+		u = &c
+	}
+	s.Upgrade = u
+	return s.save()
+}
+
+// GetLastNotifiedVersion is a thread-safe function which returns the
+// version most recently notified as an available update
+func (s *State) GetLastNotifiedVersion() string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.LastNotifiedVersion
+}
+
+// SetLastNotifiedVersion is a thread-safe function which records the version
+// most recently notified as an available update and persists the State
+func (s *State) SetLastNotifiedVersion(version string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.LastNotifiedVersion = version
+	return s.save()
+}
+
+// GetSkippedUpdateVersion is a thread-safe function which returns the
+// version the Admin has chosen not to be notified about
+func (s *State) GetSkippedUpdateVersion() string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.SkippedUpdateVersion
+}
+
+// SetSkippedUpdateVersion is a thread-safe function which records the version
+// the Admin has chosen not to be notified about and persists the State
+func (s *State) SetSkippedUpdateVersion(version string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.SkippedUpdateVersion = version
+	return s.save()
+}
+
+// GetPendingAlerts is a thread-safe function which returns a copy of the
+// alerts which have not yet been delivered
+func (s *State) GetPendingAlerts() []PendingAlert {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]PendingAlert(nil), s.PendingAlerts...)
+}
+
+// SetPendingAlerts is a thread-safe function which records the alerts which
+// have not yet been delivered and persists the State
+func (s *State) SetPendingAlerts(alerts []PendingAlert) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.PendingAlerts = append([]PendingAlert(nil), alerts...)
+	return s.save()
+}
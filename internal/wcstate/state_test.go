@@ -0,0 +1,132 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package wcstate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func Test_LoadState_NoFile(t *testing.T) {
+	state, err := LoadState("./this-file-does-not-exist.json")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(state.GetAdminIDs()) != 0 {
+		t.Error("Expected: State should be empty")
+	}
+}
+
+func Test_State_AddAdminID_SaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wcstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	statefile := filepath.Join(dir, "state.json")
+	state := NewState(statefile)
+	if err := state.AddAdminID(123456789); err != nil {
+		t.Error(err)
+	}
+	// Adding the same ID twice should not duplicate it
+	if err := state.AddAdminID(123456789); err != nil {
+		t.Error(err)
+	}
+
+	loaded, err := LoadState(statefile)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if diff := deep.Equal(loaded.GetAdminIDs(), []int{123456789}); diff != nil {
+		t.Error(diff)
+	}
+
+	info, err := os.Stat(statefile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Unexpected state file permissions: %v", info.Mode().Perm())
+	}
+}
+
+func Test_State_SetUpgrade_SaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wcstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	statefile := filepath.Join(dir, "state.json")
+	state := NewState(statefile)
+	upgrade := UpgradeState{
+		From:     "v1.1.1",
+		To:       "v1.2.0",
+		Previous: "/usr/local/bin/wcbot.old",
+		Started:  time.Date(2018, 7, 1, 10, 0, 0, 0, time.UTC),
+	}
+	if err := state.SetUpgrade(&upgrade); err != nil {
+		t.Error(err)
+	}
+
+	loaded, err := LoadState(statefile)
+	if err != nil {
+		t.Error(err)
+	}
+	if diff := deep.Equal(loaded.GetUpgrade(), &upgrade); diff != nil {
+		t.Error(diff)
+	}
+
+	if err := loaded.SetUpgrade(nil); err != nil {
+		t.Error(err)
+	}
+	loaded, err = LoadState(statefile)
+	if err != nil {
+		t.Error(err)
+	}
+	if loaded.GetUpgrade() != nil {
+		t.Error("Expected: The upgrade to be cleared")
+	}
+}
+
+func Test_State_SetPendingAlerts_SaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wcstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	statefile := filepath.Join(dir, "state.json")
+	state := NewState(statefile)
+	alerts := []PendingAlert{
+		{ChatID: 123456789, Format: "markdown", Text: "*Node disconnected*", Queued: time.Date(2018, 7, 1, 10, 0, 0, 0, time.UTC)},
+	}
+	if err := state.SetPendingAlerts(alerts); err != nil {
+		t.Error(err)
+	}
+
+	loaded, err := LoadState(statefile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(loaded.GetPendingAlerts(), alerts); diff != nil {
+		t.Error(diff)
+	}
+
+	if err := loaded.SetPendingAlerts(nil); err != nil {
+		t.Error(err)
+	}
+	if len(loaded.GetPendingAlerts()) != 0 {
+		t.Error("Expected: no pending alerts")
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package wctelemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HTTPSink is a Sink which posts each event as a JSON object to a URL
+type HTTPSink struct {
+	URL string
+	// ClientID anonymously identifies the installation (see wcconfig.WingCommanderAnalytics)
+	ClientID string
+	// Version is the application version sent with each event
+	Version string
+	Client  *http.Client
+}
+
+// NewHTTPSink creates an HTTPSink which posts events to url
+func NewHTTPSink(url, clientID, version string) *HTTPSink {
+	return &HTTPSink{
+		URL:      url,
+		ClientID: clientID,
+		Version:  version,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send posts the event to the URL
+func (s *HTTPSink) Send(e Event) error {
+	body, err := json.Marshal(struct {
+		ClientID string `json:"clientid"`
+		Version  string `json:"version"`
+		Event
+	}{s.ClientID, s.Version, e})
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.Client.Post(s.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s responded %s", s.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package wctelemetry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_HTTPSink_Send(t *testing.T) {
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	s := NewHTTPSink(srv.URL, "abc123", "v1.0.0")
+	if err := s.Send(Event{Category: CategoryCommand, Action: "status", Label: "Handlestatus"}); err != nil {
+		t.Fatal(err)
+	}
+	if got["clientid"] != "abc123" || got["version"] != "v1.0.0" || got["category"] != CategoryCommand || got["action"] != "status" {
+		t.Errorf("Unexpected event: %v", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	if err := NewHTTPSink(failing.URL, "abc123", "v1.0.0").Send(Event{}); err == nil {
+		t.Error("Expected: an error for a 503 response")
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+// Package wctelemetry records application usage events. Events are always counted
+// locally (see Snapshot) and never leave the machine, unless a remote Sink is provided
+// (which requires the user to opt in).
+package wctelemetry
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Event categories with a specific meaning within the usage statistics
+const (
+	// CategoryCommand is the category of a command handled by the bot. The action is the command,
+	// optionally qualified by an outcome (i.e. `status-isrunning`) which is not counted separately.
+	CategoryCommand = "BotCommand"
+	// CategoryCommandError is the category of a command which failed (the action is the command)
+	CategoryCommandError = "BotCommandError"
+)
+
+// queueSize is the number of events which may be waiting to be sent to the remote Sink.
+// Further events are dropped rather than blocking the caller.
+const queueSize = 100
+
+// Event is an application usage event
+type Event struct {
+	Time     time.Time `json:"time"`
+	Category string    `json:"category"`
+	Action   string    `json:"action"`
+	Label    string    `json:"label"`
+}
+
+// Sink sends usage events to a remote service
+type Sink interface {
+	Send(e Event) error
+}
+
+// Snapshot is a point in time copy of the usage statistics
+type Snapshot struct {
+	Started  time.Time
+	Uptime   time.Duration
+	Events   int
+	Commands map[string]int
+	Errors   map[string]int
+	// Remote determines if events are also sent to a remote Sink,
+	// in which case the number sent, failed and dropped are provided
+	Remote  bool
+	Sent    int64
+	Failed  int64
+	Dropped int64
+}
+
+// Telemetry records usage events
+type Telemetry struct {
+	started time.Time
+	remote  Sink
+	queue   chan Event
+	done    chan struct{}
+	close   sync.Once
+
+	m        sync.Mutex
+	events   int
+	commands map[string]int
+	errors   map[string]int
+
+	sent, failed, dropped int64
+}
+
+// New creates a Telemetry which counts events locally and, if remote is not nil, also
+// sends them to remote in the background
+func New(remote Sink) *Telemetry {
+	t := &Telemetry{
+		started:  time.Now(),
+		remote:   remote,
+		done:     make(chan struct{}),
+		commands: make(map[string]int),
+		errors:   make(map[string]int),
+	}
+	if remote != nil {
+		t.queue = make(chan Event, queueSize)
+		go t.sendLoop()
+	}
+	return t
+}
+
+// Record records a usage event. It never blocks: if the remote Sink is not keeping up, the
+// event is only counted locally.
+func (t *Telemetry) Record(category, action, label string) {
+	if t == nil {
+		return
+	}
+	e := Event{Time: time.Now(), Category: category, Action: action, Label: label}
+
+	t.m.Lock()
+	t.events++
+	switch {
+	case category == CategoryCommand:
+		t.commands[strings.SplitN(action, "-", 2)[0]]++
+	case category == CategoryCommandError:
+		t.errors[action]++
+	}
+	t.m.Unlock()
+
+	if t.queue == nil {
+		return
+	}
+	select {
+	case t.queue <- e:
+	default:
+		atomic.AddInt64(&t.dropped, 1)
+	}
+}
+
+// sendLoop sends queued events to the remote Sink until Close is called
+func (t *Telemetry) sendLoop() {
+	for {
+		select {
+		case e := <-t.queue:
+			if err := t.remote.Send(e); err != nil {
+				atomic.AddInt64(&t.failed, 1)
+				log.Debugf("Telemetry.sendLoop: Failed to send event: %v", err)
+				continue
+			}
+			atomic.AddInt64(&t.sent, 1)
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// Snapshot returns a copy of the usage statistics
+func (t *Telemetry) Snapshot() Snapshot {
+	t.m.Lock()
+	defer t.m.Unlock()
+	s := Snapshot{
+		Started:  t.started,
+		Uptime:   time.Since(t.started),
+		Events:   t.events,
+		Commands: make(map[string]int, len(t.commands)),
+		Errors:   make(map[string]int, len(t.errors)),
+		Remote:   t.remote != nil,
+		Sent:     atomic.LoadInt64(&t.sent),
+		Failed:   atomic.LoadInt64(&t.failed),
+		Dropped:  atomic.LoadInt64(&t.dropped),
+	}
+	for k, v := range t.commands {
+		s.Commands[k] = v
+	}
+	for k, v := range t.errors {
+		s.Errors[k] = v
+	}
+	return s
+}
+
+// Close stops sending events to the remote Sink. Events are still counted locally.
+func (t *Telemetry) Close() {
+	t.close.Do(func() { close(t.done) })
+}
@@ -0,0 +1,97 @@
+// Copyright © 2018 BigOokie
+//
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+package wctelemetry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+// blockingSink blocks each Send until it is released
+type blockingSink struct {
+	sending chan struct{}
+	release chan struct{}
+	sent    chan Event
+}
+
+func (s *blockingSink) Send(e Event) error {
+	s.sending <- struct{}{}
+	<-s.release
+	s.sent <- e
+	return nil
+}
+
+func Test_Telemetry_LocalStats(t *testing.T) {
+	tm := New(nil)
+	defer tm.Close()
+
+	tm.Record(CategoryCommand, "status", "Handlestatus")
+	tm.Record(CategoryCommand, "status-isrunning", "Handlestatus")
+	tm.Record(CategoryCommand, "help", "Handlehelp")
+	tm.Record(CategoryCommandError, "update", "Handleupdate")
+	tm.Record("AppInit", "BotStart", "Bot Starting")
+
+	s := tm.Snapshot()
+	if diff := deep.Equal(s.Commands, map[string]int{"status": 2, "help": 1}); diff != nil {
+		t.Errorf("Unexpected command counts: %v", diff)
+	}
+	if diff := deep.Equal(s.Errors, map[string]int{"update": 1}); diff != nil {
+		t.Errorf("Unexpected error counts: %v", diff)
+	}
+	if s.Events != 5 || s.Remote {
+		t.Errorf("Unexpected snapshot: %+v", s)
+	}
+
+	// A nil Telemetry records nothing
+	var none *Telemetry
+	none.Record(CategoryCommand, "status", "Handlestatus")
+}
+
+func Test_Telemetry_RemoteNeverBlocks(t *testing.T) {
+	sink := &blockingSink{
+		sending: make(chan struct{}, queueSize+1),
+		release: make(chan struct{}),
+		sent:    make(chan Event, queueSize+1),
+	}
+	tm := New(sink)
+	defer tm.Close()
+
+	tm.Record(CategoryCommand, "status", "Handlestatus")
+	<-sink.sending
+
+	// While the Sink is blocked, events beyond the queue are dropped rather than blocking
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < queueSize+10; i++ {
+			tm.Record(CategoryCommand, "status", "Handlestatus")
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Record blocked while the remote Sink was blocked")
+	}
+
+	s := tm.Snapshot()
+	if s.Commands["status"] != queueSize+11 || !s.Remote {
+		t.Errorf("Expected: all events to be counted locally, got %+v", s)
+	}
+	// The Sink holds one event and the queue is full
+	if s.Dropped != 10 {
+		t.Errorf("Expected: 10 dropped events, got %d", s.Dropped)
+	}
+
+	close(sink.release)
+	for i := 0; i < queueSize+1; i++ {
+		select {
+		case <-sink.sent:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected: %d events to be sent, got %d", queueSize+1, i)
+		}
+	}
+}
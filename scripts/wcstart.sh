@@ -1,7 +1,13 @@
 #!/bin/sh
-# Auto Startup Wing Commander 
+# Auto Startup Wing Commander
+# Wing Commander logs to the file configured by log.file within ~/.wingcommander/config.toml.
+# If it is not set, the log is written to ~/.wingcommander/wcbot.log
 
 export GOPATH=$HOME/go
 
 cd $GOPATH/bin
-./wcbot /dev/null 2>&1 & echo $! > wcbot.pid &
+if grep -qs "^[[:space:]]*file[[:space:]]*=" $HOME/.wingcommander/config.toml; then
+  ./wcbot > /dev/null 2>&1 & echo $! > wcbot.pid &
+else
+  ./wcbot -log.file $HOME/.wingcommander/wcbot.log > /dev/null 2>&1 & echo $! > wcbot.pid &
+fi